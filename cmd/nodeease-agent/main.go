@@ -0,0 +1,351 @@
+// Command nodeease-agent runs on NodeEase nodes. It keeps an outbound
+// WebSocket connection to the NodeEase API, reports heartbeats and runs the
+// whitelisted commands the API sends it.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/0saurabh0/NodeEase/models"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	agentVersion = "0.1.0"
+
+	// heartbeatInterval matches the interval the API watches for missed heartbeats
+	heartbeatInterval = 30 * time.Second
+
+	// maxReconnectDelay caps the backoff between connection attempts
+	maxReconnectDelay = time.Minute
+
+	// maxOutputBytes is how much command output is sent back, from the end
+	maxOutputBytes = 64 * 1024
+
+	// defaultCommandTimeout applies when a command doesn't carry its own
+	defaultCommandTimeout = 2 * time.Minute
+)
+
+// config is read from the environment file the bootstrap script writes
+type config struct {
+	APIURL  string // e.g. https://nodeease.example/api
+	NodeID  string
+	Token   string
+	Service string // systemd unit of the validator
+	RPCURL  string // local JSON-RPC endpoint
+	DataDir string // filesystem whose free space is reported
+}
+
+func loadConfig() (config, error) {
+	cfg := config{
+		APIURL:  os.Getenv("NODEEASE_API_URL"),
+		NodeID:  os.Getenv("NODEEASE_NODE_ID"),
+		Token:   os.Getenv("NODEEASE_TOKEN"),
+		Service: os.Getenv("NODEEASE_SERVICE"),
+		RPCURL:  os.Getenv("NODEEASE_RPC_URL"),
+		DataDir: os.Getenv("NODEEASE_DATA_DIR"),
+	}
+
+	if cfg.APIURL == "" || cfg.NodeID == "" || cfg.Token == "" {
+		return config{}, fmt.Errorf("NODEEASE_API_URL, NODEEASE_NODE_ID and NODEEASE_TOKEN are required")
+	}
+	// The connection carries the token and the scripts run as root
+	if !cfg.secure() {
+		return config{}, fmt.Errorf("NODEEASE_API_URL must be an https URL")
+	}
+	if cfg.Service == "" {
+		cfg.Service = "solana-validator"
+	}
+	if cfg.RPCURL == "" {
+		cfg.RPCURL = "http://127.0.0.1:8899"
+	}
+	if cfg.DataDir == "" {
+		cfg.DataDir = "/data/solana"
+	}
+
+	return cfg, nil
+}
+
+// secure reports whether the API is reached over TLS
+func (cfg config) secure() bool {
+	u, err := url.Parse(cfg.APIURL)
+	return err == nil && u.Scheme == "https" && u.Host != ""
+}
+
+// connectURL returns the WebSocket URL of the agent endpoint. Only wss is
+// used, the API URL has to be https.
+func (cfg config) connectURL() (string, error) {
+	u, err := url.Parse(strings.TrimRight(cfg.APIURL, "/") + "/agent/" + url.PathEscape(cfg.NodeID) + "/connect")
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "https" {
+		return "", fmt.Errorf("API URL scheme %q is not allowed, use https", u.Scheme)
+	}
+	u.Scheme = "wss"
+
+	return u.String(), nil
+}
+
+// agent is a single connection to the API
+type agent struct {
+	cfg config
+	ws  *websocket.Conn
+
+	mu sync.Mutex // Serializes writes
+}
+
+func (a *agent) send(msg models.AgentMessage) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.ws.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return websocket.JSON.Send(a.ws, msg)
+}
+
+func main() {
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	log.Printf("NodeEase agent %s starting for node %s", agentVersion, cfg.NodeID)
+
+	delay := time.Second
+	for {
+		started := time.Now()
+		if err := run(cfg); err != nil {
+			log.Printf("Connection lost: %v", err)
+		}
+
+		// Back off while the API is unreachable, reset once a connection held
+		if time.Since(started) > maxReconnectDelay {
+			delay = time.Second
+		}
+		time.Sleep(delay)
+		if delay *= 2; delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// run connects to the API and serves the connection until it breaks
+func run(cfg config) error {
+	target, err := cfg.connectURL()
+	if err != nil {
+		return err
+	}
+
+	wsConfig, err := websocket.NewConfig(target, cfg.APIURL)
+	if err != nil {
+		return err
+	}
+	wsConfig.Header.Set("Authorization", "Bearer "+cfg.Token)
+
+	ws, err := websocket.DialConfig(wsConfig)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %v", err)
+	}
+	defer ws.Close()
+
+	log.Printf("Connected to %s", cfg.APIURL)
+
+	a := &agent{cfg: cfg, ws: ws}
+
+	done := make(chan struct{})
+	defer close(done)
+	go a.heartbeats(done)
+
+	for {
+		var msg models.AgentMessage
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			return err
+		}
+
+		if msg.Type == models.AgentMessageCommand && msg.Command != nil {
+			go a.runCommand(*msg.Command)
+		}
+	}
+}
+
+// heartbeats reports the node's state until done is closed
+func (a *agent) heartbeats(done chan struct{}) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		heartbeat := a.collectHeartbeat()
+		if err := a.send(models.AgentMessage{Type: models.AgentMessageHeartbeat, Heartbeat: &heartbeat}); err != nil {
+			log.Printf("Failed to send heartbeat: %v", err)
+			a.ws.Close()
+			return
+		}
+
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// collectHeartbeat gathers service state, slot, disk and uptime
+func (a *agent) collectHeartbeat() models.NodeHeartbeat {
+	heartbeat := models.NodeHeartbeat{
+		NodeID:       a.cfg.NodeID,
+		AgentVersion: agentVersion,
+	}
+
+	// is-active exits non-zero for anything but active, the state is still printed
+	state, _ := exec.Command("systemctl", "is-active", a.cfg.Service).Output()
+	heartbeat.ServiceState = strings.TrimSpace(string(state))
+	if heartbeat.ServiceState == "" {
+		heartbeat.ServiceState = "unknown"
+	}
+
+	if slot, err := currentSlot(a.cfg.RPCURL); err == nil {
+		heartbeat.Slot = slot
+	}
+
+	var fs syscall.Statfs_t
+	dir := a.cfg.DataDir
+	if _, err := os.Stat(dir); err != nil {
+		dir = "/"
+	}
+	if err := syscall.Statfs(dir, &fs); err == nil {
+		heartbeat.DiskFreeBytes = int64(fs.Bavail) * int64(fs.Bsize)
+	}
+
+	if uptime, err := os.ReadFile("/proc/uptime"); err == nil {
+		if fields := strings.Fields(string(uptime)); len(fields) > 0 {
+			if seconds, err := strconv.ParseFloat(fields[0], 64); err == nil {
+				heartbeat.UptimeSeconds = int64(seconds)
+			}
+		}
+	}
+
+	return heartbeat
+}
+
+// currentSlot asks the local RPC for the slot it has processed
+func currentSlot(rpcURL string) (int64, error) {
+	body := []byte(`{"jsonrpc":"2.0","id":1,"method":"getSlot"}`)
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Post(rpcURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Result int64 `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, err
+	}
+	if result.Error != nil {
+		return 0, fmt.Errorf("getSlot failed: %s", result.Error.Message)
+	}
+
+	return result.Result, nil
+}
+
+// runCommand runs a whitelisted command and reports its result
+func (a *agent) runCommand(command models.AgentCommand) {
+	result := execute(a.cfg, command)
+	result.CommandID = command.ID
+
+	if err := a.send(models.AgentMessage{Type: models.AgentMessageResult, Result: &result}); err != nil {
+		log.Printf("Failed to report result of command %s: %v", command.ID, err)
+	}
+}
+
+// execute runs a command. Only the command types below are accepted, and
+// scripts only come from the API's own config and upgrade templates.
+func execute(cfg config, command models.AgentCommand) models.AgentCommandResult {
+	timeout := time.Duration(command.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = defaultCommandTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	switch command.Type {
+	case models.AgentCommandRestartService:
+		cmd = exec.CommandContext(ctx, "systemctl", "restart", cfg.Service)
+	case models.AgentCommandTailLogs:
+		lines := command.Lines
+		if lines <= 0 {
+			lines = 100
+		}
+		cmd = exec.CommandContext(ctx, "journalctl", "-u", cfg.Service, "-n", strconv.Itoa(lines), "--no-pager")
+	case models.AgentCommandUploadLogs:
+		cmd = exec.CommandContext(ctx, "/usr/local/bin/nodeease-upload-logs.sh", models.LogBundleReasonOnDemand)
+	case models.AgentCommandApplyConfig, models.AgentCommandUpgrade:
+		if command.Script == "" {
+			return models.AgentCommandResult{Error: "command has no script"}
+		}
+		if !cfg.secure() {
+			return models.AgentCommandResult{Error: "scripts are only run from an https API"}
+		}
+		cmd = exec.CommandContext(ctx, "bash", "-s")
+		cmd.Stdin = strings.NewReader(command.Script)
+	default:
+		return models.AgentCommandResult{Error: fmt.Sprintf("command %q is not allowed", command.Type)}
+	}
+
+	log.Printf("Running command %s (%s)", command.ID, command.Type)
+
+	// Run in its own process group so a timeout also stops anything it started
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = 10 * time.Second
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	err := cmd.Run()
+	result := models.AgentCommandResult{Output: tail(output.Bytes(), maxOutputBytes)}
+
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		result.Error = fmt.Sprintf("command timed out after %s", timeout)
+	case err != nil:
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			result.ExitCode = exitErr.ExitCode()
+		} else {
+			result.Error = err.Error()
+		}
+	}
+
+	return result
+}
+
+// tail returns the last n bytes of output
+func tail(output []byte, n int) string {
+	if len(output) > n {
+		output = output[len(output)-n:]
+	}
+	return string(output)
+}
@@ -11,6 +11,37 @@ import (
 
 var DB *pgxpool.Pool
 
+// nodeColumnMigrations lists columns added to the nodes table over time. They
+// are applied with ADD COLUMN IF NOT EXISTS so existing databases pick them up.
+var nodeColumnMigrations = []string{
+	"ledger_volume_id TEXT NOT NULL DEFAULT ''",
+	"accounts_volume_id TEXT NOT NULL DEFAULT ''",
+	"ephemeral_storage BOOLEAN NOT NULL DEFAULT FALSE",
+	// Nodes deployed before client selection all ran Agave v2.2.14
+	"client TEXT NOT NULL DEFAULT 'agave'",
+	"client_version TEXT NOT NULL DEFAULT 'v2.2.14'",
+	"ssh_host_key TEXT NOT NULL DEFAULT ''",
+	"history_length TEXT NOT NULL DEFAULT ''",
+	"ws_endpoint TEXT NOT NULL DEFAULT ''",
+	"stalled_step TEXT NOT NULL DEFAULT ''",
+	"deploy_attempt INTEGER NOT NULL DEFAULT 1",
+}
+
+// configRevisionColumnMigrations lists columns added to node_config_revisions
+var configRevisionColumnMigrations = []string{
+	"deploy_request JSONB",
+	"delta JSONB",
+	"rollback_of INTEGER NOT NULL DEFAULT 0",
+}
+
+// deploymentLogColumnMigrations lists columns added to node_deployment_logs
+var deploymentLogColumnMigrations = []string{
+	"sequence BIGINT NOT NULL DEFAULT 0",
+	"attempt INTEGER NOT NULL DEFAULT 1",
+	// Sequenced updates that arrived after a newer one, logged but not applied
+	"stale BOOLEAN NOT NULL DEFAULT FALSE",
+}
+
 // Add a custom resolver
 func customResolver(ctx context.Context, host string) ([]string, error) {
 	ips, err := net.DefaultResolver.LookupIP(ctx, "ip4", host)
@@ -113,6 +144,14 @@ func createTables() error {
 		return fmt.Errorf("failed to create nodes table: %v", err)
 	}
 
+	// Add columns introduced after the nodes table was first created
+	for _, column := range nodeColumnMigrations {
+		_, err = DB.Exec(context.Background(), "ALTER TABLE nodes ADD COLUMN IF NOT EXISTS "+column)
+		if err != nil {
+			return fmt.Errorf("failed to add nodes column %q: %v", column, err)
+		}
+	}
+
 	// Create node_deployment_logs table
 	_, err = DB.Exec(context.Background(), `
     CREATE TABLE IF NOT EXISTS node_deployment_logs (
@@ -130,6 +169,205 @@ func createTables() error {
 		return fmt.Errorf("failed to create node_deployment_logs table: %v", err)
 	}
 
+	for _, column := range deploymentLogColumnMigrations {
+		_, err = DB.Exec(context.Background(), "ALTER TABLE node_deployment_logs ADD COLUMN IF NOT EXISTS "+column)
+		if err != nil {
+			return fmt.Errorf("failed to add node_deployment_logs column %q: %v", column, err)
+		}
+	}
+
+	// Each sequenced status update is recorded once
+	_, err = DB.Exec(context.Background(), `
+        CREATE UNIQUE INDEX IF NOT EXISTS node_deployment_logs_sequence
+        ON node_deployment_logs (node_id, sequence) WHERE sequence > 0
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to create node_deployment_logs sequence index: %v", err)
+	}
+
+	// Create upgrade_rollouts table
+	_, err = DB.Exec(context.Background(), `
+        CREATE TABLE IF NOT EXISTS upgrade_rollouts (
+            id TEXT PRIMARY KEY,
+            user_id TEXT NOT NULL,
+            target_version TEXT NOT NULL,
+            batch_size INTEGER NOT NULL,
+            status TEXT NOT NULL,
+            message TEXT NOT NULL DEFAULT '',
+            created_at TIMESTAMP NOT NULL,
+            updated_at TIMESTAMP NOT NULL
+        )
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to create upgrade_rollouts table: %v", err)
+	}
+
+	// Create node_upgrades table
+	_, err = DB.Exec(context.Background(), `
+        CREATE TABLE IF NOT EXISTS node_upgrades (
+            id TEXT PRIMARY KEY,
+            node_id TEXT NOT NULL REFERENCES nodes(id) ON DELETE CASCADE,
+            user_id TEXT NOT NULL,
+            rollout_id TEXT NOT NULL DEFAULT '',
+            client TEXT NOT NULL,
+            from_version TEXT NOT NULL,
+            to_version TEXT NOT NULL,
+            status TEXT NOT NULL,
+            message TEXT NOT NULL DEFAULT '',
+            output TEXT NOT NULL DEFAULT '',
+            created_at TIMESTAMP NOT NULL,
+            updated_at TIMESTAMP NOT NULL
+        )
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to create node_upgrades table: %v", err)
+	}
+
+	// A node runs one upgrade at a time
+	_, err = DB.Exec(context.Background(), `
+        CREATE UNIQUE INDEX IF NOT EXISTS node_upgrades_active
+        ON node_upgrades (node_id) WHERE status IN ('pending', 'running')
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to create node_upgrades active index: %v", err)
+	}
+
+	// Create node_config_revisions table
+	_, err = DB.Exec(context.Background(), `
+        CREATE TABLE IF NOT EXISTS node_config_revisions (
+            id TEXT PRIMARY KEY,
+            node_id TEXT NOT NULL REFERENCES nodes(id) ON DELETE CASCADE,
+            user_id TEXT NOT NULL,
+            revision INTEGER NOT NULL,
+            config JSONB NOT NULL,
+            systemd_unit TEXT NOT NULL,
+            client_config TEXT NOT NULL DEFAULT '',
+            client_version TEXT NOT NULL,
+            reason TEXT NOT NULL,
+            status TEXT NOT NULL,
+            message TEXT NOT NULL DEFAULT '',
+            created_at TIMESTAMP NOT NULL,
+            UNIQUE (node_id, revision)
+        )
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to create node_config_revisions table: %v", err)
+	}
+
+	// Create clusters table
+	_, err = DB.Exec(context.Background(), `
+        CREATE TABLE IF NOT EXISTS clusters (
+            id TEXT NOT NULL,
+            name TEXT NOT NULL,
+            owner_id TEXT NOT NULL DEFAULT '',
+            entrypoints JSONB NOT NULL,
+            known_validators JSONB NOT NULL,
+            expected_genesis_hash TEXT NOT NULL DEFAULT '',
+            expected_shred_version INTEGER NOT NULL DEFAULT 0,
+            created_at TIMESTAMP NOT NULL,
+            updated_at TIMESTAMP NOT NULL,
+            PRIMARY KEY (owner_id, id)
+        )
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to create clusters table: %v", err)
+	}
+
+	// Create node_secrets table
+	_, err = DB.Exec(context.Background(), `
+        CREATE TABLE IF NOT EXISTS node_secrets (
+            node_id TEXT NOT NULL REFERENCES nodes(id) ON DELETE CASCADE,
+            name TEXT NOT NULL,
+            value TEXT NOT NULL,
+            created_at TIMESTAMP NOT NULL,
+            PRIMARY KEY (node_id, name)
+        )
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to create node_secrets table: %v", err)
+	}
+
+	// Create node_agent_commands table
+	_, err = DB.Exec(context.Background(), `
+        CREATE TABLE IF NOT EXISTS node_agent_commands (
+            id TEXT PRIMARY KEY,
+            node_id TEXT NOT NULL REFERENCES nodes(id) ON DELETE CASCADE,
+            user_id TEXT NOT NULL,
+            type TEXT NOT NULL,
+            lines INTEGER NOT NULL DEFAULT 0,
+            status TEXT NOT NULL,
+            exit_code INTEGER NOT NULL DEFAULT 0,
+            output TEXT NOT NULL DEFAULT '',
+            created_at TIMESTAMP NOT NULL,
+            updated_at TIMESTAMP NOT NULL
+        )
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to create node_agent_commands table: %v", err)
+	}
+
+	// Create node_heartbeats table
+	_, err = DB.Exec(context.Background(), `
+        CREATE TABLE IF NOT EXISTS node_heartbeats (
+            node_id TEXT PRIMARY KEY REFERENCES nodes(id) ON DELETE CASCADE,
+            service_state TEXT NOT NULL,
+            slot BIGINT NOT NULL DEFAULT 0,
+            disk_free_bytes BIGINT NOT NULL DEFAULT 0,
+            uptime_seconds BIGINT NOT NULL DEFAULT 0,
+            agent_version TEXT NOT NULL DEFAULT '',
+            received_at TIMESTAMP NOT NULL
+        )
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to create node_heartbeats table: %v", err)
+	}
+
+	// Create node_log_bundles table
+	_, err = DB.Exec(context.Background(), `
+        CREATE TABLE IF NOT EXISTS node_log_bundles (
+            id TEXT PRIMARY KEY,
+            node_id TEXT NOT NULL REFERENCES nodes(id) ON DELETE CASCADE,
+            reason TEXT NOT NULL,
+            size_bytes BIGINT NOT NULL,
+            data BYTEA NOT NULL,
+            created_at TIMESTAMP NOT NULL
+        )
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to create node_log_bundles table: %v", err)
+	}
+
+	// Create node_terminal_sessions table. Audit records outlive their node,
+	// so there is no foreign key.
+	_, err = DB.Exec(context.Background(), `
+        CREATE TABLE IF NOT EXISTS node_terminal_sessions (
+            id TEXT PRIMARY KEY,
+            node_id TEXT NOT NULL,
+            node_owner_id TEXT NOT NULL DEFAULT '',
+            user_id TEXT NOT NULL,
+            role TEXT NOT NULL,
+            remote_addr TEXT NOT NULL DEFAULT '',
+            started_at TIMESTAMP NOT NULL,
+            ended_at TIMESTAMP,
+            end_reason TEXT NOT NULL DEFAULT '',
+            bytes_in BIGINT NOT NULL DEFAULT 0,
+            bytes_out BIGINT NOT NULL DEFAULT 0,
+            record_transcript BOOLEAN NOT NULL DEFAULT FALSE,
+            transcript BYTEA,
+            transcript_truncated BOOLEAN NOT NULL DEFAULT FALSE
+        )
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to create node_terminal_sessions table: %v", err)
+	}
+
+	for _, column := range configRevisionColumnMigrations {
+		_, err = DB.Exec(context.Background(), "ALTER TABLE node_config_revisions ADD COLUMN IF NOT EXISTS "+column)
+		if err != nil {
+			return fmt.Errorf("failed to add node_config_revisions column %q: %v", column, err)
+		}
+	}
+
 	return nil
 }
 
@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/0saurabh0/NodeEase/db"
+	"github.com/0saurabh0/NodeEase/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// SaveAgentCommand creates or updates an agent command record. Scripts are
+// only held in memory and aren't stored.
+func SaveAgentCommand(command models.AgentCommand) error {
+	_, err := db.DB.Exec(context.Background(), `
+        INSERT INTO node_agent_commands (
+            id, node_id, user_id, type, lines, status, exit_code, output, created_at, updated_at
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+        ON CONFLICT (id) DO UPDATE
+        SET status = EXCLUDED.status,
+            exit_code = EXCLUDED.exit_code,
+            output = EXCLUDED.output,
+            updated_at = EXCLUDED.updated_at
+    `, command.ID, command.NodeID, command.UserID, command.Type, command.Lines,
+		command.Status, command.ExitCode, command.Output, command.CreatedAt, command.UpdatedAt)
+
+	return err
+}
+
+// ClaimAgentCommand marks a queued command as sent. It returns false if the
+// command is no longer queued, e.g. because another connection already sent it.
+func ClaimAgentCommand(commandID string) (bool, error) {
+	var id string
+	err := db.DB.QueryRow(context.Background(), `
+        UPDATE node_agent_commands
+        SET status = 'sent', updated_at = $2
+        WHERE id = $1 AND status = 'queued'
+        RETURNING id
+    `, commandID, time.Now()).Scan(&id)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// scanAgentCommands reads agent command rows
+func scanAgentCommands(rows pgx.Rows) ([]models.AgentCommand, error) {
+	defer rows.Close()
+
+	var commands []models.AgentCommand
+	for rows.Next() {
+		var command models.AgentCommand
+		err := rows.Scan(
+			&command.ID, &command.NodeID, &command.UserID, &command.Type, &command.Lines,
+			&command.Status, &command.ExitCode, &command.Output, &command.CreatedAt, &command.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		commands = append(commands, command)
+	}
+
+	return commands, rows.Err()
+}
+
+// GetAgentCommands retrieves the most recent agent commands of a node, newest first
+func GetAgentCommands(nodeID, userID string, limit int) ([]models.AgentCommand, error) {
+	rows, err := db.DB.Query(context.Background(), `
+        SELECT id, node_id, user_id, type, lines, status, exit_code, output, created_at, updated_at
+        FROM node_agent_commands
+        WHERE node_id = $1 AND user_id = $2
+        ORDER BY created_at DESC
+        LIMIT $3
+    `, nodeID, userID, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return scanAgentCommands(rows)
+}
+
+// GetAgentCommand retrieves a single agent command of a node
+func GetAgentCommand(commandID, nodeID, userID string) (models.AgentCommand, error) {
+	rows, err := db.DB.Query(context.Background(), `
+        SELECT id, node_id, user_id, type, lines, status, exit_code, output, created_at, updated_at
+        FROM node_agent_commands
+        WHERE id = $1 AND node_id = $2 AND user_id = $3
+    `, commandID, nodeID, userID)
+
+	if err != nil {
+		return models.AgentCommand{}, err
+	}
+
+	commands, err := scanAgentCommands(rows)
+	if err != nil || len(commands) == 0 {
+		return models.AgentCommand{}, err
+	}
+
+	return commands[0], nil
+}
+
+// GetQueuedAgentCommands retrieves the commands waiting for a node's agent, oldest first
+func GetQueuedAgentCommands(nodeID string) ([]models.AgentCommand, error) {
+	rows, err := db.DB.Query(context.Background(), `
+        SELECT id, node_id, user_id, type, lines, status, exit_code, output, created_at, updated_at
+        FROM node_agent_commands
+        WHERE node_id = $1 AND status = 'queued'
+        ORDER BY created_at ASC
+    `, nodeID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return scanAgentCommands(rows)
+}
+
+// SaveNodeHeartbeat records the latest heartbeat of a node
+func SaveNodeHeartbeat(heartbeat models.NodeHeartbeat) error {
+	_, err := db.DB.Exec(context.Background(), `
+        INSERT INTO node_heartbeats (
+            node_id, service_state, slot, disk_free_bytes, uptime_seconds, agent_version, received_at
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7)
+        ON CONFLICT (node_id) DO UPDATE
+        SET service_state = EXCLUDED.service_state,
+            slot = EXCLUDED.slot,
+            disk_free_bytes = EXCLUDED.disk_free_bytes,
+            uptime_seconds = EXCLUDED.uptime_seconds,
+            agent_version = EXCLUDED.agent_version,
+            received_at = EXCLUDED.received_at
+    `, heartbeat.NodeID, heartbeat.ServiceState, heartbeat.Slot, heartbeat.DiskFreeBytes,
+		heartbeat.UptimeSeconds, heartbeat.AgentVersion, heartbeat.ReceivedAt)
+
+	return err
+}
+
+// GetNodeHeartbeat retrieves the latest heartbeat of a node, or nil if it has never sent one
+func GetNodeHeartbeat(nodeID string) (*models.NodeHeartbeat, error) {
+	var heartbeat models.NodeHeartbeat
+	err := db.DB.QueryRow(context.Background(), `
+        SELECT node_id, service_state, slot, disk_free_bytes, uptime_seconds, agent_version, received_at
+        FROM node_heartbeats
+        WHERE node_id = $1
+    `, nodeID).Scan(
+		&heartbeat.NodeID, &heartbeat.ServiceState, &heartbeat.Slot, &heartbeat.DiskFreeBytes,
+		&heartbeat.UptimeSeconds, &heartbeat.AgentVersion, &heartbeat.ReceivedAt,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &heartbeat, nil
+}
+
+// GetNodesWithStaleHeartbeats returns running nodes that have sent heartbeats
+// but none since cutoff, and haven't changed status since cutoff either
+func GetNodesWithStaleHeartbeats(cutoff time.Time) ([]string, error) {
+	rows, err := db.DB.Query(context.Background(), `
+        SELECT n.id
+        FROM nodes n
+        JOIN node_heartbeats h ON h.node_id = n.id
+        WHERE n.status = 'running' AND h.received_at < $1 AND n.updated_at < $1
+    `, cutoff)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var nodeIDs []string
+	for rows.Next() {
+		var nodeID string
+		if err := rows.Scan(&nodeID); err != nil {
+			return nil, err
+		}
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+
+	return nodeIDs, rows.Err()
+}
@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/0saurabh0/NodeEase/db"
+	"github.com/0saurabh0/NodeEase/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// clusterColumns are selected in the order scanCluster reads them
+const clusterColumns = `id, name, owner_id, entrypoints, known_validators, expected_genesis_hash,
+            expected_shred_version, created_at, updated_at`
+
+// scanCluster reads a single cluster row
+func scanCluster(row pgx.Row) (models.Cluster, error) {
+	var cluster models.Cluster
+	var entrypoints, knownValidators []byte
+
+	err := row.Scan(
+		&cluster.ID, &cluster.Name, &cluster.OwnerID, &entrypoints, &knownValidators,
+		&cluster.ExpectedGenesisHash, &cluster.ExpectedShredVersion, &cluster.CreatedAt,
+		&cluster.UpdatedAt,
+	)
+	if err != nil {
+		return models.Cluster{}, err
+	}
+
+	if err := json.Unmarshal(entrypoints, &cluster.Entrypoints); err != nil {
+		return models.Cluster{}, fmt.Errorf("failed to unmarshal entrypoints: %v", err)
+	}
+	if err := json.Unmarshal(knownValidators, &cluster.KnownValidators); err != nil {
+		return models.Cluster{}, fmt.Errorf("failed to unmarshal known validators: %v", err)
+	}
+	cluster.Private = cluster.OwnerID != ""
+
+	return cluster, nil
+}
+
+// marshalClusterPeers encodes a cluster's entrypoints and known validators
+func marshalClusterPeers(cluster models.Cluster) ([]byte, []byte, error) {
+	entrypoints, err := json.Marshal(cluster.Entrypoints)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal entrypoints: %v", err)
+	}
+	knownValidators, err := json.Marshal(cluster.KnownValidators)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal known validators: %v", err)
+	}
+	return entrypoints, knownValidators, nil
+}
+
+// CreateCluster stores a new cluster. It returns false if the owner already
+// has a cluster with the ID.
+func CreateCluster(cluster models.Cluster) (bool, error) {
+	entrypoints, knownValidators, err := marshalClusterPeers(cluster)
+	if err != nil {
+		return false, err
+	}
+
+	tag, err := db.DB.Exec(context.Background(), `
+        INSERT INTO clusters (
+            id, name, owner_id, entrypoints, known_validators, expected_genesis_hash,
+            expected_shred_version, created_at, updated_at
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        ON CONFLICT (owner_id, id) DO NOTHING
+    `, cluster.ID, cluster.Name, cluster.OwnerID, entrypoints, knownValidators,
+		cluster.ExpectedGenesisHash, cluster.ExpectedShredVersion, cluster.CreatedAt,
+		cluster.UpdatedAt)
+
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+// UpdateCluster updates a cluster, matching on its ID and owner
+func UpdateCluster(cluster models.Cluster) error {
+	entrypoints, knownValidators, err := marshalClusterPeers(cluster)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.DB.Exec(context.Background(), `
+        UPDATE clusters
+        SET name = $1, entrypoints = $2, known_validators = $3, expected_genesis_hash = $4,
+            expected_shred_version = $5, updated_at = $6
+        WHERE id = $7 AND owner_id = $8
+    `, cluster.Name, entrypoints, knownValidators, cluster.ExpectedGenesisHash,
+		cluster.ExpectedShredVersion, cluster.UpdatedAt, cluster.ID, cluster.OwnerID)
+
+	return err
+}
+
+// GetCluster retrieves a cluster by ID and owner ("" for public clusters), or
+// an empty cluster if there is none
+func GetCluster(id, ownerID string) (models.Cluster, error) {
+	cluster, err := scanCluster(db.DB.QueryRow(context.Background(), `
+        SELECT `+clusterColumns+`
+        FROM clusters
+        WHERE id = $1 AND owner_id = $2
+    `, id, ownerID))
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return models.Cluster{}, nil
+		}
+		return models.Cluster{}, err
+	}
+
+	return cluster, nil
+}
+
+// ListClusters retrieves the public clusters and those owned by the user
+func ListClusters(userID string) ([]models.Cluster, error) {
+	rows, err := db.DB.Query(context.Background(), `
+        SELECT `+clusterColumns+`
+        FROM clusters
+        WHERE owner_id = '' OR owner_id = $1
+        ORDER BY owner_id, id
+    `, userID)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clusters []models.Cluster
+	for rows.Next() {
+		cluster, err := scanCluster(rows)
+		if err != nil {
+			return nil, err
+		}
+		clusters = append(clusters, cluster)
+	}
+
+	return clusters, rows.Err()
+}
+
+// DeleteCluster deletes a cluster owned by ownerID ("" for public clusters)
+func DeleteCluster(id, ownerID string) error {
+	_, err := db.DB.Exec(context.Background(), `
+        DELETE FROM clusters WHERE id = $1 AND owner_id = $2
+    `, id, ownerID)
+
+	return err
+}
+
+// ClusterHasNodes reports whether any node was deployed to the cluster. Only
+// the owner's nodes can use a private cluster.
+func ClusterHasNodes(id, ownerID string) (bool, error) {
+	var exists bool
+	err := db.DB.QueryRow(context.Background(),
+		"SELECT EXISTS(SELECT 1 FROM nodes WHERE network_type = $1 AND ($2 = '' OR user_id = $2))",
+		id, ownerID).Scan(&exists)
+
+	return exists, err
+}
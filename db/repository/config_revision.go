@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/0saurabh0/NodeEase/db"
+	"github.com/0saurabh0/NodeEase/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// SaveNodeConfigRevision stores a new config revision, numbered after the
+// node's latest one. Revisions are never updated once written.
+func SaveNodeConfigRevision(revision models.NodeConfigRevision) (models.NodeConfigRevision, error) {
+	config, err := json.Marshal(revision.Config)
+	if err != nil {
+		return models.NodeConfigRevision{}, fmt.Errorf("failed to marshal node config: %v", err)
+	}
+
+	// What was asked for is stored alongside the resulting config
+	var deployRequest, delta []byte
+	if revision.DeployRequest != nil {
+		if deployRequest, err = json.Marshal(revision.DeployRequest); err != nil {
+			return models.NodeConfigRevision{}, fmt.Errorf("failed to marshal deploy request: %v", err)
+		}
+	}
+	if revision.Delta != nil {
+		if delta, err = json.Marshal(revision.Delta); err != nil {
+			return models.NodeConfigRevision{}, fmt.Errorf("failed to marshal config delta: %v", err)
+		}
+	}
+
+	err = db.DB.QueryRow(context.Background(), `
+        INSERT INTO node_config_revisions (
+            id, node_id, user_id, revision, config, systemd_unit, client_config,
+            client_version, reason, status, message, deploy_request, delta,
+            rollback_of, created_at
+        ) VALUES (
+            $1, $2, $3,
+            (SELECT COALESCE(MAX(revision), 0) + 1 FROM node_config_revisions WHERE node_id = $2),
+            $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14
+        )
+        RETURNING revision
+    `, revision.ID, revision.NodeID, revision.UserID, config, revision.SystemdUnit,
+		revision.ClientConfig, revision.ClientVersion, revision.Reason, revision.Status,
+		revision.Message, deployRequest, delta, revision.RollbackOf,
+		revision.CreatedAt).Scan(&revision.Revision)
+
+	if err != nil {
+		return models.NodeConfigRevision{}, err
+	}
+
+	return revision, nil
+}
+
+// configRevisionColumns are selected in the order scanNodeConfigRevision reads them
+const configRevisionColumns = `id, node_id, user_id, revision, config, systemd_unit, client_config,
+            client_version, reason, status, message, deploy_request, delta, rollback_of, created_at`
+
+// scanNodeConfigRevision reads a single revision row
+func scanNodeConfigRevision(row pgx.Row) (models.NodeConfigRevision, error) {
+	var revision models.NodeConfigRevision
+	var config, deployRequest, delta []byte
+
+	err := row.Scan(
+		&revision.ID, &revision.NodeID, &revision.UserID, &revision.Revision, &config,
+		&revision.SystemdUnit, &revision.ClientConfig, &revision.ClientVersion,
+		&revision.Reason, &revision.Status, &revision.Message, &deployRequest, &delta,
+		&revision.RollbackOf, &revision.CreatedAt,
+	)
+	if err != nil {
+		return models.NodeConfigRevision{}, err
+	}
+
+	if err := json.Unmarshal(config, &revision.Config); err != nil {
+		return models.NodeConfigRevision{}, fmt.Errorf("failed to unmarshal node config: %v", err)
+	}
+	if deployRequest != nil {
+		revision.DeployRequest = &models.NodeDeployRequest{}
+		if err := json.Unmarshal(deployRequest, revision.DeployRequest); err != nil {
+			return models.NodeConfigRevision{}, fmt.Errorf("failed to unmarshal deploy request: %v", err)
+		}
+	}
+	if delta != nil {
+		revision.Delta = &models.NodeConfigDelta{}
+		if err := json.Unmarshal(delta, revision.Delta); err != nil {
+			return models.NodeConfigRevision{}, fmt.Errorf("failed to unmarshal config delta: %v", err)
+		}
+	}
+
+	return revision, nil
+}
+
+// GetLatestAppliedConfigRevision retrieves the configuration a node currently runs
+func GetLatestAppliedConfigRevision(nodeID string) (models.NodeConfigRevision, error) {
+	revision, err := scanNodeConfigRevision(db.DB.QueryRow(context.Background(), `
+        SELECT `+configRevisionColumns+`
+        FROM node_config_revisions
+        WHERE node_id = $1 AND status = 'applied'
+        ORDER BY revision DESC
+        LIMIT 1
+    `, nodeID))
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return models.NodeConfigRevision{}, nil
+		}
+		return models.NodeConfigRevision{}, err
+	}
+
+	return revision, nil
+}
+
+// ListNodeConfigRevisions retrieves every config revision of a node, newest first
+func ListNodeConfigRevisions(nodeID, userID string) ([]models.NodeConfigRevision, error) {
+	rows, err := db.DB.Query(context.Background(), `
+        SELECT `+configRevisionColumns+`
+        FROM node_config_revisions
+        WHERE node_id = $1 AND user_id = $2
+        ORDER BY revision DESC
+    `, nodeID, userID)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []models.NodeConfigRevision
+	for rows.Next() {
+		revision, err := scanNodeConfigRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, rows.Err()
+}
+
+// GetNodeConfigRevision retrieves a single revision of a node by number
+func GetNodeConfigRevision(nodeID, userID string, number int) (models.NodeConfigRevision, error) {
+	revision, err := scanNodeConfigRevision(db.DB.QueryRow(context.Background(), `
+        SELECT `+configRevisionColumns+`
+        FROM node_config_revisions
+        WHERE node_id = $1 AND user_id = $2 AND revision = $3
+    `, nodeID, userID, number))
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return models.NodeConfigRevision{}, nil
+		}
+		return models.NodeConfigRevision{}, err
+	}
+
+	return revision, nil
+}
@@ -77,6 +77,18 @@ func GetIntegrationByUserAndProvider(userID, provider string) (models.Integratio
 			return models.Integration{}, fmt.Errorf("failed to unmarshal AWS data: %v", err)
 		}
 		integration.Data = awsData
+	case "GCP":
+		var gcpData models.GCPIntegrationData
+		if err := json.Unmarshal(data, &gcpData); err != nil {
+			return models.Integration{}, fmt.Errorf("failed to unmarshal GCP data: %v", err)
+		}
+		integration.Data = gcpData
+	case "BareMetal":
+		var bareMetalData models.BareMetalIntegrationData
+		if err := json.Unmarshal(data, &bareMetalData); err != nil {
+			return models.Integration{}, fmt.Errorf("failed to unmarshal bare-metal data: %v", err)
+		}
+		integration.Data = bareMetalData
 	}
 
 	integration.CreatedAt = createdAt
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/0saurabh0/NodeEase/db"
+	"github.com/0saurabh0/NodeEase/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// SaveNodeLogBundle stores a log bundle uploaded by a node
+func SaveNodeLogBundle(bundle models.NodeLogBundle, data []byte) error {
+	_, err := db.DB.Exec(context.Background(), `
+        INSERT INTO node_log_bundles (id, node_id, reason, size_bytes, data, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+    `, bundle.ID, bundle.NodeID, bundle.Reason, bundle.SizeBytes, data, bundle.CreatedAt)
+
+	return err
+}
+
+// GetNodeLogBundles lists the log bundles of a node, newest first, without their data
+func GetNodeLogBundles(nodeID string) ([]models.NodeLogBundle, error) {
+	rows, err := db.DB.Query(context.Background(), `
+        SELECT id, node_id, reason, size_bytes, created_at
+        FROM node_log_bundles
+        WHERE node_id = $1
+        ORDER BY created_at DESC
+    `, nodeID)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bundles []models.NodeLogBundle
+	for rows.Next() {
+		var bundle models.NodeLogBundle
+		if err := rows.Scan(&bundle.ID, &bundle.NodeID, &bundle.Reason, &bundle.SizeBytes, &bundle.CreatedAt); err != nil {
+			return nil, err
+		}
+		bundles = append(bundles, bundle)
+	}
+
+	return bundles, rows.Err()
+}
+
+// GetNodeLogBundle retrieves a log bundle of a node with its data. The bundle
+// ID is empty if there is no such bundle.
+func GetNodeLogBundle(bundleID, nodeID string) (models.NodeLogBundle, []byte, error) {
+	var bundle models.NodeLogBundle
+	var data []byte
+	err := db.DB.QueryRow(context.Background(), `
+        SELECT id, node_id, reason, size_bytes, data, created_at
+        FROM node_log_bundles
+        WHERE id = $1 AND node_id = $2
+    `, bundleID, nodeID).Scan(&bundle.ID, &bundle.NodeID, &bundle.Reason, &bundle.SizeBytes, &data, &bundle.CreatedAt)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return models.NodeLogBundle{}, nil, nil
+		}
+		return models.NodeLogBundle{}, nil, err
+	}
+
+	return bundle, data, nil
+}
+
+// PruneNodeLogBundles deletes all but the newest keep log bundles of a node
+func PruneNodeLogBundles(nodeID string, keep int) error {
+	_, err := db.DB.Exec(context.Background(), `
+        DELETE FROM node_log_bundles
+        WHERE node_id = $1 AND id NOT IN (
+            SELECT id FROM node_log_bundles
+            WHERE node_id = $1
+            ORDER BY created_at DESC
+            LIMIT $2
+        )
+    `, nodeID, keep)
+
+	return err
+}
+
+// DeleteLogBundlesBefore deletes log bundles uploaded before cutoff and
+// returns how many were deleted
+func DeleteLogBundlesBefore(cutoff time.Time) (int64, error) {
+	tag, err := db.DB.Exec(context.Background(),
+		"DELETE FROM node_log_bundles WHERE created_at < $1",
+		cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -38,12 +38,23 @@ func SaveNode(node models.Node) error {
                 rpc_endpoint = $12,
                 ssh_private_key = $13,
                 deploy_token = $14,
-                updated_at = $15
-            WHERE id = $16
+                ledger_volume_id = $15,
+                accounts_volume_id = $16,
+                ephemeral_storage = $17,
+                client = $18,
+                client_version = $19,
+                history_length = $20,
+                ws_endpoint = $21,
+                stalled_step = $22,
+                deploy_attempt = $23,
+                updated_at = $24
+            WHERE id = $25
         `, node.Name, node.Provider, node.Region, node.InstanceType,
 			node.InstanceID, node.NodeType, node.NetworkType, node.Status,
 			node.StatusDetail, node.IPAddress, node.DiskSize, node.RpcEndpoint,
-			node.SshPrivateKey, node.DeployToken, node.UpdatedAt, node.ID)
+			node.SshPrivateKey, node.DeployToken, node.LedgerVolumeID, node.AccountsVolumeID,
+			node.EphemeralStorage, node.Client, node.ClientVersion, node.HistoryLength, node.WsEndpoint,
+			node.StalledStep, node.DeployAttempt, node.UpdatedAt, node.ID)
 	} else {
 		// Create new node
 		_, err = db.DB.Exec(context.Background(), `
@@ -51,12 +62,16 @@ func SaveNode(node models.Node) error {
                 id, user_id, name, provider, region, instance_type, 
                 instance_id, node_type, network_type, status, status_detail,
                 ip_address, disk_size, rpc_endpoint, ssh_private_key,
-                deploy_token, created_at, updated_at
-            ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+                deploy_token, ledger_volume_id, accounts_volume_id, ephemeral_storage,
+                client, client_version, history_length, ws_endpoint, stalled_step, deploy_attempt,
+                created_at, updated_at
+            ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27)
         `, node.ID, node.UserID, node.Name, node.Provider, node.Region, node.InstanceType,
 			node.InstanceID, node.NodeType, node.NetworkType, node.Status, node.StatusDetail,
 			node.IPAddress, node.DiskSize, node.RpcEndpoint, node.SshPrivateKey,
-			node.DeployToken, node.CreatedAt, node.UpdatedAt)
+			node.DeployToken, node.LedgerVolumeID, node.AccountsVolumeID, node.EphemeralStorage,
+			node.Client, node.ClientVersion, node.HistoryLength, node.WsEndpoint, node.StalledStep,
+			node.DeployAttempt, node.CreatedAt, node.UpdatedAt)
 	}
 
 	return err
@@ -67,7 +82,8 @@ func GetNodesByUserID(userID string) ([]models.Node, error) {
 	rows, err := db.DB.Query(context.Background(), `
         SELECT id, user_id, name, provider, region, instance_type, instance_id, 
             node_type, network_type, status, status_detail, ip_address, 
-            disk_size, rpc_endpoint, created_at, updated_at
+            disk_size, rpc_endpoint, ledger_volume_id, accounts_volume_id,
+            ephemeral_storage, client, client_version, history_length, ws_endpoint, stalled_step, deploy_attempt, created_at, updated_at
         FROM nodes
         WHERE user_id = $1
         ORDER BY created_at DESC
@@ -85,7 +101,9 @@ func GetNodesByUserID(userID string) ([]models.Node, error) {
 			&node.ID, &node.UserID, &node.Name, &node.Provider, &node.Region,
 			&node.InstanceType, &node.InstanceID, &node.NodeType, &node.NetworkType,
 			&node.Status, &node.StatusDetail, &node.IPAddress, &node.DiskSize,
-			&node.RpcEndpoint, &node.CreatedAt, &node.UpdatedAt,
+			&node.RpcEndpoint, &node.LedgerVolumeID, &node.AccountsVolumeID,
+			&node.EphemeralStorage, &node.Client, &node.ClientVersion, &node.HistoryLength,
+			&node.WsEndpoint, &node.StalledStep, &node.DeployAttempt, &node.CreatedAt, &node.UpdatedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -103,14 +121,19 @@ func GetNodeByID(nodeID, userID string) (models.Node, error) {
 	err := db.DB.QueryRow(context.Background(), `
         SELECT id, user_id, name, provider, region, instance_type, instance_id, 
             node_type, network_type, status, status_detail, ip_address, 
-            disk_size, rpc_endpoint, ssh_private_key, deploy_token, created_at, updated_at
+            disk_size, rpc_endpoint, ssh_private_key, deploy_token,
+            ledger_volume_id, accounts_volume_id, ephemeral_storage, client, client_version,
+            history_length, ws_endpoint, stalled_step, deploy_attempt, created_at, updated_at
         FROM nodes
         WHERE id = $1 AND user_id = $2
     `, nodeID, userID).Scan(
 		&node.ID, &node.UserID, &node.Name, &node.Provider, &node.Region,
 		&node.InstanceType, &node.InstanceID, &node.NodeType, &node.NetworkType,
 		&node.Status, &node.StatusDetail, &node.IPAddress, &node.DiskSize,
-		&node.RpcEndpoint, &node.SshPrivateKey, &node.DeployToken, &node.CreatedAt, &node.UpdatedAt,
+		&node.RpcEndpoint, &node.SshPrivateKey, &node.DeployToken,
+		&node.LedgerVolumeID, &node.AccountsVolumeID, &node.EphemeralStorage,
+		&node.Client, &node.ClientVersion, &node.HistoryLength, &node.WsEndpoint,
+		&node.StalledStep, &node.DeployAttempt, &node.CreatedAt, &node.UpdatedAt,
 	)
 
 	if err != nil {
@@ -131,14 +154,19 @@ func GetNodeByIDInternal(nodeID string) (models.Node, error) {
 	err := db.DB.QueryRow(context.Background(), `
         SELECT id, user_id, name, provider, region, instance_type, instance_id, 
             node_type, network_type, status, status_detail, ip_address, 
-            disk_size, rpc_endpoint, ssh_private_key, deploy_token, created_at, updated_at
+            disk_size, rpc_endpoint, ssh_private_key, deploy_token,
+            ledger_volume_id, accounts_volume_id, ephemeral_storage, client, client_version,
+            history_length, ws_endpoint, stalled_step, deploy_attempt, created_at, updated_at
         FROM nodes
         WHERE id = $1
     `, nodeID).Scan(
 		&node.ID, &node.UserID, &node.Name, &node.Provider, &node.Region,
 		&node.InstanceType, &node.InstanceID, &node.NodeType, &node.NetworkType,
 		&node.Status, &node.StatusDetail, &node.IPAddress, &node.DiskSize,
-		&node.RpcEndpoint, &node.SshPrivateKey, &node.DeployToken, &node.CreatedAt, &node.UpdatedAt,
+		&node.RpcEndpoint, &node.SshPrivateKey, &node.DeployToken,
+		&node.LedgerVolumeID, &node.AccountsVolumeID, &node.EphemeralStorage,
+		&node.Client, &node.ClientVersion, &node.HistoryLength, &node.WsEndpoint,
+		&node.StalledStep, &node.DeployAttempt, &node.CreatedAt, &node.UpdatedAt,
 	)
 
 	if err != nil {
@@ -151,6 +179,23 @@ func GetNodeByIDInternal(nodeID string) (models.Node, error) {
 	return node, nil
 }
 
+// GetNodeSSHHostKey returns the SSH host key pinned for a node, if any
+func GetNodeSSHHostKey(nodeID string) (string, error) {
+	var hostKey string
+	err := db.DB.QueryRow(context.Background(),
+		"SELECT ssh_host_key FROM nodes WHERE id = $1",
+		nodeID).Scan(&hostKey)
+	return hostKey, err
+}
+
+// SaveNodeSSHHostKey pins the SSH host key for a node
+func SaveNodeSSHHostKey(nodeID, hostKey string) error {
+	_, err := db.DB.Exec(context.Background(),
+		"UPDATE nodes SET ssh_host_key = $1 WHERE id = $2",
+		hostKey, nodeID)
+	return err
+}
+
 // DeleteNode deletes a node record
 func DeleteNode(nodeID, userID string) error {
 	_, err := db.DB.Exec(context.Background(), `
@@ -163,17 +208,44 @@ func DeleteNode(nodeID, userID string) error {
 // AddNodeDeploymentLog adds a log entry for a node
 func AddNodeDeploymentLog(nodeID string, log models.NodeDeploymentLog) error {
 	_, err := db.DB.Exec(context.Background(), `
-        INSERT INTO node_deployment_logs (node_id, timestamp, step, message, progress, created_at)
-        VALUES ($1, $2, $3, $4, $5, NOW())
+        INSERT INTO node_deployment_logs (node_id, timestamp, step, message, progress, attempt, created_at)
+        VALUES ($1, $2, $3, $4, $5, COALESCE((SELECT deploy_attempt FROM nodes WHERE id = $1), 1), NOW())
     `, nodeID, log.Timestamp, log.Step, log.Message, log.Progress)
 
 	return err
 }
 
+// AddSequencedNodeDeploymentLog adds a log entry for a sequenced status
+// update, marked stale if a newer update was already applied. It reports
+// false if the sequence number was already recorded.
+func AddSequencedNodeDeploymentLog(nodeID string, log models.NodeDeploymentLog, sequence int64, stale bool) (bool, error) {
+	tag, err := db.DB.Exec(context.Background(), `
+        INSERT INTO node_deployment_logs (node_id, timestamp, step, message, progress, sequence, stale, attempt, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, COALESCE((SELECT deploy_attempt FROM nodes WHERE id = $1), 1), NOW())
+        ON CONFLICT (node_id, sequence) WHERE sequence > 0 DO NOTHING
+    `, nodeID, log.Timestamp, log.Step, log.Message, log.Progress, sequence, stale)
+
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+// GetLatestStatusSequence returns the highest status update sequence number
+// recorded for a node, or 0 if it never sent a sequenced update
+func GetLatestStatusSequence(nodeID string) (int64, error) {
+	var sequence int64
+	err := db.DB.QueryRow(context.Background(),
+		"SELECT COALESCE(MAX(sequence), 0) FROM node_deployment_logs WHERE node_id = $1",
+		nodeID).Scan(&sequence)
+	return sequence, err
+}
+
 // GetDeploymentLogsForNode retrieves all deployment logs for a node
 func GetDeploymentLogsForNode(nodeID string) ([]models.NodeDeploymentLog, error) {
 	rows, err := db.DB.Query(context.Background(), `
-        SELECT timestamp, step, message, progress
+        SELECT timestamp, step, message, progress, attempt
         FROM node_deployment_logs
         WHERE node_id = $1
         ORDER BY timestamp ASC
@@ -187,7 +259,7 @@ func GetDeploymentLogsForNode(nodeID string) ([]models.NodeDeploymentLog, error)
 	var logs []models.NodeDeploymentLog
 	for rows.Next() {
 		var log models.NodeDeploymentLog
-		if err := rows.Scan(&log.Timestamp, &log.Step, &log.Message, &log.Progress); err != nil {
+		if err := rows.Scan(&log.Timestamp, &log.Step, &log.Message, &log.Progress, &log.Attempt); err != nil {
 			return nil, err
 		}
 		logs = append(logs, log)
@@ -195,3 +267,58 @@ func GetDeploymentLogsForNode(nodeID string) ([]models.NodeDeploymentLog, error)
 
 	return logs, nil
 }
+
+// GetDeployingNodeProgress returns the latest progress log of every node that
+// is deploying, keyed by node ID. Nodes without logs yet report a "provision"
+// step at their creation time. Console output captured for diagnosis isn't
+// progress and is skipped.
+//
+// The VM's sequenced updates are ordered by sequence number and stale ones
+// are skipped, since a late retry is logged after newer progress. The newest
+// of them is compared with the newest unsequenced log, e.g. from the watchdog.
+func GetDeployingNodeProgress() (map[string]models.NodeDeploymentLog, error) {
+	rows, err := db.DB.Query(context.Background(), `
+        SELECT n.id,
+            COALESCE(l.timestamp, n.created_at),
+            COALESCE(l.step, 'provision'),
+            COALESCE(l.message, ''),
+            COALESCE(l.progress, 0)
+        FROM nodes n
+        LEFT JOIN LATERAL (
+            SELECT timestamp, step, message, progress
+            FROM (
+                (SELECT timestamp, step, message, progress, sequence
+                FROM node_deployment_logs
+                WHERE node_id = n.id AND step <> 'console_output' AND sequence > 0 AND NOT stale
+                ORDER BY sequence DESC
+                LIMIT 1)
+                UNION ALL
+                (SELECT timestamp, step, message, progress, sequence
+                FROM node_deployment_logs
+                WHERE node_id = n.id AND step <> 'console_output' AND sequence = 0
+                ORDER BY timestamp DESC
+                LIMIT 1)
+            ) latest
+            ORDER BY timestamp DESC, sequence DESC
+            LIMIT 1
+        ) l ON TRUE
+        WHERE n.status = 'deploying'
+    `)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	progress := map[string]models.NodeDeploymentLog{}
+	for rows.Next() {
+		var nodeID string
+		var log models.NodeDeploymentLog
+		if err := rows.Scan(&nodeID, &log.Timestamp, &log.Step, &log.Message, &log.Progress); err != nil {
+			return nil, err
+		}
+		progress[nodeID] = log
+	}
+
+	return progress, rows.Err()
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/0saurabh0/NodeEase/db"
+	"github.com/jackc/pgx/v5"
+)
+
+// SaveNodeSecret stores an encrypted secret for a node, replacing any
+// previous value with the same name
+func SaveNodeSecret(nodeID, name, encryptedValue string) error {
+	_, err := db.DB.Exec(context.Background(), `
+        INSERT INTO node_secrets (node_id, name, value, created_at)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (node_id, name) DO UPDATE
+        SET value = EXCLUDED.value,
+            created_at = EXCLUDED.created_at
+    `, nodeID, name, encryptedValue, time.Now())
+
+	return err
+}
+
+// GetNodeSecret retrieves an encrypted node secret, or "" if there is none
+func GetNodeSecret(nodeID, name string) (string, error) {
+	var value string
+	err := db.DB.QueryRow(context.Background(), `
+        SELECT value FROM node_secrets WHERE node_id = $1 AND name = $2
+    `, nodeID, name).Scan(&value)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return value, nil
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/0saurabh0/NodeEase/db"
+	"github.com/0saurabh0/NodeEase/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// SaveTerminalSession records the start of a browser terminal session
+func SaveTerminalSession(session models.NodeTerminalSession) error {
+	_, err := db.DB.Exec(context.Background(), `
+        INSERT INTO node_terminal_sessions (
+            id, node_id, node_owner_id, user_id, role, remote_addr, started_at, record_transcript
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+    `, session.ID, session.NodeID, session.NodeOwnerID, session.UserID, session.Role, session.RemoteAddr,
+		session.StartedAt, session.Transcript)
+
+	return err
+}
+
+// FinishTerminalSession records how a browser terminal session ended, with
+// its transcript if one was recorded
+func FinishTerminalSession(session models.NodeTerminalSession, transcript []byte) error {
+	_, err := db.DB.Exec(context.Background(), `
+        UPDATE node_terminal_sessions
+        SET ended_at = $1,
+            end_reason = $2,
+            bytes_in = $3,
+            bytes_out = $4,
+            transcript = $5,
+            transcript_truncated = $6
+        WHERE id = $7
+    `, session.EndedAt, session.EndReason, session.BytesIn, session.BytesOut,
+		transcript, session.TranscriptTruncated, session.ID)
+
+	return err
+}
+
+// GetTerminalSessions retrieves the most recent terminal sessions on a node,
+// newest first, without their transcripts. A non-empty ownerID only matches
+// sessions on the owner's node or opened by them.
+func GetTerminalSessions(nodeID, ownerID string, limit int) ([]models.NodeTerminalSession, error) {
+	rows, err := db.DB.Query(context.Background(), `
+        SELECT id, node_id, node_owner_id, user_id, role, remote_addr, started_at, ended_at, end_reason,
+            bytes_in, bytes_out, record_transcript, transcript_truncated
+        FROM node_terminal_sessions
+        WHERE node_id = $1 AND ($2 = '' OR node_owner_id = $2 OR user_id = $2)
+        ORDER BY started_at DESC
+        LIMIT $3
+    `, nodeID, ownerID, limit)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []models.NodeTerminalSession
+	for rows.Next() {
+		var session models.NodeTerminalSession
+		err := rows.Scan(
+			&session.ID, &session.NodeID, &session.NodeOwnerID, &session.UserID, &session.Role, &session.RemoteAddr,
+			&session.StartedAt, &session.EndedAt, &session.EndReason, &session.BytesIn,
+			&session.BytesOut, &session.Transcript, &session.TranscriptTruncated,
+		)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// GetTerminalTranscript retrieves the transcript of a terminal session on a
+// node, scoped to an owner like GetTerminalSessions. found is false if there
+// is no such session.
+func GetTerminalTranscript(sessionID, nodeID, ownerID string) (transcript []byte, found bool, err error) {
+	err = db.DB.QueryRow(context.Background(), `
+        SELECT transcript
+        FROM node_terminal_sessions
+        WHERE id = $1 AND node_id = $2 AND ($3 = '' OR node_owner_id = $3 OR user_id = $3)
+    `, sessionID, nodeID, ownerID).Scan(&transcript)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	return transcript, true, nil
+}
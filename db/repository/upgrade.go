@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/0saurabh0/NodeEase/db"
+	"github.com/0saurabh0/NodeEase/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// SaveNodeUpgrade creates or updates a node upgrade record
+func SaveNodeUpgrade(upgrade models.NodeUpgrade) error {
+	_, err := db.DB.Exec(context.Background(), `
+        INSERT INTO node_upgrades (
+            id, node_id, user_id, rollout_id, client, from_version, to_version,
+            status, message, output, created_at, updated_at
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+        ON CONFLICT (id) DO UPDATE
+        SET status = EXCLUDED.status,
+            message = EXCLUDED.message,
+            output = EXCLUDED.output,
+            updated_at = EXCLUDED.updated_at
+    `, upgrade.ID, upgrade.NodeID, upgrade.UserID, upgrade.RolloutID, upgrade.Client,
+		upgrade.FromVersion, upgrade.ToVersion, upgrade.Status, upgrade.Message,
+		upgrade.Output, upgrade.CreatedAt, upgrade.UpdatedAt)
+
+	return err
+}
+
+// scanNodeUpgrades reads node upgrade rows
+func scanNodeUpgrades(rows pgx.Rows) ([]models.NodeUpgrade, error) {
+	defer rows.Close()
+
+	var upgrades []models.NodeUpgrade
+	for rows.Next() {
+		var upgrade models.NodeUpgrade
+		err := rows.Scan(
+			&upgrade.ID, &upgrade.NodeID, &upgrade.UserID, &upgrade.RolloutID,
+			&upgrade.Client, &upgrade.FromVersion, &upgrade.ToVersion, &upgrade.Status,
+			&upgrade.Message, &upgrade.Output, &upgrade.CreatedAt, &upgrade.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		upgrades = append(upgrades, upgrade)
+	}
+
+	return upgrades, rows.Err()
+}
+
+// GetNodeUpgrades retrieves the upgrade history of a node, newest first
+func GetNodeUpgrades(nodeID, userID string) ([]models.NodeUpgrade, error) {
+	rows, err := db.DB.Query(context.Background(), `
+        SELECT id, node_id, user_id, rollout_id, client, from_version, to_version,
+            status, message, output, created_at, updated_at
+        FROM node_upgrades
+        WHERE node_id = $1 AND user_id = $2
+        ORDER BY created_at DESC
+    `, nodeID, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return scanNodeUpgrades(rows)
+}
+
+// GetRolloutUpgrades retrieves the node upgrades belonging to a rollout
+func GetRolloutUpgrades(rolloutID string) ([]models.NodeUpgrade, error) {
+	rows, err := db.DB.Query(context.Background(), `
+        SELECT id, node_id, user_id, rollout_id, client, from_version, to_version,
+            status, message, output, created_at, updated_at
+        FROM node_upgrades
+        WHERE rollout_id = $1
+        ORDER BY created_at ASC
+    `, rolloutID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return scanNodeUpgrades(rows)
+}
+
+// CreateNodeUpgrade stores a new pending upgrade. It returns false if the
+// node already has an upgrade pending or running.
+func CreateNodeUpgrade(upgrade models.NodeUpgrade) (bool, error) {
+	tag, err := db.DB.Exec(context.Background(), `
+        INSERT INTO node_upgrades (
+            id, node_id, user_id, rollout_id, client, from_version, to_version,
+            status, message, output, created_at, updated_at
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+        ON CONFLICT (node_id) WHERE status IN ('pending', 'running') DO NOTHING
+    `, upgrade.ID, upgrade.NodeID, upgrade.UserID, upgrade.RolloutID, upgrade.Client,
+		upgrade.FromVersion, upgrade.ToVersion, upgrade.Status, upgrade.Message,
+		upgrade.Output, upgrade.CreatedAt, upgrade.UpdatedAt)
+
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+// SaveUpgradeRollout creates or updates a rollout record
+func SaveUpgradeRollout(rollout models.UpgradeRollout) error {
+	_, err := db.DB.Exec(context.Background(), `
+        INSERT INTO upgrade_rollouts (
+            id, user_id, target_version, batch_size, status, message, created_at, updated_at
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        ON CONFLICT (id) DO UPDATE
+        SET status = EXCLUDED.status,
+            message = EXCLUDED.message,
+            updated_at = EXCLUDED.updated_at
+    `, rollout.ID, rollout.UserID, rollout.TargetVersion, rollout.BatchSize,
+		rollout.Status, rollout.Message, rollout.CreatedAt, rollout.UpdatedAt)
+
+	return err
+}
+
+// GetUpgradeRollout retrieves a rollout by ID
+func GetUpgradeRollout(rolloutID, userID string) (models.UpgradeRollout, error) {
+	var rollout models.UpgradeRollout
+
+	err := db.DB.QueryRow(context.Background(), `
+        SELECT id, user_id, target_version, batch_size, status, message, created_at, updated_at
+        FROM upgrade_rollouts
+        WHERE id = $1 AND user_id = $2
+    `, rolloutID, userID).Scan(
+		&rollout.ID, &rollout.UserID, &rollout.TargetVersion, &rollout.BatchSize,
+		&rollout.Status, &rollout.Message, &rollout.CreatedAt, &rollout.UpdatedAt,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return models.UpgradeRollout{}, nil
+		}
+		return models.UpgradeRollout{}, err
+	}
+
+	return rollout, nil
+}
@@ -23,20 +23,21 @@ const RECOMMENDED_CONFIGS = {
   }
 };
 
-// AWS instance types with descriptions
-const INSTANCE_TYPES = {
-  'r7a.16xlarge': { vCPU: 64, memory: '512 GB', description: 'Memory optimized', recommended: 'Base RPC' },
-  'r7a.24xlarge': { vCPU: 96, memory: '768 GB', description: 'Memory optimized', recommended: 'Extended RPC' },
-  'i7ie.16xlarge': { vCPU: 64, memory: '576 GB', description: 'Memory optimized', recommended: 'Base RPC (Alternative)' },
-  'i7ie.24xlarge': { vCPU: 96, memory: '768 GB', description: 'Memory optimized', recommended: 'Extended RPC (Alternative)' },
-};
+// Instance types and prices come from the catalog API
+interface InstanceTypeInfo {
+  instanceType: string;
+  description: string;
+  vCPU: number;
+  memoryGiB: number;
+  monthlyPrice?: number;
+  estimated?: boolean; // No price listed for the region, the default region's is shown
+  available?: boolean;
+}
 
-const INSTANCE_COSTS = {
-  'r7a.16xlarge': 250,
-  'r7a.24xlarge': 350,
-  'i7ie.16xlarge': 300,
-  'i7ie.24xlarge': 400,
-};
+interface RegionInfo {
+  code: string;
+  name: string;
+}
 
 interface NodeDeploymentViewProps {
   navigateToIntegrate?: () => void;
@@ -49,6 +50,8 @@ const NodeDeploymentView: React.FC<NodeDeploymentViewProps> = ({ navigateToInteg
   const [loading, setLoading] = useState(false);
   const [deploymentSuccess, setDeploymentSuccess] = useState(false);
   const [estimatedCost, setEstimatedCost] = useState<number>(0);
+  const [instanceTypes, setInstanceTypes] = useState<InstanceTypeInfo[]>([]);
+  const [regions, setRegions] = useState<RegionInfo[]>([]);
   const [activeNodeId, setActiveNodeId] = useState<string | null>(null);
   const [showProgressView, setShowProgressView] = useState(false);
   const [notification, setNotification] = useState<{
@@ -104,10 +107,42 @@ const NodeDeploymentView: React.FC<NodeDeploymentViewProps> = ({ navigateToInteg
     checkAWSStatus();
   }, []);
 
+  // Load the regions nodes can be deployed to
+  useEffect(() => {
+    const fetchRegions = async () => {
+      try {
+        const response = await api.get('/api/catalog/regions');
+        setRegions(response.data || []);
+      } catch (error) {
+        console.error('Error fetching regions:', error);
+      }
+    };
+
+    fetchRegions();
+  }, []);
+
+  // Load instance types with prices and availability for the selected region
+  useEffect(() => {
+    const fetchCatalog = async () => {
+      try {
+        const response = await api.get('/api/catalog/instance-types', {
+          params: formData.region ? { region: formData.region } : {},
+        });
+        setInstanceTypes(response.data?.instanceTypes || []);
+      } catch (error) {
+        console.error('Error fetching instance catalog:', error);
+      }
+    };
+
+    fetchCatalog();
+  }, [formData.region]);
+
+  const selectedInstance = instanceTypes.find(info => info.instanceType === formData.instanceType);
+
   // Calculate estimated cost - REMOVED snapshots cost
   useEffect(() => {
     const calculateCost = () => {
-      const instanceCost = INSTANCE_COSTS[formData.instanceType as keyof typeof INSTANCE_COSTS] || 0;
+      const instanceCost = selectedInstance?.monthlyPrice || 0;
       const storageCost = formData.diskSize * 0.1;
       const dataCost = 80;
       
@@ -115,7 +150,7 @@ const NodeDeploymentView: React.FC<NodeDeploymentViewProps> = ({ navigateToInteg
     };
     
     setEstimatedCost(calculateCost());
-  }, [formData.instanceType, formData.diskSize]); // REMOVED snapshots dependency
+  }, [selectedInstance, formData.diskSize]); // REMOVED snapshots dependency
 
   // Handle changes to RPC type
   const handleRPCTypeChange = (type: string) => {
@@ -197,11 +232,13 @@ const NodeDeploymentView: React.FC<NodeDeploymentViewProps> = ({ navigateToInteg
       setDeployedNodes(prev => [newNode, ...prev]);
       
       // Show deployment started notification
+      // Mention any retention warning, e.g. full history without long-term storage
+      const started = `Your ${formData.rpcType === 'base' ? 'Base' : 'Extended'} RPC node is now being deployed. This process may take 15-20 minutes to complete.`;
       setNotification({
         show: true,
-        type: 'info',
+        type: response.data.warning ? 'warning' : 'info',
         title: 'Deployment Started',
-        message: `Your ${formData.rpcType === 'base' ? 'Base' : 'Extended'} RPC node is now being deployed. This process may take 15-20 minutes to complete.`
+        message: response.data.warning ? `${started} Note: ${response.data.warning}.` : started
       });
     } catch (err: unknown) {
       const error = err as { response?: { data?: { error?: string } }; message?: string };
@@ -563,7 +600,7 @@ const NodeDeploymentView: React.FC<NodeDeploymentViewProps> = ({ navigateToInteg
                         <div>
                           <span className="text-gray-400">Instance Type:</span>
                           <span className="text-white ml-2">
-                            {formData.instanceType} ({INSTANCE_TYPES[formData.instanceType as keyof typeof INSTANCE_TYPES]?.vCPU || '64'} vCPU, {INSTANCE_TYPES[formData.instanceType as keyof typeof INSTANCE_TYPES]?.memory || '512 GB'})
+                            {formData.instanceType}{selectedInstance && ` (${selectedInstance.vCPU} vCPU, ${selectedInstance.memoryGiB} GiB)`}
                           </span>
                         </div>
                         <div>
@@ -597,9 +634,9 @@ const NodeDeploymentView: React.FC<NodeDeploymentViewProps> = ({ navigateToInteg
                           onChange={handleInputChange}
                           className="w-full bg-[#151C2C] border border-[#1E2D4A] rounded-xl px-4 py-3 text-white focus:outline-none focus:border-blue-500"
                         >
-                          {Object.entries(INSTANCE_TYPES).map(([type, details]) => (
-                            <option key={type} value={type}>
-                              {type} - {details.vCPU} vCPU, {details.memory}
+                          {instanceTypes.map(info => (
+                            <option key={info.instanceType} value={info.instanceType} disabled={info.available === false}>
+                              {info.instanceType} - {info.vCPU} vCPU, {info.memoryGiB} GiB{info.available === false ? ' (not offered in this region)' : ''}
                             </option>
                           ))}
                         </select>
@@ -619,13 +656,9 @@ const NodeDeploymentView: React.FC<NodeDeploymentViewProps> = ({ navigateToInteg
                           <option value={awsRegion}>
                             {awsRegion} (From AWS Integration)
                           </option>
-                          <option value="us-east-1">US East (N. Virginia)</option>
-                          <option value="us-east-2">US East (Ohio)</option>
-                          <option value="us-west-1">US West (N. California)</option>
-                          <option value="us-west-2">US West (Oregon)</option>
-                          <option value="eu-west-1">EU (Ireland)</option>
-                          <option value="eu-central-1">EU (Frankfurt)</option>
-                          <option value="ap-northeast-1">Asia Pacific (Tokyo)</option>
+                          {regions.filter(region => region.code !== awsRegion).map(region => (
+                            <option key={region.code} value={region.code}>{region.name}</option>
+                          ))}
                         </select>
                       </div>
                       
@@ -693,7 +726,9 @@ const NodeDeploymentView: React.FC<NodeDeploymentViewProps> = ({ navigateToInteg
                     <div className="flex justify-between">
                       <span className="text-gray-400">EC2 Instance:</span>
                       <span className="text-white font-medium">
-                        ${INSTANCE_COSTS[formData.instanceType as keyof typeof INSTANCE_COSTS]?.toFixed(2) || '250.00'}/month
+                        {selectedInstance?.monthlyPrice
+                          ? `${selectedInstance.estimated ? '~' : ''}$${selectedInstance.monthlyPrice.toFixed(2)}/month`
+                          : 'Price unavailable'}
                       </span>
                     </div>
                     
@@ -727,6 +762,11 @@ const NodeDeploymentView: React.FC<NodeDeploymentViewProps> = ({ navigateToInteg
                       <p className="text-amber-400/70 text-xs mt-3">
                         * These are approximate costs. Consider reserved instances for production deployments to reduce costs.
                       </p>
+                      {selectedInstance?.estimated && (
+                        <p className="text-amber-400/70 text-xs mt-1">
+                          * No listed price for {formData.region}, the instance price is estimated from us-east-1.
+                        </p>
+                      )}
                     </div>
                   </div>
                 </div>
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/0saurabh0/NodeEase/middleware"
+	"github.com/0saurabh0/NodeEase/models"
+	"github.com/0saurabh0/NodeEase/services"
+	"github.com/0saurabh0/NodeEase/utils"
+	"github.com/gorilla/mux"
+	"golang.org/x/net/websocket"
+)
+
+// AgentConnectHandler upgrades a node agent's connection to a WebSocket. The
+// agent authenticates with its node's deployment token as a bearer token.
+func AgentConnectHandler(w http.ResponseWriter, r *http.Request) {
+	// Get node ID from URL
+	vars := mux.Vars(r)
+	nodeID := vars["id"]
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if err := services.AuthenticateAgent(nodeID, token); err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Failed to authenticate agent: "+err.Error())
+		return
+	}
+
+	// Agents aren't browsers, so there is no origin to check
+	server := websocket.Server{
+		Handler: func(ws *websocket.Conn) {
+			services.ServeAgent(nodeID, ws)
+		},
+	}
+	server.ServeHTTP(w, r)
+}
+
+// GetAgentBinaryHandler serves the agent build nodes install while bootstrapping
+func GetAgentBinaryHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	path, err := services.AgentBinaryPath(vars["arch"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeFile(w, r, path)
+}
+
+// GetAgentBinaryChecksumHandler publishes the SHA-256 of an agent build in
+// the format sha256sum -c reads
+func GetAgentBinaryChecksumHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	sum, err := services.AgentBinarySHA256(vars["arch"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "%s  nodeease-agent-linux-%s\n", sum, vars["arch"])
+}
+
+// QueueAgentCommandHandler queues a command for a node's agent
+func QueueAgentCommandHandler(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	// Get node ID from URL
+	vars := mux.Vars(r)
+	nodeID := vars["id"]
+
+	// Parse request body
+	var req models.AgentCommandRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	command, err := services.QueueAgentCommand(nodeID, userID, req)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Failed to queue command: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusAccepted, command)
+}
+
+// ListAgentCommandsHandler lists the recent agent commands of a node
+func ListAgentCommandsHandler(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	// Get node ID from URL
+	vars := mux.Vars(r)
+	nodeID := vars["id"]
+
+	commands, err := services.GetAgentCommands(nodeID, userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get commands: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, commands)
+}
+
+// GetAgentCommandHandler retrieves an agent command and its output
+func GetAgentCommandHandler(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	// Get node and command IDs from URL
+	vars := mux.Vars(r)
+	nodeID := vars["id"]
+	commandID := vars["commandId"]
+
+	command, err := services.GetAgentCommand(commandID, nodeID, userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Failed to get command: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, command)
+}
+
+// GetNodeAgentHandler reports the agent connection and last heartbeat of a node
+func GetNodeAgentHandler(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	// Get node ID from URL
+	vars := mux.Vars(r)
+	nodeID := vars["id"]
+
+	status, err := services.GetNodeAgentStatus(nodeID, userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Failed to get agent status: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, status)
+}
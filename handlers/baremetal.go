@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/0saurabh0/NodeEase/middleware"
+	"github.com/0saurabh0/NodeEase/models"
+	"github.com/0saurabh0/NodeEase/services"
+	"github.com/0saurabh0/NodeEase/utils"
+)
+
+// TestBareMetalConnectionHandler tests a bare-metal API token without saving it
+func TestBareMetalConnectionHandler(w http.ResponseWriter, r *http.Request) {
+	var credentials models.BareMetalCredentials
+	if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := services.TestBareMetalConnection(credentials); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Connection successful"})
+}
+
+// IntegrateBareMetalHandler saves bare-metal integration details for a user
+func IntegrateBareMetalHandler(w http.ResponseWriter, r *http.Request) {
+	var credentials models.BareMetalCredentials
+	if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	// Validate, encrypt and save the API token
+	if err := services.IntegrateBareMetal(userID, credentials); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Failed to save integration: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Bare-metal integration successful",
+		"integration": map[string]string{
+			"provider":  "BareMetal",
+			"projectId": credentials.ProjectID,
+			"status":    "active",
+		},
+	})
+}
+
+// BareMetalStatusHandler retrieves the bare-metal integration status for a user
+func BareMetalStatusHandler(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context (set by auth middleware)
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	integration, err := services.GetBareMetalIntegrationByUserID(userID)
+	if err != nil {
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"integrated": false,
+		})
+		return
+	}
+
+	bareMetalData, ok := integration.Data.(models.BareMetalIntegrationData)
+	if !ok {
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"integrated": false,
+		})
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"integrated": true,
+		"projectId":  bareMetalData.ProjectID,
+	})
+}
+
+// DisconnectBareMetalHandler removes the bare-metal integration for a user
+func DisconnectBareMetalHandler(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context (set by auth middleware)
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	if err := services.DisconnectBareMetal(userID); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to disconnect bare-metal host: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Disconnected from bare-metal host successfully"})
+}
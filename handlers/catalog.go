@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/0saurabh0/NodeEase/middleware"
+	"github.com/0saurabh0/NodeEase/services"
+	"github.com/0saurabh0/NodeEase/utils"
+)
+
+// GetInstanceCatalogHandler lists supported instance types with prices and,
+// when a region is given, their availability in that region
+func GetInstanceCatalogHandler(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	region := r.URL.Query().Get("region")
+
+	// Build the catalog
+	catalog, err := services.GetInstanceCatalog(userID, region)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get instance catalog: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, catalog)
+}
+
+// GetRegionsHandler lists the regions nodes can be deployed to
+func GetRegionsHandler(w http.ResponseWriter, r *http.Request) {
+	utils.RespondWithJSON(w, http.StatusOK, services.GetSupportedRegions())
+}
+
+// GetValidatorClientsHandler lists the validator clients and versions nodes can run
+func GetValidatorClientsHandler(w http.ResponseWriter, r *http.Request) {
+	utils.RespondWithJSON(w, http.StatusOK, services.GetValidatorClients())
+}
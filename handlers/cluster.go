@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/0saurabh0/NodeEase/middleware"
+	"github.com/0saurabh0/NodeEase/models"
+	"github.com/0saurabh0/NodeEase/services"
+	"github.com/0saurabh0/NodeEase/utils"
+	"github.com/gorilla/mux"
+)
+
+// ListClustersHandler lists the clusters the user can deploy to
+func ListClustersHandler(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	clusters, err := services.ListClusters(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get clusters: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, clusters)
+}
+
+// CreateClusterHandler defines a private cluster for the user
+func CreateClusterHandler(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	createCluster(w, r, userID)
+}
+
+// UpdateClusterHandler updates one of the user's private clusters
+func UpdateClusterHandler(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	updateCluster(w, r, userID)
+}
+
+// DeleteClusterHandler deletes one of the user's private clusters
+func DeleteClusterHandler(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	deleteCluster(w, r, userID)
+}
+
+// AdminCreateClusterHandler adds a public cluster to the catalog
+func AdminCreateClusterHandler(w http.ResponseWriter, r *http.Request) {
+	createCluster(w, r, "")
+}
+
+// AdminUpdateClusterHandler updates a public cluster
+func AdminUpdateClusterHandler(w http.ResponseWriter, r *http.Request) {
+	updateCluster(w, r, "")
+}
+
+// AdminDeleteClusterHandler removes a public cluster from the catalog
+func AdminDeleteClusterHandler(w http.ResponseWriter, r *http.Request) {
+	deleteCluster(w, r, "")
+}
+
+// createCluster creates a cluster owned by ownerID, "" for public clusters
+func createCluster(w http.ResponseWriter, r *http.Request, ownerID string) {
+	// Parse request body
+	var cluster models.Cluster
+	if err := json.NewDecoder(r.Body).Decode(&cluster); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	created, err := services.CreateCluster(ownerID, cluster)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Failed to create cluster: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, created)
+}
+
+// updateCluster updates a cluster owned by ownerID, "" for public clusters
+func updateCluster(w http.ResponseWriter, r *http.Request, ownerID string) {
+	// Get cluster ID from URL
+	vars := mux.Vars(r)
+	clusterID := vars["id"]
+
+	// Parse request body
+	var cluster models.Cluster
+	if err := json.NewDecoder(r.Body).Decode(&cluster); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	updated, err := services.UpdateCluster(ownerID, clusterID, cluster)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Failed to update cluster: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, updated)
+}
+
+// deleteCluster deletes a cluster owned by ownerID, "" for public clusters
+func deleteCluster(w http.ResponseWriter, r *http.Request, ownerID string) {
+	// Get cluster ID from URL
+	vars := mux.Vars(r)
+	clusterID := vars["id"]
+
+	if err := services.DeleteCluster(ownerID, clusterID); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Failed to delete cluster: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Cluster deleted successfully"})
+}
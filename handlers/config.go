@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/0saurabh0/NodeEase/middleware"
+	"github.com/0saurabh0/NodeEase/models"
+	"github.com/0saurabh0/NodeEase/services"
+	"github.com/0saurabh0/NodeEase/utils"
+	"github.com/gorilla/mux"
+)
+
+// respondWithConfigRevision reports the outcome of applying a config revision
+func respondWithConfigRevision(w http.ResponseWriter, revision models.NodeConfigRevision) {
+	if revision.Status != "applied" {
+		utils.RespondWithJSON(w, http.StatusBadGateway, map[string]interface{}{
+			"error":    "Failed to apply configuration on the node",
+			"revision": revision,
+		})
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, revision)
+}
+
+// UpdateNodeConfigHandler applies a validator settings change to a running node
+func UpdateNodeConfigHandler(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	// Get node ID from URL
+	vars := mux.Vars(r)
+	nodeID := vars["id"]
+
+	// Parse request body
+	var delta models.NodeConfigDelta
+	if err := json.NewDecoder(r.Body).Decode(&delta); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// Validate and apply the change
+	revision, err := services.ReconfigureNode(nodeID, userID, delta)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Failed to reconfigure node: "+err.Error())
+		return
+	}
+
+	respondWithConfigRevision(w, revision)
+}
+
+// ListNodeConfigRevisionsHandler lists the config revisions of a node
+func ListNodeConfigRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	// Get node ID from URL
+	vars := mux.Vars(r)
+	nodeID := vars["id"]
+
+	revisions, err := services.ListNodeConfigRevisions(nodeID, userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get config revisions: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, revisions)
+}
+
+// GetNodeConfigRevisionHandler returns a single config revision of a node
+func GetNodeConfigRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	// Get node ID and revision from URL
+	vars := mux.Vars(r)
+	nodeID := vars["id"]
+	number, err := strconv.Atoi(vars["revision"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid revision number")
+		return
+	}
+
+	revision, err := services.GetNodeConfigRevision(nodeID, userID, number)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, revision)
+}
+
+// DiffNodeConfigRevisionsHandler compares two config revisions of a node
+func DiffNodeConfigRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	// Get node ID from URL
+	vars := mux.Vars(r)
+	nodeID := vars["id"]
+
+	// Get the revisions to compare from the query
+	from, err := strconv.Atoi(r.URL.Query().Get("from"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid from revision")
+		return
+	}
+	to, err := strconv.Atoi(r.URL.Query().Get("to"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid to revision")
+		return
+	}
+
+	diff, err := services.DiffNodeConfigRevisions(nodeID, userID, from, to)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, diff)
+}
+
+// RollbackNodeConfigHandler re-applies an earlier config revision to a node
+func RollbackNodeConfigHandler(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	// Get node ID from URL
+	vars := mux.Vars(r)
+	nodeID := vars["id"]
+
+	// Parse request body
+	var req models.NodeConfigRollbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	revision, err := services.RollbackNodeConfig(nodeID, userID, req.Revision)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Failed to roll back node config: "+err.Error())
+		return
+	}
+
+	respondWithConfigRevision(w, revision)
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/0saurabh0/NodeEase/middleware"
+	"github.com/0saurabh0/NodeEase/models"
+	"github.com/0saurabh0/NodeEase/services"
+	"github.com/0saurabh0/NodeEase/utils"
+)
+
+// TestGCPConnectionHandler tests a GCP service account key without saving it
+func TestGCPConnectionHandler(w http.ResponseWriter, r *http.Request) {
+	var gcpCredentials models.GCPCredentials
+	if err := json.NewDecoder(r.Body).Decode(&gcpCredentials); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if _, err := services.TestGCPConnection(gcpCredentials); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Connection successful"})
+}
+
+// IntegrateGCPHandler saves GCP integration details for a user
+func IntegrateGCPHandler(w http.ResponseWriter, r *http.Request) {
+	var gcpCredentials models.GCPCredentials
+	if err := json.NewDecoder(r.Body).Decode(&gcpCredentials); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// Get user ID from context (set by auth middleware)
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	// Validate, encrypt and save the service account key
+	gcpData, err := services.IntegrateGCP(userID, gcpCredentials)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Failed to save integration: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "GCP integration successful",
+		"integration": map[string]string{
+			"provider":    "GCP",
+			"projectId":   gcpData.ProjectID,
+			"clientEmail": gcpData.ClientEmail,
+			"zone":        gcpData.Zone,
+			"status":      "active",
+		},
+	})
+}
+
+// GCPStatusHandler retrieves the GCP integration status for a user
+func GCPStatusHandler(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context (set by auth middleware)
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	integration, err := services.GetGCPIntegrationByUserID(userID)
+	if err != nil {
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"integrated": false,
+		})
+		return
+	}
+
+	gcpData, ok := integration.Data.(models.GCPIntegrationData)
+	if !ok {
+		utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"integrated": false,
+		})
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"integrated":  true,
+		"projectId":   gcpData.ProjectID,
+		"clientEmail": gcpData.ClientEmail,
+		"zone":        gcpData.Zone,
+	})
+}
+
+// DisconnectGCPHandler removes the GCP integration for a user
+func DisconnectGCPHandler(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context (set by auth middleware)
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	if err := services.DisconnectGCP(userID); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to disconnect GCP: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Disconnected from GCP successfully"})
+}
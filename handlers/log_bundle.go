@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/0saurabh0/NodeEase/middleware"
+	"github.com/0saurabh0/NodeEase/models"
+	"github.com/0saurabh0/NodeEase/services"
+	"github.com/0saurabh0/NodeEase/utils"
+	"github.com/gorilla/mux"
+)
+
+// UploadNodeLogBundleHandler receives a gzipped tarball of logs from a node.
+// The reason is passed as a query parameter and defaults to failure.
+func UploadNodeLogBundleHandler(w http.ResponseWriter, r *http.Request) {
+	// Get node ID and token from URL
+	vars := mux.Vars(r)
+	nodeID := vars["id"]
+	token := vars["token"]
+
+	reason := r.URL.Query().Get("reason")
+	if reason == "" {
+		reason = models.LogBundleReasonFailure
+	}
+
+	// Authenticate the node before reading up to MaxLogBundleBytes
+	if err := services.AuthenticateNode(nodeID, token); err != nil {
+		if errors.Is(err, services.ErrInvalidDeployToken) {
+			utils.RespondWithError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to authenticate node: "+err.Error())
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, services.MaxLogBundleBytes))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("Log bundle must be at most %d bytes", services.MaxLogBundleBytes))
+		return
+	}
+
+	bundle, err := services.StoreNodeLogBundle(nodeID, reason, data)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Failed to store log bundle: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, bundle)
+}
+
+// ListNodeLogBundlesHandler lists the log bundles a node uploaded
+func ListNodeLogBundlesHandler(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	// Get node ID from URL
+	vars := mux.Vars(r)
+	nodeID := vars["id"]
+
+	bundles, err := services.ListNodeLogBundles(nodeID, userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to list log bundles: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, bundles)
+}
+
+// RequestNodeLogBundleHandler has a node upload a log bundle now
+func RequestNodeLogBundleHandler(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	// Get node ID from URL
+	vars := mux.Vars(r)
+	nodeID := vars["id"]
+
+	bundle, err := services.RequestNodeLogBundle(nodeID, userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Failed to collect log bundle: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, bundle)
+}
+
+// DownloadNodeLogBundleHandler sends a log bundle as a .tar.gz download
+func DownloadNodeLogBundleHandler(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	// Get node and bundle ID from URL
+	vars := mux.Vars(r)
+	nodeID := vars["id"]
+	bundleID := vars["bundleId"]
+
+	bundle, data, err := services.GetNodeLogBundle(bundleID, nodeID, userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	filename := fmt.Sprintf("nodeease-%s-logs-%s.tar.gz", bundle.NodeID, bundle.CreatedAt.UTC().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/0saurabh0/NodeEase/middleware"
+	"github.com/0saurabh0/NodeEase/models"
+	"github.com/0saurabh0/NodeEase/services"
+	"github.com/0saurabh0/NodeEase/utils"
+	"github.com/gorilla/mux"
+	"golang.org/x/net/websocket"
+)
+
+// StreamNodeLogsHandler streams a node's validator journal over a WebSocket.
+// The priority, grep, since and lines query parameters filter the stream.
+func StreamNodeLogsHandler(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	// Get node ID from URL
+	vars := mux.Vars(r)
+	nodeID := vars["id"]
+
+	query := r.URL.Query()
+	filter := models.NodeLogStreamFilter{
+		Priority: query.Get("priority"),
+		Grep:     query.Get("grep"),
+		Since:    query.Get("since"),
+	}
+	if lines := query.Get("lines"); lines != "" {
+		var err error
+		if filter.Lines, err = strconv.Atoi(lines); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid lines parameter")
+			return
+		}
+	}
+
+	// Connect to the node before upgrading so failures get a proper status
+	stream, err := services.OpenNodeLogStream(nodeID, userID, filter)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Failed to stream logs: "+err.Error())
+		return
+	}
+	defer stream.Close()
+
+	// The token is passed explicitly rather than in a cookie, so any origin
+	// may connect
+	server := websocket.Server{
+		Handler: func(ws *websocket.Conn) {
+			stream.Serve(ws)
+		},
+	}
+	server.ServeHTTP(w, r)
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/0saurabh0/NodeEase/middleware"
@@ -28,11 +29,32 @@ func DeployNodeHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Basic validation
-	if req.NodeName == "" || req.InstanceType == "" || req.Region == "" {
+	if req.NodeName == "" || (services.UsesEC2(req) && (req.InstanceType == "" || req.Region == "")) {
 		utils.RespondWithError(w, http.StatusBadRequest, "Missing required fields")
 		return
 	}
 
+	// Validate the cluster, instance selection and data volumes
+	if err := services.ValidateDeployRequest(userID, req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Verify permissions, quotas and networking before creating anything
+	preflight, err := services.RunDeployPreflight(userID, req)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to run deployment preflight: "+err.Error())
+		return
+	}
+
+	if !preflight.Passed {
+		utils.RespondWithJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+			"error":     "Deployment preflight failed",
+			"preflight": preflight,
+		})
+		return
+	}
+
 	// Deploy the node
 	nodeID, err := services.DeployNode(userID, req)
 	if err != nil {
@@ -40,11 +62,75 @@ func DeployNodeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Return the node ID
-	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+	// Return the node ID, including any retention warning
+	response := map[string]interface{}{
 		"message": "Node deployment started",
 		"nodeId":  nodeID,
-	})
+	}
+	if warning := services.RetentionWarning(req); warning != "" {
+		response["warning"] = warning
+	}
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+// PreflightNodeHandler runs the deployment preflight checks without deploying
+func PreflightNodeHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse request body
+	var req models.NodeDeployRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// Get user ID from context
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	// Basic validation
+	if services.UsesEC2(req) && (req.InstanceType == "" || req.Region == "") {
+		utils.RespondWithError(w, http.StatusBadRequest, "Missing required fields")
+		return
+	}
+
+	// Run the checks
+	preflight, err := services.RunDeployPreflight(userID, req)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to run deployment preflight: "+err.Error())
+		return
+	}
+
+	// Return the checklist
+	utils.RespondWithJSON(w, http.StatusOK, preflight)
+}
+
+// RenderNodeScriptsHandler returns the exact user-data and systemd unit a
+// deploy request would produce, without deploying anything
+func RenderNodeScriptsHandler(w http.ResponseWriter, r *http.Request) {
+	// Parse request body
+	var req models.NodeDeployRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// Get user ID from context
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	// Render the scripts
+	rendered, err := services.PreviewNodeScripts(userID, req)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Failed to render node scripts: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, rendered)
 }
 
 // GetNodeHandler retrieves details of a specific node
@@ -185,6 +271,84 @@ func UpdateNodeStatusHandler(w http.ResponseWriter, r *http.Request) {
 	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Status updated successfully"})
 }
 
+// UpdateNodeStatusBatchHandler receives several status updates from a node at
+// once, e.g. the ones it couldn't deliver earlier
+func UpdateNodeStatusBatchHandler(w http.ResponseWriter, r *http.Request) {
+	// Get node ID and token from URL
+	vars := mux.Vars(r)
+	nodeID := vars["id"]
+	token := vars["token"]
+
+	// Parse request body
+	var batch models.NodeStatusBatch
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// Ensure the node ID in the URL matches the one in the body
+	if batch.NodeID != nodeID {
+		utils.RespondWithError(w, http.StatusBadRequest, "Node ID mismatch")
+		return
+	}
+
+	result, err := services.UpdateNodeDeploymentStatuses(nodeID, token, batch.Updates)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Failed to update node status: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, result)
+}
+
+// NodeHeartbeatHandler receives the periodic heartbeats of running nodes
+func NodeHeartbeatHandler(w http.ResponseWriter, r *http.Request) {
+	// Get node ID and token from URL
+	vars := mux.Vars(r)
+	nodeID := vars["id"]
+	token := vars["token"]
+
+	// Parse request body
+	var heartbeat models.NodeHeartbeat
+	if err := json.NewDecoder(r.Body).Decode(&heartbeat); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := services.RecordNodeHeartbeat(nodeID, token, heartbeat); err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Failed to record heartbeat: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Heartbeat recorded"})
+}
+
+// GetNodeSecretHandler hands a node one of its secrets while it bootstraps
+func GetNodeSecretHandler(w http.ResponseWriter, r *http.Request) {
+	// Get node ID, token and secret name from URL
+	vars := mux.Vars(r)
+	nodeID := vars["id"]
+	token := vars["token"]
+	name := vars["name"]
+
+	value, err := services.GetNodeSecretForNode(nodeID, token, name)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrInvalidDeployToken):
+			status = http.StatusUnauthorized
+		case errors.Is(err, services.ErrNodeSecretUnavailable):
+			status = http.StatusForbidden
+		case errors.Is(err, services.ErrNodeSecretNotFound):
+			status = http.StatusNotFound
+		}
+		utils.RespondWithError(w, status, "Failed to get node secret: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"value": value})
+}
+
 // GetNodeSSHKeyHandler retrieves the SSH key for a specific node
 func GetNodeSSHKeyHandler(w http.ResponseWriter, r *http.Request) {
 	// Get user ID from context
@@ -222,14 +386,18 @@ func StartNodeHandler(w http.ResponseWriter, r *http.Request) {
 	nodeID := vars["id"]
 
 	// Start the node
-	err := services.StartNode(nodeID, userID)
+	warning, err := services.StartNode(nodeID, userID)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to start node: "+err.Error())
 		return
 	}
 
-	// Return success
-	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Node start initiated"})
+	// Return success, including any instance storage warning
+	response := map[string]string{"message": "Node start initiated"}
+	if warning != "" {
+		response["warning"] = warning
+	}
+	utils.RespondWithJSON(w, http.StatusOK, response)
 }
 
 // StopNodeHandler stops a running EC2 instance for a node
@@ -245,15 +413,38 @@ func StopNodeHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	nodeID := vars["id"]
 
-	// Stop the node
-	err := services.StopNode(nodeID, userID)
+	// Stop the node, force=true confirms wiping instance storage
+	force := r.URL.Query().Get("force") == "true"
+	warning, err := services.StopNode(nodeID, userID, force)
 	if err != nil {
+		if respondInstanceStoreConfirmation(w, err) {
+			return
+		}
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to stop node: "+err.Error())
 		return
 	}
 
-	// Return success
-	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Node stop initiated"})
+	// Return success, including any instance storage warning
+	response := map[string]string{"message": "Node stop initiated"}
+	if warning != "" {
+		response["warning"] = warning
+	}
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+// respondInstanceStoreConfirmation answers with 409 and the warning when an
+// action needs to be confirmed because of instance storage
+func respondInstanceStoreConfirmation(w http.ResponseWriter, err error) bool {
+	var confirmErr *services.InstanceStoreConfirmationError
+	if !errors.As(err, &confirmErr) {
+		return false
+	}
+
+	utils.RespondWithJSON(w, http.StatusConflict, map[string]string{
+		"error":   "Confirm with force=true to continue",
+		"warning": confirmErr.Warning,
+	})
+	return true
 }
 
 // RebootNodeHandler reboots a running EC2 instance for a node
@@ -270,12 +461,44 @@ func RebootNodeHandler(w http.ResponseWriter, r *http.Request) {
 	nodeID := vars["id"]
 
 	// Reboot the node
-	err := services.RebootNode(nodeID, userID)
+	warning, err := services.RebootNode(nodeID, userID)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to reboot node: "+err.Error())
 		return
 	}
 
+	// Return success, including any instance storage warning
+	response := map[string]string{"message": "Node reboot initiated"}
+	if warning != "" {
+		response["warning"] = warning
+	}
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+// RetryNodeHandler retries a failed or stalled deployment, reusing the
+// resources the earlier attempt created
+func RetryNodeHandler(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	// Get node ID from URL
+	vars := mux.Vars(r)
+	nodeID := vars["id"]
+
+	// Retry the deployment
+	attempt, err := services.RetryNodeDeployment(nodeID, userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Failed to retry deployment: "+err.Error())
+		return
+	}
+
 	// Return success
-	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Node reboot initiated"})
+	utils.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Deployment retry initiated",
+		"attempt": attempt,
+	})
 }
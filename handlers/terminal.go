@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/0saurabh0/NodeEase/middleware"
+	"github.com/0saurabh0/NodeEase/models"
+	"github.com/0saurabh0/NodeEase/services"
+	"github.com/0saurabh0/NodeEase/utils"
+	"github.com/gorilla/mux"
+	"golang.org/x/net/websocket"
+)
+
+// NodeTerminalHandler opens an interactive shell on a node over a WebSocket.
+// The cols and rows query parameters set the initial terminal size. Whether
+// the session's output is recorded depends on the user's role.
+func NodeTerminalHandler(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	// Get node ID from URL
+	vars := mux.Vars(r)
+	nodeID := vars["id"]
+
+	query := r.URL.Query()
+	var req models.NodeTerminalRequest
+	if cols := query.Get("cols"); cols != "" {
+		var err error
+		if req.Cols, err = strconv.Atoi(cols); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid cols parameter")
+			return
+		}
+	}
+	if rows := query.Get("rows"); rows != "" {
+		var err error
+		if req.Rows, err = strconv.Atoi(rows); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid rows parameter")
+			return
+		}
+	}
+
+	role := models.RoleUser
+	if middleware.IsAdmin(userID) {
+		role = models.RoleAdmin
+	}
+
+	// Connect to the node before upgrading so failures get a proper status
+	terminal, err := services.OpenNodeTerminal(nodeID, userID, role, r.RemoteAddr, req)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Failed to open terminal: "+err.Error())
+		return
+	}
+	defer terminal.Close()
+
+	// The token is passed explicitly rather than in a cookie, so any origin
+	// may connect
+	server := websocket.Server{
+		Handler: func(ws *websocket.Conn) {
+			terminal.Serve(ws)
+		},
+	}
+	server.ServeHTTP(w, r)
+}
+
+// ListNodeTerminalSessionsHandler lists the recent terminal sessions on a node
+func ListNodeTerminalSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	// Get node ID from URL
+	vars := mux.Vars(r)
+	nodeID := vars["id"]
+
+	sessions, err := services.ListNodeTerminalSessions(nodeID, userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to list terminal sessions: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, sessions)
+}
+
+// DownloadNodeTerminalTranscriptHandler sends the recorded output of a
+// terminal session as a text download
+func DownloadNodeTerminalTranscriptHandler(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	// Get node and session ID from URL
+	vars := mux.Vars(r)
+	nodeID := vars["id"]
+	sessionID := vars["sessionId"]
+
+	transcript, err := services.GetNodeTerminalTranscript(sessionID, nodeID, userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	filename := fmt.Sprintf("nodeease-%s-terminal-%s.txt", nodeID, sessionID)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Content-Length", strconv.Itoa(len(transcript)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(transcript)
+}
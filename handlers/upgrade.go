@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/0saurabh0/NodeEase/middleware"
+	"github.com/0saurabh0/NodeEase/models"
+	"github.com/0saurabh0/NodeEase/services"
+	"github.com/0saurabh0/NodeEase/utils"
+	"github.com/gorilla/mux"
+)
+
+// UpgradeNodeHandler starts a validator client upgrade on a single node
+func UpgradeNodeHandler(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	// Get node ID from URL
+	vars := mux.Vars(r)
+	nodeID := vars["id"]
+
+	// Parse request body
+	var req models.NodeUpgradeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if req.TargetVersion == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Target version is required")
+		return
+	}
+
+	// Start the upgrade
+	upgrade, err := services.UpgradeNode(nodeID, userID, req)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Failed to start upgrade: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusAccepted, upgrade)
+}
+
+// GetNodeUpgradesHandler lists the upgrade history of a node
+func GetNodeUpgradesHandler(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	// Get node ID from URL
+	vars := mux.Vars(r)
+	nodeID := vars["id"]
+
+	upgrades, err := services.GetNodeUpgrades(nodeID, userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get upgrades: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, upgrades)
+}
+
+// FleetUpgradeHandler starts a rolling upgrade across several nodes
+func FleetUpgradeHandler(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	// Parse request body
+	var req models.FleetUpgradeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// Start the rollout
+	rollout, err := services.StartFleetUpgrade(userID, req)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Failed to start rollout: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusAccepted, rollout)
+}
+
+// GetUpgradeRolloutHandler returns the progress of a rolling upgrade
+func GetUpgradeRolloutHandler(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get user ID")
+		return
+	}
+
+	// Get rollout ID from URL
+	vars := mux.Vars(r)
+	rolloutID := vars["id"]
+
+	rollout, err := services.GetUpgradeRollout(rolloutID, userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, rollout)
+}
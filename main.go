@@ -8,6 +8,8 @@ import (
 
 	"github.com/0saurabh0/NodeEase/db"
 	"github.com/0saurabh0/NodeEase/routes"
+	"github.com/0saurabh0/NodeEase/services"
+	"github.com/0saurabh0/NodeEase/utils"
 	"github.com/joho/godotenv"
 	"github.com/rs/cors"
 )
@@ -17,11 +19,30 @@ func main() {
 		log.Printf("Warning: Error loading .env file: %v", err)
 	}
 
+	// Credentials are stored encrypted, fail early without a key
+	if err := utils.LoadEncryptionKey(); err != nil {
+		log.Fatalf("Failed to load encryption key: %v", err)
+	}
+
 	// Initialize database
 	if err := db.InitDB(); err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
+	// Make sure the public clusters are in the catalog
+	if err := services.SeedDefaultClusters(); err != nil {
+		log.Fatalf("Failed to seed clusters: %v", err)
+	}
+
+	// Watch running nodes for missed heartbeats
+	services.StartHeartbeatMonitor()
+
+	// Watch deploying nodes for stalled steps
+	services.StartDeployWatchdog()
+
+	// Delete log bundles past their retention
+	services.StartLogBundleCleanup()
+
 	router := routes.SetupRouter()
 
 	// Create a more permissive CORS middleware configuration
@@ -34,7 +55,7 @@ func main() {
 			"https://nodeease.up.railway.app",
 			"https://*.railway.app", // Try to match all Railway subdomains
 		},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With"},
 		AllowCredentials: true,
 		// Add these options to handle preflight requests properly
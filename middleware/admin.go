@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+	"os"
+	"strings"
+)
+
+// IsAdmin reports whether a user is listed in ADMIN_EMAILS
+func IsAdmin(userID string) bool {
+	for _, email := range strings.Split(os.Getenv("ADMIN_EMAILS"), ",") {
+		if email = strings.TrimSpace(email); email != "" && strings.EqualFold(email, userID) {
+			return true
+		}
+	}
+	return false
+}
+
+// AdminMiddleware only lets admins through. It must run after AuthMiddleware.
+func AdminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(UserIDKey).(string)
+		if !ok || !IsAdmin(userID) {
+			http.Error(w, "Forbidden: Admin access required", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -21,6 +21,13 @@ const (
 func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
+
+		// Browsers can't set headers on WebSocket connections, so those pass
+		// the token as a query parameter instead
+		if authHeader == "" && strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			authHeader = r.URL.Query().Get("token")
+		}
+
 		if authHeader == "" {
 			http.Error(w, "Unauthorized: No token provided", http.StatusUnauthorized)
 			return
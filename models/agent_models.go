@@ -0,0 +1,75 @@
+package models
+
+import "time"
+
+// Frame types exchanged over a node agent connection
+const (
+	AgentMessageHeartbeat = "heartbeat"
+	AgentMessageCommand   = "command"
+	AgentMessageResult    = "result"
+)
+
+// Commands the node agent runs. The agent refuses anything else.
+const (
+	AgentCommandRestartService = "restart_service"
+	AgentCommandTailLogs       = "tail_logs"
+	AgentCommandApplyConfig    = "apply_config"
+	AgentCommandUpgrade        = "upgrade"
+	AgentCommandUploadLogs     = "upload_logs"
+)
+
+// AgentMessage is a frame on the agent connection. Type says which field is set.
+type AgentMessage struct {
+	Type      string              `json:"type"` // heartbeat, command, result
+	Heartbeat *NodeHeartbeat      `json:"heartbeat,omitempty"`
+	Command   *AgentCommand       `json:"command,omitempty"`
+	Result    *AgentCommandResult `json:"result,omitempty"`
+}
+
+// NodeHeartbeat is a node's periodic report on its validator
+type NodeHeartbeat struct {
+	NodeID        string    `json:"nodeId"`
+	ServiceState  string    `json:"serviceState"`  // systemctl is-active state: active, inactive, failed, ...
+	Slot          int64     `json:"slot"`          // Slot reported by the local RPC, 0 if it didn't answer
+	DiskFreeBytes int64     `json:"diskFreeBytes"` // Free space on the data filesystem
+	UptimeSeconds int64     `json:"uptimeSeconds"` // Time since the machine booted
+	AgentVersion  string    `json:"agentVersion,omitempty"`
+	ReceivedAt    time.Time `json:"receivedAt"`
+}
+
+// AgentCommand is a command queued for a node's agent
+type AgentCommand struct {
+	ID             string    `json:"id"`
+	NodeID         string    `json:"nodeId"`
+	UserID         string    `json:"userId"`
+	Type           string    `json:"type"`                     // restart_service, tail_logs, apply_config, upgrade
+	Lines          int       `json:"lines,omitempty"`          // Lines to return for tail_logs
+	Script         string    `json:"script,omitempty"`         // Script run by apply_config and upgrade, never stored
+	TimeoutSeconds int       `json:"timeoutSeconds,omitempty"` // How long the agent lets the command run
+	Status         string    `json:"status"`                   // queued, sent, succeeded, failed, expired
+	ExitCode       int       `json:"exitCode"`
+	Output         string    `json:"output"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// AgentCommandResult is what the agent reports after running a command
+type AgentCommandResult struct {
+	CommandID string `json:"commandId"`
+	ExitCode  int    `json:"exitCode"`
+	Output    string `json:"output"`
+	Error     string `json:"error,omitempty"` // Set when the command couldn't be run
+}
+
+// AgentCommandRequest queues a command for a node's agent from the API
+type AgentCommandRequest struct {
+	Type  string `json:"type"`  // restart_service, tail_logs
+	Lines int    `json:"lines"` // tail_logs only (default 100)
+}
+
+// NodeAgentStatus reports whether a node's agent is connected and what it last sent
+type NodeAgentStatus struct {
+	Connected   bool           `json:"connected"`
+	ConnectedAt *time.Time     `json:"connectedAt,omitempty"`
+	Heartbeat   *NodeHeartbeat `json:"heartbeat,omitempty"`
+}
@@ -25,7 +25,7 @@ type Integration struct {
 	ID        string      `json:"id,omitempty"`
 	UserID    string      `json:"userId"`
 	Provider  string      `json:"provider"`
-	Data      interface{} `json:"data"` // Can be AWSIntegrationData or other provider data
+	Data      interface{} `json:"data"` // AWSIntegrationData, GCPIntegrationData or BareMetalIntegrationData
 	Status    string      `json:"status"`
 	CreatedAt time.Time   `json:"createdAt"`
 	UpdatedAt time.Time   `json:"updatedAt"`
@@ -0,0 +1,13 @@
+package models
+
+// BareMetalCredentials represents the bare-metal host API token received from client
+type BareMetalCredentials struct {
+	APIToken  string `json:"apiToken"`
+	ProjectID string `json:"projectId"` // Project servers are ordered in
+}
+
+// BareMetalIntegrationData represents the stored bare-metal integration data
+type BareMetalIntegrationData struct {
+	APIToken  string `json:"apiToken"` // Encrypted
+	ProjectID string `json:"projectId"`
+}
@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+// InstanceTypeInfo describes an EC2 instance type NodeEase supports
+type InstanceTypeInfo struct {
+	InstanceType string  `json:"instanceType"`
+	Description  string  `json:"description"`
+	VCPU         int     `json:"vCPU"`
+	MemoryGiB    int     `json:"memoryGiB"`
+	Network      string  `json:"network"`      // Advertised network bandwidth, e.g. "25 Gbps"
+	LocalNVMeGB  int     `json:"localNvmeGB"`  // Total instance-store capacity, 0 if none
+	Architecture string  `json:"architecture"` // x86_64, arm64
+	HourlyPrice  float64 `json:"hourlyPrice,omitempty"`
+	MonthlyPrice float64 `json:"monthlyPrice,omitempty"`
+	Estimated    bool    `json:"estimated,omitempty"` // The region has no listed price, the default region's is used
+	Available    *bool   `json:"available,omitempty"` // Set when a region is requested
+}
+
+// InstanceRecommendation is the minimum sizing for a network and RPC type
+type InstanceRecommendation struct {
+	NetworkType         string `json:"networkType"`
+	RpcType             string `json:"rpcType"`
+	MinVCPU             int    `json:"minVCPU"`
+	MinMemoryGiB        int    `json:"minMemoryGiB"`
+	MinDiskSize         int    `json:"minDiskSize"`
+	RecommendedInstance string `json:"recommendedInstance"`
+	RecommendedDiskSize int    `json:"recommendedDiskSize"`
+}
+
+// RegionInfo describes a region nodes can be deployed to
+type RegionInfo struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// InstanceCatalog is returned by the catalog API
+type InstanceCatalog struct {
+	Region          string                   `json:"region,omitempty"`
+	Currency        string                   `json:"currency"`
+	PricesUpdatedAt time.Time                `json:"pricesUpdatedAt"`
+	InstanceTypes   []InstanceTypeInfo       `json:"instanceTypes"`
+	Recommendations []InstanceRecommendation `json:"recommendations"`
+}
+
+// ValidatorClientInfo describes a validator client that can be installed on a node
+type ValidatorClientInfo struct {
+	Flavor         string   `json:"flavor"` // agave, jito-solana, firedancer
+	DisplayName    string   `json:"displayName"`
+	Versions       []string `json:"versions"`
+	DefaultVersion string   `json:"defaultVersion"`
+	BinaryName     string   `json:"binaryName"`
+	Architectures  []string `json:"architectures"` // amd64, arm64
+	SupportedFlags []string `json:"supportedFlags"`
+
+	ExtraArgs []ValidatorArgInfo `json:"extraArgs"` // Arguments users may add
+}
+
+// ValidatorArgInfo describes an extra validator argument a client accepts
+type ValidatorArgInfo struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"` // bool, int, path, url, hostport
+	Min         int64  `json:"min,omitempty"`
+	Max         int64  `json:"max,omitempty"`
+	Repeatable  bool   `json:"repeatable"`
+	Description string `json:"description"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Cluster is a Solana cluster nodes can join. Public clusters are managed by
+// admins, private ones belong to the user who created them.
+type Cluster struct {
+	ID                   string    `json:"id"` // Used as the deploy request's networkType
+	Name                 string    `json:"name"`
+	OwnerID              string    `json:"ownerId,omitempty"` // Empty for public clusters
+	Private              bool      `json:"private"`
+	Entrypoints          []string  `json:"entrypoints"`     // Gossip entrypoints, host:port
+	KnownValidators      []string  `json:"knownValidators"` // Identity pubkeys trusted for snapshots
+	ExpectedGenesisHash  string    `json:"expectedGenesisHash,omitempty"`
+	ExpectedShredVersion int       `json:"expectedShredVersion,omitempty"` // 0 leaves it unpinned
+	CreatedAt            time.Time `json:"createdAt"`
+	UpdatedAt            time.Time `json:"updatedAt"`
+}
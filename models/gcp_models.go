@@ -0,0 +1,15 @@
+package models
+
+// GCPCredentials represents the GCP service account received from client
+type GCPCredentials struct {
+	ServiceAccountKey string `json:"serviceAccountKey"` // Service account key JSON
+	Zone              string `json:"zone"`              // Default zone, e.g. us-central1-a
+}
+
+// GCPIntegrationData represents the stored GCP integration data
+type GCPIntegrationData struct {
+	ProjectID         string `json:"projectId"`
+	ClientEmail       string `json:"clientEmail"`
+	ServiceAccountKey string `json:"serviceAccountKey"` // Encrypted
+	Zone              string `json:"zone"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Why a node uploaded a log bundle
+const (
+	LogBundleReasonFailure  = "failure"
+	LogBundleReasonOnDemand = "on_demand"
+)
+
+// NodeLogBundle is a gzipped tarball of a node's deployment log and validator
+// journal, as uploaded by the node. The archive itself is downloaded separately.
+type NodeLogBundle struct {
+	ID        string    `json:"id"`
+	NodeID    string    `json:"nodeId"`
+	Reason    string    `json:"reason"` // failure, on_demand
+	SizeBytes int64     `json:"sizeBytes"`
+	CreatedAt time.Time `json:"createdAt"`
+}
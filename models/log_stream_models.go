@@ -0,0 +1,23 @@
+package models
+
+// Frame types sent on a live log stream
+const (
+	LogStreamLine  = "line"
+	LogStreamError = "error"
+	LogStreamEnd   = "end"
+)
+
+// NodeLogStreamFilter narrows down a live validator log stream
+type NodeLogStreamFilter struct {
+	Priority string // Journal priority name or 0-7, only lines at least this severe
+	Grep     string // Regular expression lines must match
+	Since    string // How far back to start, a duration like 15m or an RFC 3339 time
+	Lines    int    // Lines of backlog sent before following
+}
+
+// NodeLogStreamMessage is a frame sent on a live log stream
+type NodeLogStreamMessage struct {
+	Type  string `json:"type"` // line, error, end
+	Line  string `json:"line,omitempty"`
+	Error string `json:"error,omitempty"`
+}
@@ -0,0 +1,139 @@
+package models
+
+import "time"
+
+// ValidatorFlag is a single agave-validator command line flag with its values
+type ValidatorFlag struct {
+	Name   string   `json:"name"`             // e.g. "--rpc-port"
+	Values []string `json:"values,omitempty"` // Empty for boolean flags
+}
+
+// ValidatorSettings are the tunable validator options rendered into the unit.
+// Defaults come from the RPC type and history length chosen at deploy time.
+type ValidatorSettings struct {
+	AccountIndexes            []string `json:"accountIndexes"`          // program-id, spl-token-owner, spl-token-mint
+	AccountIndexExcludeKeys   []string `json:"accountIndexExcludeKeys"` // Keys left out of the account indexes
+	PrivateRPC                bool     `json:"privateRpc"`
+	OnlyKnownRPC              bool     `json:"onlyKnownRpc"` // Only fetch snapshots from known validators
+	FullRPCAPI                bool     `json:"fullRpcApi"`
+	RPCBindAddress            string   `json:"rpcBindAddress,omitempty"`
+	TransactionHistory        bool     `json:"transactionHistory"`
+	ExtendedTxMetadataStorage bool     `json:"extendedTxMetadataStorage"`
+	CPIAndLogStorage          bool     `json:"cpiAndLogStorage"`
+	LimitLedger               bool     `json:"limitLedger"`
+	LimitLedgerShreds         int64    `json:"limitLedgerShreds,omitempty"` // 0 uses the client default
+	WALRecoveryMode           string   `json:"walRecoveryMode,omitempty"`
+	NoSnapshotFetch           bool     `json:"noSnapshotFetch"`
+
+	// Snapshot schedule and retention, derived from the history length
+	FullSnapshotIntervalSlots        int64 `json:"fullSnapshotIntervalSlots,omitempty"`
+	IncrementalSnapshotIntervalSlots int64 `json:"incrementalSnapshotIntervalSlots,omitempty"`
+	MaxFullSnapshots                 int   `json:"maxFullSnapshots,omitempty"`
+	MaxIncrementalSnapshots          int   `json:"maxIncrementalSnapshots,omitempty"`
+
+	// Serve history older than the local ledger from BigTable
+	BigTableLedgerStorage bool   `json:"bigTableLedgerStorage"`
+	BigTableInstanceName  string `json:"bigTableInstanceName,omitempty"`
+	BigTableTimeout       int    `json:"bigTableTimeout,omitempty"` // Seconds
+
+	// ExtraArgs are additional flags from the client's allow-list, appended
+	// after the flags NodeEase manages
+	ExtraArgs []ValidatorFlag `json:"extraArgs,omitempty"`
+}
+
+// NodeConfigDelta is a partial update to a node's validator settings. Only
+// fields that are set are changed.
+type NodeConfigDelta struct {
+	AccountIndexes            *[]string `json:"accountIndexes,omitempty"`
+	AccountIndexExcludeKeys   *[]string `json:"accountIndexExcludeKeys,omitempty"`
+	PrivateRPC                *bool     `json:"privateRpc,omitempty"`
+	OnlyKnownRPC              *bool     `json:"onlyKnownRpc,omitempty"`
+	FullRPCAPI                *bool     `json:"fullRpcApi,omitempty"`
+	TransactionHistory        *bool     `json:"transactionHistory,omitempty"`
+	ExtendedTxMetadataStorage *bool     `json:"extendedTxMetadataStorage,omitempty"`
+	CPIAndLogStorage          *bool     `json:"cpiAndLogStorage,omitempty"`
+	LimitLedger               *bool     `json:"limitLedger,omitempty"`
+	LimitLedgerShreds         *int64    `json:"limitLedgerShreds,omitempty"`
+	NoSnapshotFetch           *bool     `json:"noSnapshotFetch,omitempty"`
+
+	// ExtraArgs replaces the whole list of extra arguments; an empty list clears them
+	ExtraArgs *[]ValidatorFlag `json:"extraArgs,omitempty"`
+}
+
+// NodeConfigRevision is a configuration that was applied to a node
+type NodeConfigRevision struct {
+	ID            string     `json:"id"`
+	NodeID        string     `json:"nodeId"`
+	UserID        string     `json:"userId"`
+	Revision      int        `json:"revision"`
+	Config        NodeConfig `json:"config"`
+	SystemdUnit   string     `json:"systemdUnit"`
+	ClientConfig  string     `json:"clientConfig,omitempty"`
+	ClientVersion string     `json:"clientVersion"`
+	Reason        string     `json:"reason"` // deploy, reconfigure, rollback
+
+	DeployRequest *NodeDeployRequest `json:"deployRequest,omitempty"` // Set for deploy revisions
+	Delta         *NodeConfigDelta   `json:"delta,omitempty"`         // Set for reconfigure revisions
+	RollbackOf    int                `json:"rollbackOf,omitempty"`    // Revision restored by a rollback
+
+	Status    string    `json:"status"` // applied, failed
+	Message   string    `json:"message,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// NodeConfigChange is a single setting that differs between two revisions
+type NodeConfigChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// NodeConfigDiff compares two config revisions of a node
+type NodeConfigDiff struct {
+	FromRevision int                `json:"fromRevision"`
+	ToRevision   int                `json:"toRevision"`
+	Changes      []NodeConfigChange `json:"changes"`
+	UnitDiff     string             `json:"unitDiff"`             // Unified diff of the systemd unit
+	ClientDiff   string             `json:"clientDiff,omitempty"` // Unified diff of the client config file
+}
+
+// NodeConfigRollbackRequest selects the revision a node is rolled back to
+type NodeConfigRollbackRequest struct {
+	Revision int `json:"revision"`
+}
+
+// NodeConfig is the typed input used to render a node's bootstrap script and
+// validator systemd unit
+type NodeConfig struct {
+	NodeID        string `json:"nodeId"`
+	NodeName      string `json:"nodeName"`
+	DeployToken   string `json:"-"`
+	APIBaseURL    string `json:"apiBaseUrl"`
+	RpcType       string `json:"rpcType"`       // base, extended
+	NetworkType   string `json:"networkType"`   // As requested: mainnet, testnet, devnet or a cluster ID
+	Cluster       string `json:"cluster"`       // Cluster ID in the catalog
+	HistoryLength string `json:"historyLength"` // minimal, recent, full
+	Client        string `json:"client"`        // Validator client flavor
+	ClientVersion string `json:"clientVersion"` // Validator client release
+
+	Entrypoints          []string `json:"entrypoints"`
+	KnownValidators      []string `json:"knownValidators"`
+	ExpectedGenesisHash  string   `json:"expectedGenesisHash,omitempty"`
+	ExpectedShredVersion int      `json:"expectedShredVersion,omitempty"`
+
+	Settings ValidatorSettings `json:"settings"`
+
+	LedgerVolume          *VolumeSpec `json:"ledgerVolume,omitempty"`
+	AccountsVolume        *VolumeSpec `json:"accountsVolume,omitempty"`
+	InstanceStoreAccounts bool        `json:"instanceStoreAccounts"`
+	InstanceStoreLedger   bool        `json:"instanceStoreLedger"`
+}
+
+// RenderedNodeScripts is the exact user-data and validator unit a node would get
+type RenderedNodeScripts struct {
+	Config       NodeConfig      `json:"config"`
+	Flags        []ValidatorFlag `json:"flags"`
+	UserData     string          `json:"userData"`
+	SystemdUnit  string          `json:"systemdUnit"`
+	ClientConfig string          `json:"clientConfig,omitempty"` // Config file for clients not configured by flags
+}
@@ -1,15 +1,17 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
 // NodeDeploymentLog represents a log entry during node deployment
 type NodeDeploymentLog struct {
 	Timestamp time.Time `json:"timestamp"`
-	Step      string    `json:"step"`     // Deployment step e.g. "system_setup", "solana_install"
-	Message   string    `json:"message"`  // Detailed message
-	Progress  int       `json:"progress"` // Progress 0-100
+	Step      string    `json:"step"`              // Deployment step e.g. "system_setup", "solana_install"
+	Message   string    `json:"message"`           // Detailed message
+	Progress  int       `json:"progress"`          // Progress 0-100
+	Attempt   int       `json:"attempt,omitempty"` // Deploy attempt the entry belongs to
 }
 
 // NodeStatusUpdate represents a status update from a node during deployment
@@ -18,41 +20,137 @@ type NodeStatusUpdate struct {
 	Step     string `json:"step"`
 	Message  string `json:"message"`
 	Progress int    `json:"progress"`
-	Status   string `json:"status"` // deploying, initializing, running, failed
+	Status   string `json:"status"`             // deploying, initializing, running, failed
+	Sequence int64  `json:"sequence,omitempty"` // Increases with every update a node sends, 0 if unsequenced
+}
+
+// NodeStatusBatch is a set of status updates a node sends at once, e.g. the
+// ones it couldn't deliver earlier
+type NodeStatusBatch struct {
+	NodeID  string             `json:"nodeId"`
+	Updates []NodeStatusUpdate `json:"updates"`
+}
+
+// NodeStatusBatchResult reports what happened to the updates of a batch
+type NodeStatusBatchResult struct {
+	Applied      int   `json:"applied"`      // Recorded and applied to the node
+	Duplicates   int   `json:"duplicates"`   // Already recorded, ignored
+	Stale        int   `json:"stale"`        // Recorded, but older than the node's current status
+	LastSequence int64 `json:"lastSequence"` // Newest sequence number applied
+}
+
+// VolumeSpec describes a separate EBS data volume for a node
+type VolumeSpec struct {
+	Type       string `json:"type"`                 // gp3, io2
+	Size       int    `json:"size"`                 // Size in GB
+	IOPS       int    `json:"iops,omitempty"`       // Provisioned IOPS (gp3, io2)
+	Throughput int    `json:"throughput,omitempty"` // Throughput in MiB/s (gp3 only)
+}
+
+// LongTermStorageSpec configures a backend serving ledger history older than
+// what the node keeps on disk
+type LongTermStorageSpec struct {
+	Backend      string `json:"backend"`                // bigtable
+	InstanceName string `json:"instanceName,omitempty"` // BigTable instance, defaults to solana-ledger
+	Timeout      int    `json:"timeout,omitempty"`      // Request timeout in seconds
+	Credentials  string `json:"credentials,omitempty"`  // Service account key JSON, kept as a node secret
+}
+
+// SandboxProgram is a program loaded into a local test validator at genesis
+type SandboxProgram struct {
+	ProgramID string `json:"programId"`
+	ELF       string `json:"elf"` // Base64 encoded program shared object
+}
+
+// SandboxAccount is an account loaded into a local test validator at genesis
+type SandboxAccount struct {
+	Address string          `json:"address"`
+	Account json.RawMessage `json:"account"` // Account JSON as written by `solana account --output json`
+}
+
+// SandboxSpec configures a local test validator
+type SandboxSpec struct {
+	Programs []SandboxProgram `json:"programs,omitempty"`
+	Accounts []SandboxAccount `json:"accounts,omitempty"`
 }
 
 // NodeDeployRequest contains parameters for node deployment
 type NodeDeployRequest struct {
 	NodeName      string `json:"nodeName"`
+	Provider      string `json:"provider"`      // aws, gcp, baremetal, local (default aws)
 	RpcType       string `json:"rpcType"`       // base, extended
-	InstanceType  string `json:"instanceType"`  // EC2 instance type
-	Region        string `json:"region"`        // AWS region
-	DiskSize      int    `json:"diskSize"`      // Disk size in GB
+	InstanceType  string `json:"instanceType"`  // EC2 instance type, GCP machine type or bare-metal plan
+	Region        string `json:"region"`        // AWS region, GCP zone or bare-metal site
+	DiskSize      int    `json:"diskSize"`      // Disk size in GB, the plan's usable disk on bare metal
 	HistoryLength string `json:"historyLength"` // minimal, recent, full
-	NetworkType   string `json:"networkType"`   // mainnet, testnet, devnet
+	NetworkType   string `json:"networkType"`   // mainnet, testnet, devnet or a cluster ID from the catalog
+	OSRelease     string `json:"osRelease"`     // Ubuntu release: 22.04, 24.04 (default 22.04)
+	Architecture  string `json:"architecture"`  // amd64, arm64 (default amd64)
+	CustomAMI     string `json:"customAmi"`     // Optional user-pinned AMI ID
+	Client        string `json:"client"`        // agave, jito-solana, firedancer (default agave)
+	ClientVersion string `json:"clientVersion"` // Client release, defaults to the catalog's default
+
+	ExtraArgs []ValidatorFlag `json:"extraArgs,omitempty"` // Additional validator flags from the client's allow-list
+
+	LedgerVolume   *VolumeSpec `json:"ledgerVolume,omitempty"`   // Optional separate ledger volume
+	AccountsVolume *VolumeSpec `json:"accountsVolume,omitempty"` // Optional separate accounts volume
+
+	LongTermStorage *LongTermStorageSpec `json:"longTermStorage,omitempty"` // Required for full history
+
+	InstanceStoreAccounts bool `json:"instanceStoreAccounts"` // Put accounts on local NVMe instance storage
+	InstanceStoreLedger   bool `json:"instanceStoreLedger"`   // Also put the ledger on instance storage
+
+	Sandbox *SandboxSpec `json:"sandbox,omitempty"` // Genesis programs and accounts for local nodes
 }
 
 // Node represents a deployed Solana node
 type Node struct {
-	ID             string              `json:"id"`
-	UserID         string              `json:"userId"`
-	Name           string              `json:"name"`
-	Provider       string              `json:"provider"` // AWS
-	Region         string              `json:"region"`
-	InstanceType   string              `json:"instanceType"`
-	InstanceID     string              `json:"instanceId"`   // AWS EC2 instance ID
-	NodeType       string              `json:"nodeType"`     // base, extended
-	NetworkType    string              `json:"networkType"`  // mainnet, testnet, devnet
-	Status         string              `json:"status"`       // deploying, running, stopped, failed
-	StatusDetail   string              `json:"statusDetail"` // Detailed status or error message
-	IPAddress      string              `json:"ipAddress"`
-	DiskSize       int                 `json:"diskSize"`
-	RpcEndpoint    string              `json:"rpcEndpoint"`
-	DeploymentLogs []NodeDeploymentLog `json:"deploymentLogs,omitempty"`
-	DeployToken    string              `json:"-"` // Token used for authenticating deployment updates
-	SSHKeyName     string              `json:"sshKeyName,omitempty"`
-	SshPrivateKey  string              `json:"sshPrivateKey,omitempty" db:"ssh_private_key"`
-	CreatedAt      time.Time           `json:"createdAt"`
-	UpdatedAt      time.Time           `json:"updatedAt"`
-	LastCheck      *time.Time          `json:"lastCheck,omitempty"`
+	ID               string              `json:"id"`
+	UserID           string              `json:"userId"`
+	Name             string              `json:"name"`
+	Provider         string              `json:"provider"` // AWS, GCP, BareMetal, Local
+	Region           string              `json:"region"`
+	InstanceType     string              `json:"instanceType"`
+	InstanceID       string              `json:"instanceId"`            // EC2 instance ID, or the provider's machine ID
+	NodeType         string              `json:"nodeType"`              // base, extended
+	NetworkType      string              `json:"networkType"`           // mainnet, testnet, devnet
+	Status           string              `json:"status"`                // deploying, running, stopped, failed, unresponsive
+	StatusDetail     string              `json:"statusDetail"`          // Detailed status or error message
+	StalledStep      string              `json:"stalledStep,omitempty"` // Deployment step that stopped making progress
+	DeployAttempt    int                 `json:"deployAttempt"`         // Starts at 1, incremented by each retry
+	IPAddress        string              `json:"ipAddress"`
+	DiskSize         int                 `json:"diskSize"`
+	RpcEndpoint      string              `json:"rpcEndpoint"`
+	WsEndpoint       string              `json:"wsEndpoint,omitempty"`
+	Client           string              `json:"client"`                     // Validator client flavor
+	ClientVersion    string              `json:"clientVersion"`              // Validator client release running on the node
+	HistoryLength    string              `json:"historyLength"`              // minimal, recent, full
+	LedgerVolumeID   string              `json:"ledgerVolumeId,omitempty"`   // EBS volume holding the ledger
+	AccountsVolumeID string              `json:"accountsVolumeId,omitempty"` // EBS volume holding accounts
+	EphemeralStorage bool                `json:"ephemeralStorage"`           // Data lives on instance storage and is wiped on stop
+	DeploymentLogs   []NodeDeploymentLog `json:"deploymentLogs,omitempty"`
+	DeployToken      string              `json:"-"` // Token used for authenticating deployment updates
+	SSHKeyName       string              `json:"sshKeyName,omitempty"`
+	SshPrivateKey    string              `json:"sshPrivateKey,omitempty" db:"ssh_private_key"`
+	CreatedAt        time.Time           `json:"createdAt"`
+	UpdatedAt        time.Time           `json:"updatedAt"`
+	LastCheck        *time.Time          `json:"lastCheck,omitempty"`
+}
+
+// PreflightCheck is a single pass/fail item checked before a node is deployed.
+// A skipped check is reported as a warning and doesn't fail the preflight.
+type PreflightCheck struct {
+	Name        string `json:"name"`                  // e.g. "iam_run_instances", "vcpu_quota"
+	Description string `json:"description"`           // What was checked
+	Passed      bool   `json:"passed"`                // Whether the check passed
+	Skipped     bool   `json:"skipped,omitempty"`     // Couldn't be evaluated, doesn't block the deploy
+	Message     string `json:"message,omitempty"`     // Detail about the outcome
+	Remediation string `json:"remediation,omitempty"` // How to fix a failed check
+}
+
+// PreflightResult is the checklist returned by the deployment preflight
+type PreflightResult struct {
+	Passed bool             `json:"passed"`
+	Region string           `json:"region"`
+	Checks []PreflightCheck `json:"checks"`
 }
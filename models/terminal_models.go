@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Frame types exchanged on a browser terminal connection
+const (
+	TerminalInput  = "input"  // Keystrokes from the browser
+	TerminalResize = "resize" // The browser's terminal changed size
+	TerminalOutput = "output" // Output of the remote shell
+	TerminalExit   = "exit"   // The remote shell ended
+)
+
+// TerminalMessage is a frame on a browser terminal connection. Data is
+// base64 encoded in JSON, so the raw terminal byte stream survives.
+type TerminalMessage struct {
+	Type string `json:"type"`
+	Data []byte `json:"data,omitempty"`
+	Cols int    `json:"cols,omitempty"`
+	Rows int    `json:"rows,omitempty"`
+}
+
+// NodeTerminalRequest describes the terminal a browser asks for
+type NodeTerminalRequest struct {
+	Cols int
+	Rows int
+}
+
+// NodeTerminalSession is the audit record of a browser terminal session on a node
+type NodeTerminalSession struct {
+	ID                  string     `json:"id"`
+	NodeID              string     `json:"nodeId"`
+	NodeOwnerID         string     `json:"nodeOwnerId"` // Kept to find the session after the node is deleted
+	UserID              string     `json:"userId"`
+	Role                string     `json:"role"`
+	RemoteAddr          string     `json:"remoteAddr"`
+	StartedAt           time.Time  `json:"startedAt"`
+	EndedAt             *time.Time `json:"endedAt,omitempty"`
+	EndReason           string     `json:"endReason,omitempty"` // closed, exited, timeout
+	BytesIn             int64      `json:"bytesIn"`
+	BytesOut            int64      `json:"bytesOut"`
+	Transcript          bool       `json:"transcript"` // Whether the output was recorded
+	TranscriptTruncated bool       `json:"transcriptTruncated,omitempty"`
+}
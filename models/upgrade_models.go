@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// NodeUpgradeRequest asks for a single node to move to another client version
+type NodeUpgradeRequest struct {
+	TargetVersion        string `json:"targetVersion"`
+	HealthTimeoutMinutes int    `json:"healthTimeoutMinutes,omitempty"` // How long the node gets to catch up before rolling back
+}
+
+// FleetUpgradeRequest asks for several nodes to be upgraded in batches
+type FleetUpgradeRequest struct {
+	NodeIDs              []string `json:"nodeIds"` // Nodes to upgrade, empty means all running nodes
+	Client               string   `json:"client"`  // Client the version belongs to, defaults to agave
+	TargetVersion        string   `json:"targetVersion"`
+	BatchSize            int      `json:"batchSize"` // Nodes upgraded at the same time, defaults to 1
+	HealthTimeoutMinutes int      `json:"healthTimeoutMinutes,omitempty"`
+}
+
+// NodeUpgrade records a client version upgrade on one node
+type NodeUpgrade struct {
+	ID          string    `json:"id"`
+	NodeID      string    `json:"nodeId"`
+	UserID      string    `json:"userId"`
+	RolloutID   string    `json:"rolloutId,omitempty"`
+	Client      string    `json:"client"`
+	FromVersion string    `json:"fromVersion"`
+	ToVersion   string    `json:"toVersion"`
+	Status      string    `json:"status"` // pending, running, succeeded, rolled_back, failed, skipped
+	Message     string    `json:"message"`
+	Output      string    `json:"output,omitempty"` // Tail of the upgrade script output
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// UpgradeRollout is a rolling upgrade across several nodes
+type UpgradeRollout struct {
+	ID            string        `json:"id"`
+	UserID        string        `json:"userId"`
+	TargetVersion string        `json:"targetVersion"`
+	BatchSize     int           `json:"batchSize"`
+	Status        string        `json:"status"` // running, succeeded, halted
+	Message       string        `json:"message"`
+	Upgrades      []NodeUpgrade `json:"upgrades,omitempty"`
+	CreatedAt     time.Time     `json:"createdAt"`
+	UpdatedAt     time.Time     `json:"updatedAt"`
+}
@@ -11,3 +11,9 @@ type User struct {
 	CreatedAt      time.Time  `json:"createdAt"`
 	LastLoginAt    *time.Time `json:"lastLoginAt,omitempty"`
 }
+
+// User roles. Admins are listed in ADMIN_EMAILS, everyone else is a user.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
@@ -30,21 +30,89 @@ func SetupRouter() *mux.Router {
 	protected.HandleFunc("/aws/status", handlers.AWSStatusHandler).Methods("GET")
 	protected.HandleFunc("/aws/disconnect", handlers.DisconnectAWSHandler).Methods("POST")
 
+	// GCP Integration routes
+	protected.HandleFunc("/gcp/test-connection", handlers.TestGCPConnectionHandler).Methods("POST")
+	protected.HandleFunc("/gcp/integrate", handlers.IntegrateGCPHandler).Methods("POST")
+	protected.HandleFunc("/gcp/status", handlers.GCPStatusHandler).Methods("GET")
+	protected.HandleFunc("/gcp/disconnect", handlers.DisconnectGCPHandler).Methods("POST")
+
+	// Bare-metal Integration routes
+	protected.HandleFunc("/baremetal/test-connection", handlers.TestBareMetalConnectionHandler).Methods("POST")
+	protected.HandleFunc("/baremetal/integrate", handlers.IntegrateBareMetalHandler).Methods("POST")
+	protected.HandleFunc("/baremetal/status", handlers.BareMetalStatusHandler).Methods("GET")
+	protected.HandleFunc("/baremetal/disconnect", handlers.DisconnectBareMetalHandler).Methods("POST")
+
+	// Catalog routes
+	protected.HandleFunc("/catalog/instance-types", handlers.GetInstanceCatalogHandler).Methods("GET")
+	protected.HandleFunc("/catalog/regions", handlers.GetRegionsHandler).Methods("GET")
+	protected.HandleFunc("/catalog/clients", handlers.GetValidatorClientsHandler).Methods("GET")
+
+	// Cluster routes
+	protected.HandleFunc("/clusters", handlers.ListClustersHandler).Methods("GET")
+	protected.HandleFunc("/clusters", handlers.CreateClusterHandler).Methods("POST")
+	protected.HandleFunc("/clusters/{id}", handlers.UpdateClusterHandler).Methods("PUT")
+	protected.HandleFunc("/clusters/{id}", handlers.DeleteClusterHandler).Methods("DELETE")
+
 	// Node routes
 	protected.HandleFunc("/nodes/deploy", handlers.DeployNodeHandler).Methods("POST")
+	protected.HandleFunc("/nodes/preflight", handlers.PreflightNodeHandler).Methods("POST")
+	protected.HandleFunc("/nodes/render", handlers.RenderNodeScriptsHandler).Methods("POST")
+	protected.HandleFunc("/nodes/upgrade", handlers.FleetUpgradeHandler).Methods("POST")
 	protected.HandleFunc("/nodes", handlers.ListNodesHandler).Methods("GET")
 	protected.HandleFunc("/nodes/{id}", handlers.GetNodeHandler).Methods("GET")
 	protected.HandleFunc("/nodes/{id}", handlers.DeleteNodeHandler).Methods("DELETE")
 	protected.HandleFunc("/nodes/{id}/status", handlers.GetNodeStatusHandler).Methods("GET")
 	protected.HandleFunc("/nodes/{id}/ssh-key", handlers.GetNodeSSHKeyHandler).Methods("GET")
+	protected.HandleFunc("/nodes/{id}/logs/stream", handlers.StreamNodeLogsHandler).Methods("GET")
 	// Node control actions
 	protected.HandleFunc("/nodes/{id}/start", handlers.StartNodeHandler).Methods("POST")
 	protected.HandleFunc("/nodes/{id}/stop", handlers.StopNodeHandler).Methods("POST")
 	protected.HandleFunc("/nodes/{id}/reboot", handlers.RebootNodeHandler).Methods("POST")
+	protected.HandleFunc("/nodes/{id}/retry", handlers.RetryNodeHandler).Methods("POST")
+	protected.HandleFunc("/nodes/{id}/upgrade", handlers.UpgradeNodeHandler).Methods("POST")
+	protected.HandleFunc("/nodes/{id}/upgrades", handlers.GetNodeUpgradesHandler).Methods("GET")
+	protected.HandleFunc("/nodes/{id}/config", handlers.UpdateNodeConfigHandler).Methods("PATCH")
+	protected.HandleFunc("/nodes/{id}/config/revisions", handlers.ListNodeConfigRevisionsHandler).Methods("GET")
+	protected.HandleFunc("/nodes/{id}/config/revisions/{revision}", handlers.GetNodeConfigRevisionHandler).Methods("GET")
+	protected.HandleFunc("/nodes/{id}/config/diff", handlers.DiffNodeConfigRevisionsHandler).Methods("GET")
+	protected.HandleFunc("/nodes/{id}/config/rollback", handlers.RollbackNodeConfigHandler).Methods("POST")
+	// Node agent routes
+	protected.HandleFunc("/nodes/{id}/agent", handlers.GetNodeAgentHandler).Methods("GET")
+	protected.HandleFunc("/nodes/{id}/agent/commands", handlers.QueueAgentCommandHandler).Methods("POST")
+	protected.HandleFunc("/nodes/{id}/agent/commands", handlers.ListAgentCommandsHandler).Methods("GET")
+	protected.HandleFunc("/nodes/{id}/agent/commands/{commandId}", handlers.GetAgentCommandHandler).Methods("GET")
+	// Log bundles uploaded by nodes
+	protected.HandleFunc("/nodes/{id}/log-bundles", handlers.ListNodeLogBundlesHandler).Methods("GET")
+	protected.HandleFunc("/nodes/{id}/log-bundles", handlers.RequestNodeLogBundleHandler).Methods("POST")
+	protected.HandleFunc("/nodes/{id}/log-bundles/{bundleId}", handlers.DownloadNodeLogBundleHandler).Methods("GET")
+	// Browser terminal sessions
+	protected.HandleFunc("/nodes/{id}/terminal", handlers.NodeTerminalHandler).Methods("GET")
+	protected.HandleFunc("/nodes/{id}/terminal/sessions", handlers.ListNodeTerminalSessionsHandler).Methods("GET")
+	protected.HandleFunc("/nodes/{id}/terminal/sessions/{sessionId}/transcript", handlers.DownloadNodeTerminalTranscriptHandler).Methods("GET")
+
+	// Rolling upgrade routes
+	protected.HandleFunc("/upgrades/{id}", handlers.GetUpgradeRolloutHandler).Methods("GET")
+
+	// Admin routes
+	admin := protected.PathPrefix("/admin").Subrouter()
+	admin.Use(middleware.AdminMiddleware)
+
+	admin.HandleFunc("/clusters", handlers.AdminCreateClusterHandler).Methods("POST")
+	admin.HandleFunc("/clusters/{id}", handlers.AdminUpdateClusterHandler).Methods("PUT")
+	admin.HandleFunc("/clusters/{id}", handlers.AdminDeleteClusterHandler).Methods("DELETE")
 
 	// Public callback endpoint for node deployment updates
 	// This endpoint doesn't use AuthMiddleware because the VM needs to call it
 	router.HandleFunc("/api/node-status/{id}/{token}", handlers.UpdateNodeStatusHandler).Methods("POST")
+	router.HandleFunc("/api/node-status/{id}/{token}/batch", handlers.UpdateNodeStatusBatchHandler).Methods("POST")
+	router.HandleFunc("/api/node-secrets/{id}/{token}/{name}", handlers.GetNodeSecretHandler).Methods("GET")
+	router.HandleFunc("/api/node-heartbeat/{id}/{token}", handlers.NodeHeartbeatHandler).Methods("POST")
+	router.HandleFunc("/api/node-logs/{id}/{token}", handlers.UploadNodeLogBundleHandler).Methods("POST")
+
+	// Node agents connect out to the API and authenticate with their deployment token
+	router.HandleFunc("/api/agent/{id}/connect", handlers.AgentConnectHandler).Methods("GET")
+	router.HandleFunc("/api/agent/binary/{arch}", handlers.GetAgentBinaryHandler).Methods("GET")
+	router.HandleFunc("/api/agent/binary/{arch}/sha256", handlers.GetAgentBinaryChecksumHandler).Methods("GET")
 
 	return router
 }
@@ -0,0 +1,491 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/0saurabh0/NodeEase/db/repository"
+	"github.com/0saurabh0/NodeEase/models"
+	"github.com/google/uuid"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// agentReadTimeout drops an agent that stays silent for three heartbeat intervals
+	agentReadTimeout = 3 * heartbeatInterval
+
+	// agentWriteTimeout bounds sending a frame to an agent
+	agentWriteTimeout = 10 * time.Second
+
+	// agentCommandTTL is how long a command waits for a disconnected agent.
+	// Older commands are expired instead of run when the agent reconnects.
+	agentCommandTTL = 15 * time.Minute
+
+	// agentCommandTimeout is how long the agent lets a queued command run
+	agentCommandTimeout = 2 * time.Minute
+
+	// Lines returned by tail_logs
+	agentDefaultTailLines = 100
+	agentMaxTailLines     = 1000
+
+	// agentCommandHistory is how many commands are listed per node
+	agentCommandHistory = 50
+
+	// defaultAgentBinaryDir holds the agent builds nodes download, named
+	// nodeease-agent-linux-<arch>. AGENT_BINARY_DIR overrides it.
+	defaultAgentBinaryDir = "bin"
+)
+
+// agentUserCommands are the commands users can queue through the API. Config
+// and upgrade scripts are only sent by NodeEase itself.
+var agentUserCommands = map[string]bool{
+	models.AgentCommandRestartService: true,
+	models.AgentCommandTailLogs:       true,
+	models.AgentCommandUploadLogs:     true,
+}
+
+// agentSession is a connected node agent
+type agentSession struct {
+	ws          *websocket.Conn
+	connectedAt time.Time
+
+	mu      sync.Mutex
+	pending map[string]models.AgentCommand // Commands sent and awaiting a result
+}
+
+// agentSessions holds the connected agent of each node
+var (
+	agentSessions   = map[string]*agentSession{}
+	agentSessionsMu sync.Mutex
+)
+
+// agentWaiters receive the results of commands NodeEase is waiting on
+var (
+	agentWaiters   = map[string]chan models.AgentCommandResult{}
+	agentWaitersMu sync.Mutex
+)
+
+// send writes a frame to the agent
+func (s *agentSession) send(msg models.AgentMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ws.SetWriteDeadline(time.Now().Add(agentWriteTimeout))
+	return websocket.JSON.Send(s.ws, msg)
+}
+
+// connectedAgent returns the connected agent of a node, if any
+func connectedAgent(nodeID string) *agentSession {
+	agentSessionsMu.Lock()
+	defer agentSessionsMu.Unlock()
+
+	return agentSessions[nodeID]
+}
+
+// AuthenticateAgent checks the token an agent connects with
+func AuthenticateAgent(nodeID, token string) error {
+	return AuthenticateNode(nodeID, token)
+}
+
+// ServeAgent runs an authenticated agent connection until it closes
+func ServeAgent(nodeID string, ws *websocket.Conn) {
+	session := &agentSession{
+		ws:          ws,
+		connectedAt: time.Now(),
+		pending:     map[string]models.AgentCommand{},
+	}
+
+	// A node has one agent, a new connection replaces a stale one
+	agentSessionsMu.Lock()
+	previous := agentSessions[nodeID]
+	agentSessions[nodeID] = session
+	agentSessionsMu.Unlock()
+	if previous != nil {
+		previous.ws.Close()
+	}
+
+	defer closeAgentSession(nodeID, session)
+
+	log.Printf("Agent connected for node %s", nodeID)
+
+	deliverQueuedAgentCommands(nodeID, session)
+
+	for {
+		ws.SetReadDeadline(time.Now().Add(agentReadTimeout))
+
+		var msg models.AgentMessage
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			if err != io.EOF {
+				log.Printf("Agent connection for node %s closed: %v", nodeID, err)
+			}
+			return
+		}
+
+		switch msg.Type {
+		case models.AgentMessageHeartbeat:
+			if msg.Heartbeat != nil {
+				recordAgentHeartbeat(nodeID, *msg.Heartbeat)
+			}
+		case models.AgentMessageResult:
+			if msg.Result != nil {
+				completeAgentCommand(session, *msg.Result)
+			}
+		}
+	}
+}
+
+// closeAgentSession forgets a closed connection and fails the commands it
+// never reported back on
+func closeAgentSession(nodeID string, session *agentSession) {
+	session.ws.Close()
+
+	agentSessionsMu.Lock()
+	if agentSessions[nodeID] == session {
+		delete(agentSessions, nodeID)
+	}
+	agentSessionsMu.Unlock()
+
+	session.mu.Lock()
+	pending := session.pending
+	session.pending = map[string]models.AgentCommand{}
+	session.mu.Unlock()
+
+	for _, command := range pending {
+		finishAgentCommand(command, models.AgentCommandResult{
+			CommandID: command.ID,
+			Error:     "agent disconnected before reporting a result",
+		})
+	}
+
+	log.Printf("Agent disconnected for node %s", nodeID)
+}
+
+// recordAgentHeartbeat stores a heartbeat sent over an agent connection
+func recordAgentHeartbeat(nodeID string, heartbeat models.NodeHeartbeat) {
+	node, err := repository.GetNodeByIDInternal(nodeID)
+	if err == nil && node.ID != "" {
+		err = saveNodeHeartbeat(node, heartbeat)
+	}
+	if err != nil {
+		log.Printf("Failed to save heartbeat for node %s: %v", nodeID, err)
+	}
+}
+
+// dispatchAgentCommand claims a queued command and sends it to a connected
+// agent. A command another connection already claimed is left alone.
+func dispatchAgentCommand(session *agentSession, command models.AgentCommand) error {
+	claimed, err := repository.ClaimAgentCommand(command.ID)
+	if err != nil {
+		return fmt.Errorf("failed to claim agent command: %v", err)
+	}
+	if !claimed {
+		return nil
+	}
+
+	command.Status = "sent"
+	session.mu.Lock()
+	session.pending[command.ID] = command
+	session.mu.Unlock()
+
+	if err := session.send(models.AgentMessage{Type: models.AgentMessageCommand, Command: &command}); err != nil {
+		session.mu.Lock()
+		delete(session.pending, command.ID)
+		session.mu.Unlock()
+
+		// Release the claim so the next connection picks it up
+		command.Status = "queued"
+		command.UpdatedAt = time.Now()
+		if err := repository.SaveAgentCommand(command); err != nil {
+			log.Printf("Failed to save agent command %s: %v", command.ID, err)
+		}
+
+		// The read loop notices the broken connection and cleans up
+		session.ws.Close()
+		return fmt.Errorf("failed to send command to agent: %v", err)
+	}
+
+	return nil
+}
+
+// deliverQueuedAgentCommands sends the commands queued while the agent was away
+func deliverQueuedAgentCommands(nodeID string, session *agentSession) {
+	commands, err := repository.GetQueuedAgentCommands(nodeID)
+	if err != nil {
+		log.Printf("Failed to load queued agent commands for node %s: %v", nodeID, err)
+		return
+	}
+
+	for _, command := range commands {
+		// Scripts are only held by whoever is waiting on them, which sends them itself
+		if !agentUserCommands[command.Type] {
+			continue
+		}
+
+		if time.Since(command.CreatedAt) > agentCommandTTL {
+			command.Status = "expired"
+			command.Output = "The agent did not connect in time"
+			command.UpdatedAt = time.Now()
+			if err := repository.SaveAgentCommand(command); err != nil {
+				log.Printf("Failed to save agent command %s: %v", command.ID, err)
+			}
+			continue
+		}
+
+		if err := dispatchAgentCommand(session, command); err != nil {
+			log.Printf("Failed to deliver agent command %s: %v", command.ID, err)
+			return
+		}
+	}
+}
+
+// completeAgentCommand records a result reported by an agent
+func completeAgentCommand(session *agentSession, result models.AgentCommandResult) {
+	session.mu.Lock()
+	command, ok := session.pending[result.CommandID]
+	delete(session.pending, result.CommandID)
+	session.mu.Unlock()
+
+	if !ok {
+		log.Printf("Ignoring result for unknown agent command %s", result.CommandID)
+		return
+	}
+
+	finishAgentCommand(command, result)
+}
+
+// finishAgentCommand saves the outcome of a command and hands it to anyone waiting on it
+func finishAgentCommand(command models.AgentCommand, result models.AgentCommandResult) {
+	command.ExitCode = result.ExitCode
+	command.Output = result.Output
+	command.Status = "succeeded"
+	switch {
+	case result.Error != "":
+		command.Status = "failed"
+		command.Output = result.Error
+	case result.ExitCode != 0:
+		command.Status = "failed"
+	}
+	command.UpdatedAt = time.Now()
+
+	if err := repository.SaveAgentCommand(command); err != nil {
+		log.Printf("Failed to save agent command %s: %v", command.ID, err)
+	}
+
+	agentWaitersMu.Lock()
+	waiter, ok := agentWaiters[command.ID]
+	delete(agentWaiters, command.ID)
+	agentWaitersMu.Unlock()
+
+	if ok {
+		waiter <- result
+	}
+}
+
+// newAgentCommand creates a queued command record
+func newAgentCommand(node models.Node, commandType string) models.AgentCommand {
+	now := time.Now()
+	return models.AgentCommand{
+		ID:             uuid.New().String(),
+		NodeID:         node.ID,
+		UserID:         node.UserID,
+		Type:           commandType,
+		TimeoutSeconds: int(agentCommandTimeout.Seconds()),
+		Status:         "queued",
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+}
+
+// QueueAgentCommand queues a command for a node's agent and sends it right
+// away if the agent is connected
+func QueueAgentCommand(nodeID, userID string, req models.AgentCommandRequest) (models.AgentCommand, error) {
+	node, err := repository.GetNodeByID(nodeID, userID)
+	if err != nil {
+		return models.AgentCommand{}, err
+	}
+	if node.ID == "" {
+		return models.AgentCommand{}, fmt.Errorf("node not found or you don't have permission")
+	}
+	if err := requireBootstrappedNode(node, "managed by the agent"); err != nil {
+		return models.AgentCommand{}, err
+	}
+
+	if !agentUserCommands[req.Type] {
+		return models.AgentCommand{}, fmt.Errorf("unsupported agent command %q", req.Type)
+	}
+
+	command := newAgentCommand(node, req.Type)
+	if req.Type == models.AgentCommandTailLogs {
+		command.Lines = req.Lines
+		if command.Lines <= 0 {
+			command.Lines = agentDefaultTailLines
+		}
+		if command.Lines > agentMaxTailLines {
+			return models.AgentCommand{}, fmt.Errorf("at most %d log lines can be requested", agentMaxTailLines)
+		}
+	}
+
+	if err := repository.SaveAgentCommand(command); err != nil {
+		return models.AgentCommand{}, fmt.Errorf("failed to save agent command: %v", err)
+	}
+
+	if session := connectedAgent(node.ID); session != nil {
+		if err := dispatchAgentCommand(session, command); err != nil {
+			// It stays queued for the next connection
+			log.Printf("Failed to deliver agent command %s: %v", command.ID, err)
+		} else {
+			command.Status = "sent"
+		}
+	}
+
+	return command, nil
+}
+
+// GetAgentCommands lists the recent agent commands of a node
+func GetAgentCommands(nodeID, userID string) ([]models.AgentCommand, error) {
+	return repository.GetAgentCommands(nodeID, userID, agentCommandHistory)
+}
+
+// GetAgentCommand retrieves an agent command, including its output once finished
+func GetAgentCommand(commandID, nodeID, userID string) (models.AgentCommand, error) {
+	command, err := repository.GetAgentCommand(commandID, nodeID, userID)
+	if err != nil {
+		return models.AgentCommand{}, err
+	}
+	if command.ID == "" {
+		return models.AgentCommand{}, fmt.Errorf("command not found")
+	}
+	return command, nil
+}
+
+// GetNodeAgentStatus reports whether a node's agent is connected and its last heartbeat
+func GetNodeAgentStatus(nodeID, userID string) (models.NodeAgentStatus, error) {
+	node, err := repository.GetNodeByID(nodeID, userID)
+	if err != nil {
+		return models.NodeAgentStatus{}, err
+	}
+	if node.ID == "" {
+		return models.NodeAgentStatus{}, fmt.Errorf("node not found or you don't have permission")
+	}
+
+	var status models.NodeAgentStatus
+	if session := connectedAgent(node.ID); session != nil {
+		status.Connected = true
+		status.ConnectedAt = &session.connectedAt
+	}
+
+	status.Heartbeat, err = repository.GetNodeHeartbeat(node.ID)
+	if err != nil {
+		return models.NodeAgentStatus{}, err
+	}
+
+	return status, nil
+}
+
+// runAgentScript runs a script on a node through its connected agent and
+// waits for the result, mirroring runNodeScript
+func runAgentScript(session *agentSession, node models.Node, commandType, script string, timeout time.Duration) (string, int, error) {
+	command := newAgentCommand(node, commandType)
+	command.Script = script
+	command.TimeoutSeconds = int(timeout.Seconds())
+
+	if err := repository.SaveAgentCommand(command); err != nil {
+		return "", 0, fmt.Errorf("failed to save agent command: %v", err)
+	}
+
+	waiter := make(chan models.AgentCommandResult, 1)
+	agentWaitersMu.Lock()
+	agentWaiters[command.ID] = waiter
+	agentWaitersMu.Unlock()
+
+	defer func() {
+		agentWaitersMu.Lock()
+		delete(agentWaiters, command.ID)
+		agentWaitersMu.Unlock()
+	}()
+
+	if err := dispatchAgentCommand(session, command); err != nil {
+		finishAgentCommand(command, models.AgentCommandResult{CommandID: command.ID, Error: err.Error()})
+		return "", 0, err
+	}
+
+	// Leave the agent a little longer than the script so it can report a timeout itself
+	select {
+	case result := <-waiter:
+		if result.Error != "" {
+			return result.Output, 0, fmt.Errorf("script did not complete: %s", result.Error)
+		}
+		return result.Output, result.ExitCode, nil
+	case <-time.After(timeout + time.Minute):
+		return "", 0, fmt.Errorf("agent did not report a result in time")
+	}
+}
+
+// execNodeScript runs a bash script as root on a node, through its agent when
+// one is connected and over SSH otherwise
+func execNodeScript(node models.Node, commandType, script string, timeout time.Duration) (string, int, error) {
+	if session := connectedAgent(node.ID); session != nil {
+		return runAgentScript(session, node, commandType, script, timeout)
+	}
+	return runNodeScript(node, script, timeout)
+}
+
+// AgentBinaryPath returns the agent build nodes of an architecture download
+func AgentBinaryPath(arch string) (string, error) {
+	if arch != "amd64" && arch != "arm64" {
+		return "", fmt.Errorf("unsupported architecture %q", arch)
+	}
+
+	dir := os.Getenv("AGENT_BINARY_DIR")
+	if dir == "" {
+		dir = defaultAgentBinaryDir
+	}
+
+	path := filepath.Join(dir, "nodeease-agent-linux-"+arch)
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("agent binary for %s is not available", arch)
+	}
+
+	return path, nil
+}
+
+// AgentBinarySHA256 returns the hex SHA-256 of the agent build for an
+// architecture. Nodes check the build they download against it.
+func AgentBinarySHA256(arch string) (string, error) {
+	path, err := AgentBinaryPath(arch)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read agent binary: %v", err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", fmt.Errorf("failed to read agent binary: %v", err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// agentBinaryChecksums returns the SHA-256 of every agent build available,
+// by architecture
+func agentBinaryChecksums() map[string]string {
+	checksums := map[string]string{}
+	for _, arch := range []string{"amd64", "arm64"} {
+		if sum, err := AgentBinarySHA256(arch); err == nil {
+			checksums[arch] = sum
+		}
+	}
+	return checksums
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+const (
+	// canonicalOwnerID is the AWS account Canonical publishes Ubuntu images from
+	canonicalOwnerID = "099720109477"
+
+	// DefaultOSRelease is the Ubuntu release used when a request doesn't specify one
+	DefaultOSRelease = "22.04"
+
+	// DefaultArchitecture is the CPU architecture used when a request doesn't specify one
+	DefaultArchitecture = "amd64"
+
+	// amiCacheTTL controls how long a resolved AMI ID is reused
+	amiCacheTTL = 6 * time.Hour
+)
+
+// ubuntuRelease describes where Canonical publishes images for a release
+type ubuntuRelease struct {
+	Codename   string // jammy, noble
+	VolumeType string // path segment used by the SSM parameter (ebs-gp2, ebs-gp3)
+	NamePrefix string // image name prefix used by DescribeImages
+}
+
+// supportedReleases lists the Ubuntu releases we can resolve images for
+var supportedReleases = map[string]ubuntuRelease{
+	"22.04": {Codename: "jammy", VolumeType: "ebs-gp2", NamePrefix: "ubuntu/images/hvm-ssd"},
+	"24.04": {Codename: "noble", VolumeType: "ebs-gp3", NamePrefix: "ubuntu/images/hvm-ssd-gp3"},
+}
+
+// supportedArchitectures maps our architecture names to the EC2 image architecture
+var supportedArchitectures = map[string]string{
+	"amd64": "x86_64",
+	"arm64": "arm64",
+}
+
+type cachedAMI struct {
+	ImageID    string
+	ResolvedAt time.Time
+}
+
+var (
+	amiCache   = map[string]cachedAMI{}
+	amiCacheMu sync.Mutex
+)
+
+// resolveSolanaAMI returns the image to launch a node with. A custom AMI ID is
+// verified and used as-is, otherwise the latest Canonical Ubuntu image for the
+// release and architecture is looked up in the session's region.
+func resolveSolanaAMI(sess *session.Session, customAMI, osRelease, architecture string) (string, error) {
+	region := aws.StringValue(sess.Config.Region)
+
+	if customAMI != "" {
+		return verifyCustomAMI(ec2.New(sess), region, customAMI)
+	}
+
+	if osRelease == "" {
+		osRelease = DefaultOSRelease
+	}
+	if architecture == "" {
+		architecture = DefaultArchitecture
+	}
+
+	release, ok := supportedReleases[osRelease]
+	if !ok {
+		return "", fmt.Errorf("unsupported OS release %q", osRelease)
+	}
+	if _, ok := supportedArchitectures[architecture]; !ok {
+		return "", fmt.Errorf("unsupported architecture %q", architecture)
+	}
+
+	// Check the cache first
+	cacheKey := fmt.Sprintf("%s|%s|%s", region, osRelease, architecture)
+	amiCacheMu.Lock()
+	if cached, ok := amiCache[cacheKey]; ok && time.Since(cached.ResolvedAt) < amiCacheTTL {
+		amiCacheMu.Unlock()
+		return cached.ImageID, nil
+	}
+	amiCacheMu.Unlock()
+
+	// Prefer the SSM parameter Canonical maintains, then fall back to searching
+	// the owner's images in case SSM isn't reachable with these credentials
+	imageID, ssmErr := lookupAMIFromSSM(ssm.New(sess), release, osRelease, architecture)
+	if ssmErr != nil {
+		var err error
+		imageID, err = lookupAMIFromImages(ec2.New(sess), release, osRelease, architecture)
+		if err != nil {
+			return "", fmt.Errorf("no Ubuntu %s %s image found in %s (ssm: %v, describe images: %v)",
+				osRelease, architecture, region, ssmErr, err)
+		}
+	}
+
+	amiCacheMu.Lock()
+	amiCache[cacheKey] = cachedAMI{ImageID: imageID, ResolvedAt: time.Now()}
+	amiCacheMu.Unlock()
+
+	return imageID, nil
+}
+
+// lookupAMIFromSSM reads the current image ID from Canonical's public SSM parameter
+func lookupAMIFromSSM(ssmClient *ssm.SSM, release ubuntuRelease, osRelease, architecture string) (string, error) {
+	name := fmt.Sprintf("/aws/service/canonical/ubuntu/server/%s/stable/current/%s/hvm/%s/ami-id",
+		osRelease, architecture, release.VolumeType)
+
+	output, err := ssmClient.GetParameter(&ssm.GetParameterInput{
+		Name: aws.String(name),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if output.Parameter == nil || aws.StringValue(output.Parameter.Value) == "" {
+		return "", fmt.Errorf("parameter %s has no value", name)
+	}
+
+	return aws.StringValue(output.Parameter.Value), nil
+}
+
+// lookupAMIFromImages finds the newest matching Canonical image with DescribeImages
+func lookupAMIFromImages(ec2Client *ec2.EC2, release ubuntuRelease, osRelease, architecture string) (string, error) {
+	namePattern := fmt.Sprintf("%s/ubuntu-%s-%s-%s-server-*", release.NamePrefix, release.Codename, osRelease, architecture)
+
+	output, err := ec2Client.DescribeImages(&ec2.DescribeImagesInput{
+		Owners: []*string{aws.String(canonicalOwnerID)},
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("name"),
+				Values: []*string{aws.String(namePattern)},
+			},
+			{
+				Name:   aws.String("architecture"),
+				Values: []*string{aws.String(supportedArchitectures[architecture])},
+			},
+			{
+				Name:   aws.String("state"),
+				Values: []*string{aws.String("available")},
+			},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(output.Images) == 0 {
+		return "", fmt.Errorf("no images match %s", namePattern)
+	}
+
+	// CreationDate is RFC3339, so string order is chronological
+	sort.Slice(output.Images, func(i, j int) bool {
+		return aws.StringValue(output.Images[i].CreationDate) > aws.StringValue(output.Images[j].CreationDate)
+	})
+
+	return aws.StringValue(output.Images[0].ImageId), nil
+}
+
+// verifyCustomAMI checks that a user-pinned image exists and is usable in the region
+func verifyCustomAMI(ec2Client *ec2.EC2, region, imageID string) (string, error) {
+	output, err := ec2Client.DescribeImages(&ec2.DescribeImagesInput{
+		ImageIds: []*string{aws.String(imageID)},
+	})
+	if err != nil {
+		return "", fmt.Errorf("custom AMI %s not found in %s: %v", imageID, region, err)
+	}
+
+	if len(output.Images) == 0 {
+		return "", fmt.Errorf("custom AMI %s not found in %s", imageID, region)
+	}
+
+	if state := aws.StringValue(output.Images[0].State); state != "available" {
+		return "", fmt.Errorf("custom AMI %s is %s, not available", imageID, state)
+	}
+
+	return imageID, nil
+}
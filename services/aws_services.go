@@ -2,6 +2,8 @@ package services
 
 import (
 	"errors"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/0saurabh0/NodeEase/db/repository"
@@ -14,6 +16,32 @@ import (
 	"github.com/google/uuid"
 )
 
+// awsSessionCache holds AWS sessions keyed by user and region so that a single
+// integration can manage nodes in several regions without re-decrypting
+// credentials on every call
+var (
+	awsSessionCache   = map[string]*session.Session{}
+	awsSessionCacheMu sync.Mutex
+)
+
+// awsSessionCacheKey builds the cache key for a user's session in a region
+func awsSessionCacheKey(userID, region string) string {
+	return userID + "|" + region
+}
+
+// invalidateAWSSessions drops all cached sessions for a user
+func invalidateAWSSessions(userID string) {
+	awsSessionCacheMu.Lock()
+	defer awsSessionCacheMu.Unlock()
+
+	prefix := userID + "|"
+	for key := range awsSessionCache {
+		if strings.HasPrefix(key, prefix) {
+			delete(awsSessionCache, key)
+		}
+	}
+}
+
 // SaveAWSIntegration saves or updates AWS integration for a user
 func SaveAWSIntegration(userID string, integration models.Integration) error {
 	// Set timestamps
@@ -27,15 +55,25 @@ func SaveAWSIntegration(userID string, integration models.Integration) error {
 		integration.CreatedAt = existing.CreatedAt
 		integration.UpdatedAt = now
 
-		return repository.UpdateIntegration(integration)
+		err = repository.UpdateIntegration(integration)
+	} else {
+		// Create new integration
+		integration.ID = uuid.New().String()
+		integration.CreatedAt = now
+		integration.UpdatedAt = now
+
+		err = repository.SaveIntegration(integration)
+	}
+	if err != nil {
+		return err
 	}
 
-	// Create new integration
-	integration.ID = uuid.New().String()
-	integration.CreatedAt = now
-	integration.UpdatedAt = now
+	// Credentials may have changed, so cached sessions are no longer valid.
+	// Dropping them after the save keeps a concurrent lookup from caching the
+	// old credentials again.
+	invalidateAWSSessions(userID)
 
-	return repository.SaveIntegration(integration)
+	return nil
 }
 
 // GetAWSIntegrationByUserID retrieves AWS integration for a user
@@ -47,8 +85,16 @@ func GetAWSIntegrationByUserID(userID string) (models.Integration, error) {
 	return integration, nil
 }
 
-// GetAWSSession creates an AWS session using stored credentials
+// GetAWSSession creates an AWS session using stored credentials in the
+// integration's default region
 func GetAWSSession(userID string) (*session.Session, error) {
+	return GetAWSSessionForRegion(userID, "")
+}
+
+// GetAWSSessionForRegion returns an AWS session scoped to the given region using
+// the user's stored credentials. An empty region falls back to the region saved
+// with the integration. Sessions are cached per user and region.
+func GetAWSSessionForRegion(userID, region string) (*session.Session, error) {
 	integration, err := GetAWSIntegrationByUserID(userID)
 	if err != nil {
 		return nil, err
@@ -60,6 +106,19 @@ func GetAWSSession(userID string) (*session.Session, error) {
 		return nil, errors.New("invalid AWS integration data format")
 	}
 
+	if region == "" {
+		region = awsData.Region
+	}
+
+	// Reuse a cached session for this region if we have one
+	cacheKey := awsSessionCacheKey(userID, region)
+	awsSessionCacheMu.Lock()
+	if sess, ok := awsSessionCache[cacheKey]; ok {
+		awsSessionCacheMu.Unlock()
+		return sess, nil
+	}
+	awsSessionCacheMu.Unlock()
+
 	// Decrypt credentials
 	accessKeyID, err := decrypt(awsData.AccessKeyID)
 	if err != nil {
@@ -73,7 +132,7 @@ func GetAWSSession(userID string) (*session.Session, error) {
 
 	// Create session
 	sess, err := session.NewSession(&aws.Config{
-		Region:      aws.String(awsData.Region),
+		Region:      aws.String(region),
 		Credentials: credentials.NewStaticCredentials(accessKeyID, secretAccessKey, ""),
 	})
 
@@ -81,6 +140,10 @@ func GetAWSSession(userID string) (*session.Session, error) {
 		return nil, err
 	}
 
+	awsSessionCacheMu.Lock()
+	awsSessionCache[cacheKey] = sess
+	awsSessionCacheMu.Unlock()
+
 	return sess, nil
 }
 
@@ -107,5 +170,6 @@ func decrypt(encrypted string) (string, error) {
 }
 
 func DisconnectAWSHandler(userID string) error {
+	invalidateAWSSessions(userID)
 	return repository.DeleteIntegrationByUserAndProvider(userID, "AWS")
 }
@@ -0,0 +1,332 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/0saurabh0/NodeEase/models"
+)
+
+var (
+	// bareMetalSitePattern matches a site slug, e.g. SAO or ASH2
+	bareMetalSitePattern = regexp.MustCompile(`^[A-Za-z0-9-]{2,16}$`)
+
+	// bareMetalPlanPattern matches a plan slug, e.g. c3-large-x86
+	bareMetalPlanPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,63}$`)
+
+	// bareMetalPollInterval is how often server state is polled. Provisioning
+	// bare metal takes several minutes, so this is slower than for VMs.
+	bareMetalPollInterval = 30 * time.Second
+
+	// bareMetalDeployTimeout bounds how long a server may take to provision
+	bareMetalDeployTimeout = 45 * time.Minute
+)
+
+// bareMetalOperatingSystems maps Ubuntu releases to the host's OS slugs
+var bareMetalOperatingSystems = map[string]string{
+	"22.04": "ubuntu_22_04_x64_lts",
+	"24.04": "ubuntu_24_04_x64_lts",
+}
+
+// bareMetalProvider runs nodes on dedicated servers ordered through the host's API
+var bareMetalProvider = nodeProvider{
+	Name:         providerBareMetal,
+	DisplayName:  "Bare metal",
+	Bootstrapped: true,
+	Validate:     validateBareMetalRequest,
+	Preflight:    bareMetalPreflight,
+	Provision:    provisionBareMetalNode,
+	Start:        startBareMetalNode,
+	Stop:         stopBareMetalNode,
+	Reboot:       rebootBareMetalNode,
+	Delete:       deleteBareMetalNode,
+}
+
+// bareMetalOS returns the OS slug for a request
+func bareMetalOS(req models.NodeDeployRequest) (string, error) {
+	osRelease := req.OSRelease
+	if osRelease == "" {
+		osRelease = DefaultOSRelease
+	}
+
+	slug, ok := bareMetalOperatingSystems[osRelease]
+	if !ok {
+		return "", fmt.Errorf("unsupported OS release %q", osRelease)
+	}
+	return slug, nil
+}
+
+// validateBareMetalRequest checks the site and plan of a bare-metal deploy
+// request. Region holds the site, InstanceType the plan and DiskSize the
+// plan's usable disk.
+func validateBareMetalRequest(userID string, req models.NodeDeployRequest) error {
+	if !bareMetalSitePattern.MatchString(req.Region) {
+		return fmt.Errorf("invalid site %q", req.Region)
+	}
+	if !bareMetalPlanPattern.MatchString(req.InstanceType) {
+		return fmt.Errorf("invalid plan %q", req.InstanceType)
+	}
+	if req.DiskSize <= 0 {
+		return fmt.Errorf("disk size is required")
+	}
+	if req.Architecture != "" && req.Architecture != "amd64" {
+		return fmt.Errorf("bare-metal servers only run amd64")
+	}
+	if _, err := bareMetalOS(req); err != nil {
+		return err
+	}
+
+	return rejectEC2Options(req, "bare metal")
+}
+
+// bareMetalPreflight checks the API token and that the plan is in stock at the site
+func bareMetalPreflight(userID string, req models.NodeDeployRequest) (models.PreflightResult, error) {
+	client, projectID, err := getBareMetalClient(userID)
+	if err != nil {
+		return models.PreflightResult{}, err
+	}
+
+	result := models.PreflightResult{
+		Passed: true,
+		Region: req.Region,
+	}
+
+	add := func(check models.PreflightCheck) {
+		if !check.Passed {
+			result.Passed = false
+		}
+		result.Checks = append(result.Checks, check)
+	}
+
+	err = client.getProject(projectID)
+	accessCheck := models.PreflightCheck{
+		Name:        "api_access",
+		Description: "The API token can manage servers in the project",
+		Passed:      err == nil,
+	}
+	if err != nil {
+		accessCheck.Message = err.Error()
+		accessCheck.Remediation = "Reconnect the bare-metal integration with a valid token and project"
+	}
+	add(accessCheck)
+
+	plan, err := client.getPlan(req.InstanceType)
+	stockCheck := models.PreflightCheck{
+		Name:        "plan_in_stock",
+		Description: fmt.Sprintf("%s is in stock at %s", req.InstanceType, req.Region),
+	}
+	if err != nil {
+		stockCheck.Message = err.Error()
+		stockCheck.Remediation = "Choose a plan the host offers"
+	} else {
+		for _, region := range plan.Regions {
+			for _, site := range region.Locations.InStock {
+				if strings.EqualFold(site, req.Region) {
+					stockCheck.Passed = true
+				}
+			}
+		}
+		if !stockCheck.Passed {
+			stockCheck.Message = fmt.Sprintf("%s is out of stock at %s", req.InstanceType, req.Region)
+			stockCheck.Remediation = "Choose another site or plan"
+		}
+	}
+	add(stockCheck)
+
+	return result, nil
+}
+
+// provisionBareMetalNode orders a server with the node's SSH key and startup
+// script, then follows it until it's powered on
+func provisionBareMetalNode(node models.Node, req models.NodeDeployRequest, userData, publicKey string) {
+	nodeID := node.ID
+
+	client, projectID, err := getBareMetalClient(node.UserID)
+	if err != nil {
+		updateNodeStatus(nodeID, "failed", fmt.Sprintf("Failed to connect to the bare-metal API: %v", err))
+		return
+	}
+
+	server, err := orderBareMetalServer(client, projectID, nodeID, req, userData, publicKey)
+	if err != nil {
+		updateNodeStatus(nodeID, "failed", fmt.Sprintf("Failed to deploy: %v", err))
+		return
+	}
+
+	// Update node with server ID
+	updateNodeInstance(nodeID, server.ID)
+
+	monitorBareMetalDeployment(nodeID, server.ID, client)
+}
+
+// orderBareMetalServer uploads the SSH key and startup script a server is
+// deployed with, orders it and removes both again
+func orderBareMetalServer(client *bareMetalClient, projectID, nodeID string, req models.NodeDeployRequest, userData, publicKey string) (bareMetalServer, error) {
+	name := fmt.Sprintf("nodeease-%s", nodeID[:8])
+	sshKeyID, err := client.createSSHKey(projectID, name, strings.TrimSpace(publicKey))
+	if err != nil {
+		return bareMetalServer{}, fmt.Errorf("failed to upload SSH key: %v", err)
+	}
+	userDataID, err := client.createUserData(projectID, name, userData)
+	if err != nil {
+		client.deleteResource("/ssh_keys/" + url.PathEscape(sshKeyID))
+		return bareMetalServer{}, fmt.Errorf("failed to upload startup script: %v", err)
+	}
+
+	// Both are only read while the server is deployed
+	defer func() {
+		client.deleteResource("/ssh_keys/" + url.PathEscape(sshKeyID))
+		client.deleteResource("/user_data/" + url.PathEscape(userDataID))
+	}()
+
+	operatingSystem, _ := bareMetalOS(req)
+	return client.createServer(map[string]interface{}{
+		"project":          projectID,
+		"plan":             req.InstanceType,
+		"site":             req.Region,
+		"operating_system": operatingSystem,
+		"hostname":         name,
+		"ssh_keys":         []string{sshKeyID},
+		"user_data":        userDataID,
+	})
+}
+
+// monitorBareMetalDeployment follows a new server until it's powered on with
+// an IP, like monitorNodeDeployment does for EC2
+func monitorBareMetalDeployment(nodeID, serverID string, client *bareMetalClient) {
+	// Create initial log entry
+	updateNodeWithLog(nodeID, "deploying", "provision", "Provisioning bare-metal server...", 5)
+
+	followBareMetalDeployment(client, serverID, func(update deployStateUpdate) {
+		applyDeployState(nodeID, update)
+	})
+}
+
+// followBareMetalDeployment polls a server and reports each state until the
+// deployment is done with the machine or times out
+func followBareMetalDeployment(client *bareMetalClient, serverID string, report func(deployStateUpdate)) {
+	deadline := time.Now().Add(bareMetalDeployTimeout)
+	for time.Now().Before(deadline) {
+		// Wait a bit before checking status
+		time.Sleep(bareMetalPollInterval)
+
+		server, err := client.getServer(serverID)
+		if err != nil {
+			report(deployStateUpdate{Status: "failed", Step: "error", Message: fmt.Sprintf("Failed to get server status: %v", err), Done: true})
+			return
+		}
+
+		update := bareMetalDeploymentState(server)
+		report(update)
+		if update.Done {
+			return
+		}
+	}
+
+	report(deployStateUpdate{Status: "failed", Step: "timeout", Message: "The server was not deployed in time", Done: true})
+}
+
+// bareMetalDeploymentState maps the state of a new server to a node update
+func bareMetalDeploymentState(server bareMetalServer) deployStateUpdate {
+	switch server.Status {
+	case "on":
+		// The startup script reports its own progress, record the IP address
+		if server.PrimaryIPv4 != "" {
+			return deployStateUpdate{Status: "deploying", Step: "vm_ready", Message: "Server is running, setting up node software...", Progress: 15, IP: server.PrimaryIPv4, Done: true}
+		}
+		return deployStateUpdate{}
+	case "failed_deployment", "failed":
+		return deployStateUpdate{Status: "failed", Step: "provision_failed", Message: "The host failed to deploy the server", Done: true}
+	default:
+		return deployStateUpdate{Status: "deploying", Step: "provisioning", Message: fmt.Sprintf("Server state: %s", server.Status), Progress: 10}
+	}
+}
+
+// monitorBareMetalStateChange follows a server after a power action, like
+// monitorInstanceStateChange does for EC2
+func monitorBareMetalStateChange(nodeID, serverID string, client *bareMetalClient, actionType string) {
+	for {
+		// Wait a bit before checking status
+		time.Sleep(bareMetalPollInterval)
+
+		server, err := client.getServer(serverID)
+		if err != nil {
+			updateNodeWithLog(nodeID, "failed", "error", fmt.Sprintf("Failed to get server status: %v", err), 0)
+			return
+		}
+
+		switch server.Status {
+		case "on":
+			if actionType == "start" || actionType == "reboot" {
+				updateNodeWithLog(nodeID, "running", "running", "Server is now running", 100)
+				return
+			}
+		case "off":
+			if actionType == "stop" {
+				updateNodeWithLog(nodeID, "stopped", "stopped", "Server is now powered off", 0)
+				return
+			}
+		}
+	}
+}
+
+// bareMetalNodeAction runs a power action on a node's server and follows it
+func bareMetalNodeAction(node models.Node, action, actionType, status, detail string) error {
+	if node.InstanceID == "" {
+		return fmt.Errorf("no bare-metal server associated with this node")
+	}
+
+	client, _, err := getBareMetalClient(node.UserID)
+	if err != nil {
+		return err
+	}
+
+	if err := client.serverAction(node.InstanceID, action); err != nil {
+		return fmt.Errorf("failed to %s server: %v", actionType, err)
+	}
+
+	// Update node status
+	updateNodeStatus(node.ID, status, detail)
+
+	// Start monitoring the server state
+	go monitorBareMetalStateChange(node.ID, node.InstanceID, client, actionType)
+
+	return nil
+}
+
+// startBareMetalNode powers a server on
+func startBareMetalNode(node models.Node) error {
+	return bareMetalNodeAction(node, "power_on", "start", "starting", "Powering on the bare-metal server...")
+}
+
+// stopBareMetalNode powers a server off
+func stopBareMetalNode(node models.Node) error {
+	return bareMetalNodeAction(node, "power_off", "stop", "stopping", "Powering off the bare-metal server...")
+}
+
+// rebootBareMetalNode power cycles a server
+func rebootBareMetalNode(node models.Node) error {
+	return bareMetalNodeAction(node, "reboot", "reboot", "rebooting", "Rebooting the bare-metal server...")
+}
+
+// deleteBareMetalNode releases a node's server back to the host
+func deleteBareMetalNode(node models.Node) error {
+	if node.InstanceID == "" {
+		return nil
+	}
+
+	client, _, err := getBareMetalClient(node.UserID)
+	if err != nil {
+		return err
+	}
+
+	// Already released, e.g. from the host's dashboard
+	if err := client.releaseServer(node.InstanceID); err != nil && !isBareMetalNotFound(err) {
+		return fmt.Errorf("failed to release server: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0saurabh0/NodeEase/models"
+)
+
+// bareMetalAPI stands in for the host's JSON:API. It keeps created resources
+// by path, e.g. /servers/servers-3, and answers server polls from states.
+type bareMetalAPI struct {
+	mu        sync.Mutex
+	resources map[string]map[string]interface{}
+	uploads   map[string]map[string]interface{} // Attributes of every upload, by type
+	states    []bareMetalServer                 // One per poll, repeating the last
+	actions   []string
+	created   int
+}
+
+func newBareMetalAPI(t *testing.T) (*bareMetalAPI, *bareMetalClient) {
+	t.Helper()
+	api := &bareMetalAPI{
+		resources: map[string]map[string]interface{}{},
+		uploads:   map[string]map[string]interface{}{},
+	}
+
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+
+	t.Setenv("BAREMETAL_API_URL", srv.URL)
+	return api, newBareMetalClient("test-token")
+}
+
+func (a *bareMetalAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	respond := func(code int, v interface{}) {
+		w.WriteHeader(code)
+		if v != nil {
+			json.NewEncoder(w).Encode(v)
+		}
+	}
+	fail := func(code int) {
+		respond(code, map[string]interface{}{"errors": []map[string]string{{"title": http.StatusText(code)}}})
+	}
+
+	if r.Header.Get("Authorization") != "Bearer test-token" {
+		fail(http.StatusUnauthorized)
+		return
+	}
+
+	var document struct {
+		Data struct {
+			Type       string                 `json:"type"`
+			Attributes map[string]interface{} `json:"attributes"`
+		} `json:"data"`
+	}
+	json.NewDecoder(r.Body).Decode(&document)
+
+	path := r.URL.Path
+	_, exists := a.resources[strings.TrimSuffix(path, "/actions")]
+	switch {
+	case r.Method == http.MethodPost && strings.Count(path, "/") == 1:
+		a.created++
+		id := fmt.Sprintf("%s-%d", document.Data.Type, a.created)
+		a.resources[path+"/"+id] = document.Data.Attributes
+		a.uploads[document.Data.Type] = document.Data.Attributes
+		respond(http.StatusCreated, map[string]interface{}{"data": map[string]interface{}{"id": id, "attributes": bareMetalServer{Status: "deploying"}}})
+	case !exists:
+		fail(http.StatusNotFound)
+	case r.Method == http.MethodGet:
+		state := a.states[0]
+		if len(a.states) > 1 {
+			a.states = a.states[1:]
+		}
+		respond(http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"attributes": state}})
+	case r.Method == http.MethodPost:
+		a.actions = append(a.actions, fmt.Sprint(document.Data.Attributes["action"]))
+		respond(http.StatusNoContent, nil)
+	case r.Method == http.MethodDelete:
+		delete(a.resources, path)
+		respond(http.StatusNoContent, nil)
+	}
+}
+
+func TestBareMetalServerLifecycle(t *testing.T) {
+	api, client := newBareMetalAPI(t)
+	req := models.NodeDeployRequest{Region: "SAO", InstanceType: "c3-large-x86", DiskSize: 2000}
+
+	server, err := orderBareMetalServer(client, "proj-1", "0123456789abcdef", req, "#!/bin/bash\n", "ssh-ed25519 AAAA key\n")
+	if err != nil {
+		t.Fatalf("orderBareMetalServer: %v", err)
+	}
+
+	api.mu.Lock()
+	order := api.resources["/servers/"+server.ID]
+	for key, want := range map[string]string{
+		"project":          "proj-1",
+		"plan":             "c3-large-x86",
+		"site":             "SAO",
+		"operating_system": "ubuntu_22_04_x64_lts",
+		"hostname":         "nodeease-01234567",
+	} {
+		if order[key] != want {
+			t.Errorf("%s = %v, want %q", key, order[key], want)
+		}
+	}
+	if key := api.uploads["ssh_keys"]["public_key"]; key != "ssh-ed25519 AAAA key" {
+		t.Errorf("public_key = %q", key)
+	}
+	script, _ := base64.StdEncoding.DecodeString(fmt.Sprint(api.uploads["user_data"]["content"]))
+	if string(script) != "#!/bin/bash\n" {
+		t.Errorf("user data = %q, want the base64 startup script", script)
+	}
+	// The key and script are only read while the server is deployed
+	if len(api.resources) != 1 {
+		t.Errorf("resources left = %v, want only the server", api.resources)
+	}
+	api.mu.Unlock()
+
+	for _, action := range []string{"power_off", "power_on", "reboot"} {
+		if err := client.serverAction(server.ID, action); err != nil {
+			t.Errorf("%s: %v", action, err)
+		}
+	}
+	if got := strings.Join(api.actions, ","); got != "power_off,power_on,reboot" {
+		t.Errorf("actions = %s", got)
+	}
+
+	if err := client.releaseServer(server.ID); err != nil {
+		t.Fatalf("releaseServer: %v", err)
+	}
+	// deleteBareMetalNode treats this as released
+	if err := client.releaseServer(server.ID); !isBareMetalNotFound(err) {
+		t.Errorf("releasing again = %v, want not found", err)
+	}
+
+	client.token = "revoked"
+	if _, err := orderBareMetalServer(client, "proj-1", "0123456789abcdef", req, "", "key"); err == nil || !strings.Contains(err.Error(), "failed to upload SSH key") {
+		t.Errorf("revoked token = %v, want an SSH key upload error", err)
+	}
+}
+
+func TestFollowBareMetalDeployment(t *testing.T) {
+	interval, timeout := bareMetalPollInterval, bareMetalDeployTimeout
+	bareMetalPollInterval = time.Millisecond
+	t.Cleanup(func() { bareMetalPollInterval, bareMetalDeployTimeout = interval, timeout })
+
+	tests := []struct {
+		name    string
+		states  []bareMetalServer // Nil for a released server
+		timeout time.Duration
+		want    []deployStateUpdate
+	}{
+		{
+			name:   "powered on",
+			states: []bareMetalServer{{Status: "deploying"}, {Status: "on"}, {Status: "on", PrimaryIPv4: "203.0.113.20"}},
+			want: []deployStateUpdate{
+				{Status: "deploying", Step: "provisioning", Message: "Server state: deploying", Progress: 10},
+				{},
+				{Status: "deploying", Step: "vm_ready", Message: "Server is running, setting up node software...", Progress: 15, IP: "203.0.113.20", Done: true},
+			},
+		},
+		{
+			name:   "failed deployment",
+			states: []bareMetalServer{{Status: "failed_deployment"}},
+			want:   []deployStateUpdate{{Status: "failed", Step: "provision_failed", Message: "The host failed to deploy the server", Done: true}},
+		},
+		{
+			name: "released",
+			want: []deployStateUpdate{{Status: "failed", Step: "error", Message: "Failed to get server status: bare-metal API returned 404: Not Found", Done: true}},
+		},
+		{
+			name:    "timeout",
+			states:  []bareMetalServer{{Status: "deploying"}},
+			timeout: -time.Second,
+			want:    []deployStateUpdate{{Status: "failed", Step: "timeout", Message: "The server was not deployed in time", Done: true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, client := newBareMetalAPI(t)
+			if tt.states != nil {
+				api.resources["/servers/srv-1"] = nil
+				api.states = tt.states
+			}
+			bareMetalDeployTimeout = timeout
+			if tt.timeout != 0 {
+				bareMetalDeployTimeout = tt.timeout
+			}
+
+			var got []deployStateUpdate
+			followBareMetalDeployment(client, "srv-1", func(update deployStateUpdate) {
+				got = append(got, update)
+			})
+
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("updates =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
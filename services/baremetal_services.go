@@ -0,0 +1,314 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/0saurabh0/NodeEase/db/repository"
+	"github.com/0saurabh0/NodeEase/models"
+	"github.com/0saurabh0/NodeEase/utils"
+	"github.com/google/uuid"
+)
+
+// defaultBareMetalAPIURL is the bare-metal host's API, overridable with
+// BAREMETAL_API_URL (e.g. to point at a fake of the vendor API)
+const defaultBareMetalAPIURL = "https://api.latitude.sh"
+
+// bareMetalClient talks to a JSON:API style bare-metal hosting API. Servers,
+// SSH keys and user data are resources scoped to a project.
+type bareMetalClient struct {
+	http    *http.Client
+	baseURL string
+	token   string
+}
+
+// bareMetalError is an error response from the bare-metal API
+type bareMetalError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *bareMetalError) Error() string {
+	return fmt.Sprintf("bare-metal API returned %d: %s", e.StatusCode, e.Message)
+}
+
+// bareMetalServer is the part of a server resource NodeEase uses
+type bareMetalServer struct {
+	ID          string `json:"-"`
+	Status      string `json:"status"` // deploying, on, off, failed_deployment, ...
+	PrimaryIPv4 string `json:"primary_ipv4"`
+}
+
+// bareMetalPlan is the part of a plan resource NodeEase uses
+type bareMetalPlan struct {
+	Regions []struct {
+		Locations struct {
+			Available []string `json:"available"`
+			InStock   []string `json:"in_stock"`
+		} `json:"locations"`
+	} `json:"regions"`
+}
+
+// newBareMetalClient creates a client authenticated with an API token
+func newBareMetalClient(token string) *bareMetalClient {
+	baseURL := os.Getenv("BAREMETAL_API_URL")
+	if baseURL == "" {
+		baseURL = defaultBareMetalAPIURL
+	}
+
+	return &bareMetalClient{
+		http:    &http.Client{Timeout: 30 * time.Second},
+		baseURL: baseURL,
+		token:   token,
+	}
+}
+
+// do sends a request, wrapping attributes in a JSON:API document of the given
+// type and unwrapping the response's attributes into out, if set. It returns
+// the ID of the resource in the response.
+func (c *bareMetalClient) do(method, path, resourceType string, attributes, out interface{}) (string, error) {
+	var body io.Reader
+	if attributes != nil {
+		encoded, err := json.Marshal(map[string]interface{}{
+			"data": map[string]interface{}{
+				"type":       resourceType,
+				"attributes": attributes,
+			},
+		})
+		if err != nil {
+			return "", err
+		}
+		body = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		var apiErr struct {
+			Errors []struct {
+				Title  string `json:"title"`
+				Detail string `json:"detail"`
+			} `json:"errors"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+
+		message := http.StatusText(resp.StatusCode)
+		if len(apiErr.Errors) > 0 {
+			message = apiErr.Errors[0].Title
+			if apiErr.Errors[0].Detail != "" {
+				message = apiErr.Errors[0].Detail
+			}
+		}
+		return "", &bareMetalError{StatusCode: resp.StatusCode, Message: message}
+	}
+
+	if resp.StatusCode == http.StatusNoContent {
+		return "", nil
+	}
+
+	var document struct {
+		Data struct {
+			ID         string          `json:"id"`
+			Attributes json.RawMessage `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&document); err != nil {
+		if err == io.EOF {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to decode bare-metal API response: %v", err)
+	}
+
+	if out != nil && len(document.Data.Attributes) > 0 {
+		if err := json.Unmarshal(document.Data.Attributes, out); err != nil {
+			return "", fmt.Errorf("failed to decode bare-metal API response: %v", err)
+		}
+	}
+
+	return document.Data.ID, nil
+}
+
+// isBareMetalNotFound reports whether an error means the resource doesn't exist
+func isBareMetalNotFound(err error) bool {
+	var apiErr *bareMetalError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// getProject checks the token can see a project
+func (c *bareMetalClient) getProject(projectID string) error {
+	_, err := c.do(http.MethodGet, "/projects/"+url.PathEscape(projectID), "", nil, nil)
+	return err
+}
+
+// getPlan looks up a server plan
+func (c *bareMetalClient) getPlan(plan string) (bareMetalPlan, error) {
+	var info bareMetalPlan
+	_, err := c.do(http.MethodGet, "/plans/"+url.PathEscape(plan), "", nil, &info)
+	return info, err
+}
+
+// createSSHKey uploads a public key to a project and returns its ID
+func (c *bareMetalClient) createSSHKey(projectID, name, publicKey string) (string, error) {
+	return c.do(http.MethodPost, "/ssh_keys", "ssh_keys", map[string]string{
+		"project":    projectID,
+		"name":       name,
+		"public_key": publicKey,
+	}, nil)
+}
+
+// createUserData uploads a startup script to a project and returns its ID
+func (c *bareMetalClient) createUserData(projectID, description, content string) (string, error) {
+	return c.do(http.MethodPost, "/user_data", "user_data", map[string]string{
+		"project":     projectID,
+		"description": description,
+		"content":     base64.StdEncoding.EncodeToString([]byte(content)),
+	}, nil)
+}
+
+// deleteResource deletes a resource, treating one that's already gone as deleted
+func (c *bareMetalClient) deleteResource(path string) error {
+	_, err := c.do(http.MethodDelete, path, "", nil, nil)
+	if err != nil && !isBareMetalNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// createServer orders a server and returns it
+func (c *bareMetalClient) createServer(attributes map[string]interface{}) (bareMetalServer, error) {
+	var server bareMetalServer
+	id, err := c.do(http.MethodPost, "/servers", "servers", attributes, &server)
+	server.ID = id
+	return server, err
+}
+
+// getServer returns the current state of a server
+func (c *bareMetalClient) getServer(id string) (bareMetalServer, error) {
+	var server bareMetalServer
+	_, err := c.do(http.MethodGet, "/servers/"+url.PathEscape(id), "", nil, &server)
+	server.ID = id
+	return server, err
+}
+
+// serverAction runs a power action (power_on, power_off, reboot) on a server
+func (c *bareMetalClient) serverAction(id, action string) error {
+	_, err := c.do(http.MethodPost, "/servers/"+url.PathEscape(id)+"/actions", "action", map[string]string{
+		"action": action,
+	}, nil)
+	return err
+}
+
+// releaseServer releases a server back to the host
+func (c *bareMetalClient) releaseServer(id string) error {
+	_, err := c.do(http.MethodDelete, "/servers/"+url.PathEscape(id), "", nil, nil)
+	return err
+}
+
+// TestBareMetalConnection checks an API token can reach the project
+func TestBareMetalConnection(creds models.BareMetalCredentials) error {
+	if creds.APIToken == "" || creds.ProjectID == "" {
+		return fmt.Errorf("API token and project ID are required")
+	}
+
+	if err := newBareMetalClient(creds.APIToken).getProject(creds.ProjectID); err != nil {
+		return fmt.Errorf("failed to connect to the bare-metal API: %v", err)
+	}
+	return nil
+}
+
+// IntegrateBareMetal verifies an API token and saves it, encrypted, as the
+// user's bare-metal integration
+func IntegrateBareMetal(userID string, creds models.BareMetalCredentials) error {
+	if err := TestBareMetalConnection(creds); err != nil {
+		return err
+	}
+
+	// Encrypt sensitive data
+	encryptedToken, err := utils.Encrypt(creds.APIToken)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt credentials")
+	}
+
+	integration := models.Integration{
+		UserID:   userID,
+		Provider: "BareMetal",
+		Status:   "active",
+		Data: models.BareMetalIntegrationData{
+			APIToken:  encryptedToken,
+			ProjectID: creds.ProjectID,
+		},
+	}
+
+	// Check if integration exists
+	now := time.Now()
+	existing, err := GetBareMetalIntegrationByUserID(userID)
+	if err == nil && existing.ID != "" {
+		// Update existing integration
+		integration.ID = existing.ID
+		integration.CreatedAt = existing.CreatedAt
+		integration.UpdatedAt = now
+
+		return repository.UpdateIntegration(integration)
+	}
+
+	// Create new integration
+	integration.ID = uuid.New().String()
+	integration.CreatedAt = now
+	integration.UpdatedAt = now
+
+	return repository.SaveIntegration(integration)
+}
+
+// GetBareMetalIntegrationByUserID retrieves bare-metal integration for a user
+func GetBareMetalIntegrationByUserID(userID string) (models.Integration, error) {
+	return repository.GetIntegrationByUserAndProvider(userID, "BareMetal")
+}
+
+// getBareMetalClient returns an API client for the user's integration along
+// with the project servers are ordered in
+func getBareMetalClient(userID string) (*bareMetalClient, string, error) {
+	integration, err := GetBareMetalIntegrationByUserID(userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	bareMetalData, ok := integration.Data.(models.BareMetalIntegrationData)
+	if !ok {
+		return nil, "", errors.New("no bare-metal integration found")
+	}
+
+	// Decrypt credentials
+	token, err := decrypt(bareMetalData.APIToken)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return newBareMetalClient(token), bareMetalData.ProjectID, nil
+}
+
+// DisconnectBareMetal removes the user's bare-metal integration
+func DisconnectBareMetal(userID string) error {
+	return repository.DeleteIntegrationByUserAndProvider(userID, "BareMetal")
+}
@@ -0,0 +1,382 @@
+package services
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/0saurabh0/NodeEase/models"
+)
+
+const (
+	// defaultAPIBaseURL is where nodes send status callbacks unless
+	// NODE_CALLBACK_API_URL is set
+	defaultAPIBaseURL = "https://nodeease.up.railway.app/api"
+
+	// solanaInstallDir is where the Anza installer puts the active release
+	solanaInstallDir = "/home/solana/.local/share/solana/install/active_release"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+// bootstrapTemplates holds the parsed bootstrap script and unit templates
+var bootstrapTemplates = template.Must(template.New("").Funcs(template.FuncMap{
+	"shq":      shellQuote,
+	"systemdq": systemdQuote,
+}).ParseFS(templateFS, "templates/*.tmpl"))
+
+// bootstrapTemplateData is what the templates are executed with
+type bootstrapTemplateData struct {
+	Config models.NodeConfig
+	Flags  []models.ValidatorFlag
+	Unit   string
+
+	Client        validatorClient
+	ClientVersion string
+	ClientConfig  string
+	Install       string
+	InstallDir    string
+	ValidatorBin  string
+	InstallURL    string
+
+	LongTermCredentials string // Where the long-term storage credentials are written, if used
+	LongTermSecret      string // Node secret the credentials are fetched from
+
+	InstanceStore        bool
+	InstanceStoreTargets string
+	LedgerDevice         string
+	AccountsDevice       string
+
+	HeartbeatInterval int // Seconds between heartbeats
+
+	AgentSHA256 map[string]string // Checksum of each agent build, by architecture
+}
+
+// clusterForNetworkType maps the requested network type to a cluster ID.
+// Anything other than the mainnet aliases names a cluster in the catalog.
+func clusterForNetworkType(networkType string) string {
+	switch networkType {
+	case "", "mainnet", "mainnet-beta":
+		return "mainnet-beta"
+	}
+	return networkType
+}
+
+// callbackAPIBaseURL returns the API base URL nodes report back to
+func callbackAPIBaseURL() string {
+	if url := os.Getenv("NODE_CALLBACK_API_URL"); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	return defaultAPIBaseURL
+}
+
+// buildNodeConfig turns a deploy request into the typed config the templates
+// render. The cluster comes from the catalog, see resolveCluster.
+func buildNodeConfig(req models.NodeDeployRequest, cluster models.Cluster, nodeID, deployToken string) models.NodeConfig {
+	// Fill in the default client and version; the request is validated separately
+	client := req.Client
+	if client == "" {
+		client = DefaultClient
+	}
+	clientVersion := req.ClientVersion
+	if clientVersion == "" {
+		clientVersion = validatorClients[client].DefaultVersion
+	}
+
+	// Retention follows the history length and disk; the request is validated separately
+	historyLength := resolveHistoryLength(req.RpcType, req.HistoryLength)
+	settings := defaultValidatorSettings(req.RpcType, historyLength)
+	if plan, err := planRetention(req); err == nil {
+		settings = applyRetentionPlan(settings, plan)
+	}
+	settings = applyLongTermStorage(settings, req.LongTermStorage)
+	settings.ExtraArgs = req.ExtraArgs
+
+	return models.NodeConfig{
+		NodeID:                nodeID,
+		NodeName:              req.NodeName,
+		DeployToken:           deployToken,
+		APIBaseURL:            callbackAPIBaseURL(),
+		RpcType:               req.RpcType,
+		NetworkType:           req.NetworkType,
+		Cluster:               clusterForNetworkType(req.NetworkType),
+		HistoryLength:         historyLength,
+		Client:                client,
+		ClientVersion:         clientVersion,
+		Entrypoints:           cluster.Entrypoints,
+		KnownValidators:       cluster.KnownValidators,
+		ExpectedGenesisHash:   cluster.ExpectedGenesisHash,
+		ExpectedShredVersion:  cluster.ExpectedShredVersion,
+		Settings:              settings,
+		LedgerVolume:          req.LedgerVolume,
+		AccountsVolume:        req.AccountsVolume,
+		InstanceStoreAccounts: req.InstanceStoreAccounts,
+		InstanceStoreLedger:   req.InstanceStoreLedger,
+	}
+}
+
+// flag is shorthand for building a ValidatorFlag
+func flag(name string, values ...string) models.ValidatorFlag {
+	return models.ValidatorFlag{Name: name, Values: values}
+}
+
+// defaultValidatorSettings returns the validator settings a node type starts with
+func defaultValidatorSettings(rpcType, historyLength string) models.ValidatorSettings {
+	switch rpcType {
+	case "base":
+		return models.ValidatorSettings{
+			AccountIndexes: []string{"program-id", "spl-token-owner", "spl-token-mint"},
+			AccountIndexExcludeKeys: []string{
+				"kinXdEcpDQeHPEuQnqmUgtYykqKGVFq6CeVX5iAHJq6",
+				"TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA",
+			},
+			PrivateRPC:         true,
+			FullRPCAPI:         true,
+			RPCBindAddress:     "0.0.0.0",
+			TransactionHistory: true,
+			LimitLedger:        true,
+			WALRecoveryMode:    "skip_any_corrupted_record",
+		}
+	case "extended":
+		return models.ValidatorSettings{
+			OnlyKnownRPC:              true,
+			FullRPCAPI:                true,
+			TransactionHistory:        true,
+			ExtendedTxMetadataStorage: true,
+			CPIAndLogStorage:          true,
+			NoSnapshotFetch:           historyLength == "full",
+		}
+	}
+	return models.ValidatorSettings{}
+}
+
+// buildValidatorFlags returns the validator flags for a node config. Clients
+// configured by file get these translated into their own format.
+func buildValidatorFlags(cfg models.NodeConfig) []models.ValidatorFlag {
+	settings := cfg.Settings
+
+	flags := []models.ValidatorFlag{flag("--ledger", "/data/solana/ledger")}
+	// Base nodes keep accounts in their own directory so it can live on a separate volume
+	if cfg.RpcType == "base" {
+		flags = append(flags, flag("--accounts", "/data/solana/accounts"))
+	}
+	flags = append(flags, flag("--identity", "/data/solana/validator-keypair.json"))
+
+	for _, entrypoint := range cfg.Entrypoints {
+		flags = append(flags, flag("--entrypoint", entrypoint))
+	}
+	for _, validator := range cfg.KnownValidators {
+		flags = append(flags, flag("--known-validator", validator))
+	}
+	if cfg.ExpectedGenesisHash != "" {
+		flags = append(flags, flag("--expected-genesis-hash", cfg.ExpectedGenesisHash))
+	}
+	if cfg.ExpectedShredVersion != 0 {
+		flags = append(flags, flag("--expected-shred-version", strconv.Itoa(cfg.ExpectedShredVersion)))
+	}
+
+	if len(settings.AccountIndexes) > 0 {
+		flags = append(flags, flag("--account-index", settings.AccountIndexes...))
+		for _, key := range settings.AccountIndexExcludeKeys {
+			flags = append(flags, flag("--account-index-exclude-key", key))
+		}
+	}
+
+	flags = append(flags, flag("--rpc-port", "8899"))
+	if settings.PrivateRPC {
+		flags = append(flags, flag("--private-rpc"))
+	}
+	// Private clusters may not list known validators to trust
+	if settings.OnlyKnownRPC && len(cfg.KnownValidators) > 0 {
+		flags = append(flags, flag("--no-untrusted-rpc"))
+	}
+	if settings.FullRPCAPI {
+		flags = append(flags, flag("--full-rpc-api"))
+	}
+	flags = append(flags, flag("--dynamic-port-range", "8000-8020"))
+	if settings.WALRecoveryMode != "" {
+		flags = append(flags, flag("--wal-recovery-mode", settings.WALRecoveryMode))
+	}
+	flags = append(flags, flag("--no-voting"))
+
+	if settings.TransactionHistory {
+		flags = append(flags, flag("--enable-rpc-transaction-history"))
+	}
+	if settings.ExtendedTxMetadataStorage {
+		flags = append(flags, flag("--enable-extended-tx-metadata-storage"))
+	}
+	if settings.CPIAndLogStorage {
+		flags = append(flags, flag("--enable-cpi-and-log-storage"))
+	}
+	if settings.LimitLedger {
+		if settings.LimitLedgerShreds > 0 {
+			flags = append(flags, flag("--limit-ledger-size", strconv.FormatInt(settings.LimitLedgerShreds, 10)))
+		} else {
+			flags = append(flags, flag("--limit-ledger-size"))
+		}
+	}
+	if settings.RPCBindAddress != "" {
+		flags = append(flags, flag("--rpc-bind-address", settings.RPCBindAddress))
+	}
+	if settings.NoSnapshotFetch {
+		flags = append(flags, flag("--no-snapshot-fetch"))
+	}
+	if settings.FullSnapshotIntervalSlots > 0 {
+		flags = append(flags, flag("--full-snapshot-interval-slots", strconv.FormatInt(settings.FullSnapshotIntervalSlots, 10)))
+	}
+	if settings.IncrementalSnapshotIntervalSlots > 0 {
+		flags = append(flags, flag("--incremental-snapshot-interval-slots", strconv.FormatInt(settings.IncrementalSnapshotIntervalSlots, 10)))
+	}
+	if settings.MaxFullSnapshots > 0 {
+		flags = append(flags, flag("--maximum-full-snapshots-to-retain", strconv.Itoa(settings.MaxFullSnapshots)))
+	}
+	if settings.MaxIncrementalSnapshots > 0 {
+		flags = append(flags, flag("--maximum-incremental-snapshots-to-retain", strconv.Itoa(settings.MaxIncrementalSnapshots)))
+	}
+	if settings.BigTableLedgerStorage {
+		flags = append(flags, flag("--enable-rpc-bigtable-ledger-storage"))
+		flags = append(flags, flag("--rpc-bigtable-instance-name", settings.BigTableInstanceName))
+		if settings.BigTableTimeout > 0 {
+			flags = append(flags, flag("--rpc-bigtable-timeout", strconv.Itoa(settings.BigTableTimeout)))
+		}
+	}
+
+	// User supplied flags come last, they're validated against the client's allow-list
+	flags = append(flags, settings.ExtraArgs...)
+
+	return flags
+}
+
+// newBootstrapTemplateData collects everything the templates need for a config
+func newBootstrapTemplateData(cfg models.NodeConfig) (bootstrapTemplateData, error) {
+	client, version, err := resolveClient(cfg.Client, cfg.ClientVersion)
+	if err != nil {
+		return bootstrapTemplateData{}, err
+	}
+
+	data := bootstrapTemplateData{
+		Config:        cfg,
+		Flags:         buildValidatorFlags(cfg),
+		Client:        client,
+		ClientVersion: version,
+		InstallDir:    client.InstallDir,
+		ValidatorBin:  client.InstallDir + "/bin/" + client.BinaryName,
+
+		InstanceStore:  cfg.InstanceStoreAccounts || cfg.InstanceStoreLedger,
+		LedgerDevice:   strings.TrimPrefix(ledgerDeviceName, "/dev/"),
+		AccountsDevice: strings.TrimPrefix(accountsDeviceName, "/dev/"),
+
+		HeartbeatInterval: int(heartbeatInterval.Seconds()),
+
+		AgentSHA256: agentBinaryChecksums(),
+	}
+
+	if client.InstallURL != nil {
+		data.InstallURL = client.InstallURL(version)
+	}
+	if cfg.Settings.BigTableLedgerStorage {
+		data.LongTermCredentials = longTermCredentialsPath
+		data.LongTermSecret = longTermStorageSecret
+	}
+
+	if err := validateClientFlags(client, data.Flags); err != nil {
+		return bootstrapTemplateData{}, err
+	}
+	if client.ConfigFile != "" {
+		data.ClientConfig = renderFiredancerConfig(data.Flags)
+	}
+
+	data.InstanceStoreTargets = "accounts"
+	if cfg.InstanceStoreLedger {
+		data.InstanceStoreTargets = "accounts ledger"
+	}
+
+	return data, nil
+}
+
+// RenderNodeScripts renders the user-data script and validator unit for a config
+func RenderNodeScripts(cfg models.NodeConfig) (models.RenderedNodeScripts, error) {
+	data, err := newBootstrapTemplateData(cfg)
+	if err != nil {
+		return models.RenderedNodeScripts{}, err
+	}
+
+	var install bytes.Buffer
+	if err := bootstrapTemplates.ExecuteTemplate(&install, data.Client.InstallTemplate, data); err != nil {
+		return models.RenderedNodeScripts{}, fmt.Errorf("failed to render client install steps: %v", err)
+	}
+	data.Install = install.String()
+
+	var unit bytes.Buffer
+	if err := bootstrapTemplates.ExecuteTemplate(&unit, "solana-validator.service.tmpl", data); err != nil {
+		return models.RenderedNodeScripts{}, fmt.Errorf("failed to render validator unit: %v", err)
+	}
+	data.Unit = unit.String()
+
+	var script bytes.Buffer
+	if err := bootstrapTemplates.ExecuteTemplate(&script, "bootstrap.sh.tmpl", data); err != nil {
+		return models.RenderedNodeScripts{}, fmt.Errorf("failed to render bootstrap script: %v", err)
+	}
+
+	return models.RenderedNodeScripts{
+		Config:       cfg,
+		Flags:        data.Flags,
+		UserData:     script.String(),
+		SystemdUnit:  data.Unit,
+		ClientConfig: data.ClientConfig,
+	}, nil
+}
+
+// PreviewNodeScripts renders what a deploy request would run without deploying.
+// Placeholder values stand in for the node ID and deployment token.
+func PreviewNodeScripts(userID string, req models.NodeDeployRequest) (models.RenderedNodeScripts, error) {
+	if err := ValidateDeployRequest(userID, req); err != nil {
+		return models.RenderedNodeScripts{}, err
+	}
+
+	// Only bootstrapped nodes run the startup script
+	providerName, _ := resolveProvider(req.Provider)
+	if provider, ok := nodeProviders[providerName]; ok && !provider.Bootstrapped {
+		return models.RenderedNodeScripts{}, fmt.Errorf("%s nodes don't use startup scripts", provider.DisplayName)
+	}
+
+	cluster, err := resolveCluster(userID, req.NetworkType)
+	if err != nil {
+		return models.RenderedNodeScripts{}, err
+	}
+
+	cfg := buildNodeConfig(req, cluster, "00000000-0000-0000-0000-000000000000", "preview-token")
+	return RenderNodeScripts(cfg)
+}
+
+// shellQuote wraps a value in single quotes for safe use in bash
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'"'"'`) + "'"
+}
+
+// systemdSafeArg matches arguments that need no quoting in a unit file
+var systemdSafeArg = regexp.MustCompile(`^[A-Za-z0-9_./:=,@+-]+$`)
+
+// systemdQuote quotes a value for use as a single argument in ExecStart,
+// escaping systemd's specifier (%) and variable ($) expansion
+func systemdQuote(value string) string {
+	if systemdSafeArg.MatchString(value) {
+		return value
+	}
+
+	escaped := strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		"%", "%%",
+		"$", "$$",
+		"\n", `\n`,
+	).Replace(value)
+
+	return `"` + escaped + `"`
+}
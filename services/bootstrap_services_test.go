@@ -0,0 +1,126 @@
+package services
+
+import (
+	cliflag "flag"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/0saurabh0/NodeEase/models"
+)
+
+// Regenerate the golden files with: go test ./services -run TestRenderNodeScripts -update
+var update = cliflag.Bool("update", false, "update the golden files in testdata")
+
+// goldenCluster returns a default cluster by ID
+func goldenCluster(t *testing.T, id string) models.Cluster {
+	t.Helper()
+	for _, cluster := range defaultClusters {
+		if cluster.ID == id {
+			return cluster
+		}
+	}
+	t.Fatalf("no default cluster %q", id)
+	return models.Cluster{}
+}
+
+// renderGolden renders everything a node would be deployed with as one file
+func renderGolden(rendered models.RenderedNodeScripts) string {
+	var b strings.Builder
+	b.WriteString("### user-data\n")
+	b.WriteString(rendered.UserData)
+	b.WriteString("\n### solana-validator.service\n")
+	b.WriteString(rendered.SystemdUnit)
+	if rendered.ClientConfig != "" {
+		b.WriteString("\n### client config\n")
+		b.WriteString(rendered.ClientConfig)
+	}
+	return b.String()
+}
+
+func TestRenderNodeScripts(t *testing.T) {
+	t.Setenv("NODE_CALLBACK_API_URL", "https://nodeease.example/api")
+
+	// Fixed agent builds, so their checksums in the scripts don't change
+	agentDir := t.TempDir()
+	for _, arch := range []string{"amd64", "arm64"} {
+		if err := os.WriteFile(filepath.Join(agentDir, "nodeease-agent-linux-"+arch), []byte("agent "+arch), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("AGENT_BINARY_DIR", agentDir)
+
+	networks := []struct {
+		networkType string
+		cluster     string
+	}{
+		{"mainnet", "mainnet-beta"},
+		{"testnet", "testnet"},
+		{"devnet", "devnet"},
+	}
+
+	for _, network := range networks {
+		for _, rpcType := range []string{"base", "extended"} {
+			name := network.networkType + "_" + rpcType
+			t.Run(name, func(t *testing.T) {
+				var rec models.InstanceRecommendation
+				for _, r := range instanceRecommendations {
+					if r.NetworkType == network.networkType && r.RpcType == rpcType {
+						rec = r
+					}
+				}
+
+				req := models.NodeDeployRequest{
+					NodeName:     "golden-node",
+					RpcType:      rpcType,
+					NetworkType:  network.networkType,
+					InstanceType: rec.RecommendedInstance,
+					DiskSize:     rec.RecommendedDiskSize,
+				}
+				cfg := buildNodeConfig(req, goldenCluster(t, network.cluster), "00000000-0000-0000-0000-000000000001", "golden-token")
+
+				rendered, err := RenderNodeScripts(cfg)
+				if err != nil {
+					t.Fatalf("RenderNodeScripts: %v", err)
+				}
+				got := renderGolden(rendered)
+
+				path := filepath.Join("testdata", "bootstrap_"+name+".golden")
+				if *update {
+					if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				want, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatalf("reading golden file (run with -update to create it): %v", err)
+				}
+				if got != string(want) {
+					t.Errorf("rendered scripts differ from %s, run with -update if the change is intended:\n%s", path, firstDiff(string(want), got))
+				}
+			})
+		}
+	}
+}
+
+// firstDiff describes the first line where two renders differ
+func firstDiff(want, got string) string {
+	wantLines := strings.Split(want, "\n")
+	gotLines := strings.Split(got, "\n")
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			return "line " + strconv.Itoa(i+1) + ":\n  want: " + w + "\n  got:  " + g
+		}
+	}
+	return ""
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/0saurabh0/NodeEase/models"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// hoursPerMonth is used to turn hourly on-demand prices into monthly estimates
+const hoursPerMonth = 730
+
+// offeringsCacheTTL controls how long regional instance type offerings are reused
+const offeringsCacheTTL = 24 * time.Hour
+
+// defaultPriceRegion is the region whose prices make up the "default" entry
+// of the price table
+const defaultPriceRegion = "us-east-1"
+
+// instanceCatalog lists the instance types NodeEase will deploy nodes on
+var instanceCatalog = []models.InstanceTypeInfo{
+	{InstanceType: "r6a.16xlarge", Description: "Memory optimized (AMD EPYC 3rd gen)", VCPU: 64, MemoryGiB: 512, Network: "25 Gbps", Architecture: "x86_64"},
+	{InstanceType: "r6a.24xlarge", Description: "Memory optimized (AMD EPYC 3rd gen)", VCPU: 96, MemoryGiB: 768, Network: "37.5 Gbps", Architecture: "x86_64"},
+	{InstanceType: "r7a.16xlarge", Description: "Memory optimized (AMD EPYC 4th gen)", VCPU: 64, MemoryGiB: 512, Network: "25 Gbps", Architecture: "x86_64"},
+	{InstanceType: "r7a.24xlarge", Description: "Memory optimized (AMD EPYC 4th gen)", VCPU: 96, MemoryGiB: 768, Network: "37.5 Gbps", Architecture: "x86_64"},
+	{InstanceType: "r7a.32xlarge", Description: "Memory optimized (AMD EPYC 4th gen)", VCPU: 128, MemoryGiB: 1024, Network: "50 Gbps", Architecture: "x86_64"},
+	{InstanceType: "i4i.16xlarge", Description: "Storage optimized with local NVMe", VCPU: 64, MemoryGiB: 512, Network: "37.5 Gbps", LocalNVMeGB: 15000, Architecture: "x86_64"},
+	{InstanceType: "i4i.32xlarge", Description: "Storage optimized with local NVMe", VCPU: 128, MemoryGiB: 1024, Network: "75 Gbps", LocalNVMeGB: 30000, Architecture: "x86_64"},
+	{InstanceType: "i7ie.18xlarge", Description: "Storage optimized with local NVMe", VCPU: 72, MemoryGiB: 576, Network: "75 Gbps", LocalNVMeGB: 45000, Architecture: "x86_64"},
+	{InstanceType: "i7ie.24xlarge", Description: "Storage optimized with local NVMe", VCPU: 96, MemoryGiB: 768, Network: "100 Gbps", LocalNVMeGB: 60000, Architecture: "x86_64"},
+}
+
+// instanceRecommendations holds the minimum sizing per network and RPC type
+var instanceRecommendations = []models.InstanceRecommendation{
+	{NetworkType: "mainnet", RpcType: "base", MinVCPU: 64, MinMemoryGiB: 512, MinDiskSize: 500, RecommendedInstance: "r7a.16xlarge", RecommendedDiskSize: 500},
+	{NetworkType: "mainnet", RpcType: "extended", MinVCPU: 96, MinMemoryGiB: 768, MinDiskSize: 2000, RecommendedInstance: "r7a.24xlarge", RecommendedDiskSize: 2000},
+	{NetworkType: "testnet", RpcType: "base", MinVCPU: 64, MinMemoryGiB: 512, MinDiskSize: 500, RecommendedInstance: "r7a.16xlarge", RecommendedDiskSize: 500},
+	{NetworkType: "testnet", RpcType: "extended", MinVCPU: 64, MinMemoryGiB: 512, MinDiskSize: 1000, RecommendedInstance: "r7a.16xlarge", RecommendedDiskSize: 1000},
+	{NetworkType: "devnet", RpcType: "base", MinVCPU: 64, MinMemoryGiB: 512, MinDiskSize: 500, RecommendedInstance: "r7a.16xlarge", RecommendedDiskSize: 500},
+	{NetworkType: "devnet", RpcType: "extended", MinVCPU: 64, MinMemoryGiB: 512, MinDiskSize: 1000, RecommendedInstance: "r7a.16xlarge", RecommendedDiskSize: 1000},
+}
+
+// supportedRegions lists the regions offered for deployment
+var supportedRegions = []models.RegionInfo{
+	{Code: "us-east-1", Name: "US East (N. Virginia)"},
+	{Code: "us-east-2", Name: "US East (Ohio)"},
+	{Code: "us-west-1", Name: "US West (N. California)"},
+	{Code: "us-west-2", Name: "US West (Oregon)"},
+	{Code: "eu-west-1", Name: "EU (Ireland)"},
+	{Code: "eu-central-1", Name: "EU (Frankfurt)"},
+	{Code: "ap-northeast-1", Name: "Asia Pacific (Tokyo)"},
+	{Code: "ap-southeast-1", Name: "Asia Pacific (Singapore)"},
+	{Code: "ap-southeast-2", Name: "Asia Pacific (Sydney)"},
+}
+
+// bundledPrices is the price table shipped with the binary. Set
+// INSTANCE_PRICES_FILE to a JSON file of the same shape to override it.
+//
+//go:embed data/instance_prices.json
+var bundledPrices []byte
+
+// priceTable holds on-demand hourly prices per region, with a "default" entry
+// of us-east-1 prices used as an estimate for regions that aren't listed
+type priceTable struct {
+	Currency  string                        `json:"currency"`
+	UpdatedAt time.Time                     `json:"updatedAt"`
+	Prices    map[string]map[string]float64 `json:"prices"`
+}
+
+var (
+	prices     *priceTable
+	pricesOnce sync.Once
+	pricesErr  error
+)
+
+// loadPriceTable reads the price table once, preferring an override file
+func loadPriceTable() (*priceTable, error) {
+	pricesOnce.Do(func() {
+		data := bundledPrices
+		if path := os.Getenv("INSTANCE_PRICES_FILE"); path != "" {
+			override, err := os.ReadFile(path)
+			if err != nil {
+				pricesErr = fmt.Errorf("failed to read price table %s: %v", path, err)
+				return
+			}
+			data = override
+		}
+
+		var table priceTable
+		if err := json.Unmarshal(data, &table); err != nil {
+			pricesErr = fmt.Errorf("failed to parse price table: %v", err)
+			return
+		}
+		prices = &table
+	})
+
+	return prices, pricesErr
+}
+
+// hourlyPrice returns the on-demand price for an instance type in a region.
+// The price is estimated when the region isn't listed and the default
+// region's price is used instead.
+func (t *priceTable) hourlyPrice(region, instanceType string) (float64, bool) {
+	if regional, ok := t.Prices[region]; ok {
+		if price, ok := regional[instanceType]; ok {
+			return price, false
+		}
+	}
+
+	price := t.Prices["default"][instanceType]
+	estimated := region != "" && region != defaultPriceRegion && price != 0
+	return price, estimated
+}
+
+type regionOfferings struct {
+	InstanceTypes map[string]bool
+	FetchedAt     time.Time
+}
+
+var (
+	offeringsCache   = map[string]regionOfferings{}
+	offeringsCacheMu sync.Mutex
+)
+
+// getRegionOfferings returns the set of instance types offered in the session's region
+func getRegionOfferings(ec2Client *ec2.EC2, region string) (map[string]bool, error) {
+	offeringsCacheMu.Lock()
+	if cached, ok := offeringsCache[region]; ok && time.Since(cached.FetchedAt) < offeringsCacheTTL {
+		offeringsCacheMu.Unlock()
+		return cached.InstanceTypes, nil
+	}
+	offeringsCacheMu.Unlock()
+
+	names := make([]*string, 0, len(instanceCatalog))
+	for _, info := range instanceCatalog {
+		names = append(names, aws.String(info.InstanceType))
+	}
+
+	offered := map[string]bool{}
+	err := ec2Client.DescribeInstanceTypeOfferingsPages(&ec2.DescribeInstanceTypeOfferingsInput{
+		LocationType: aws.String("region"),
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("instance-type"),
+				Values: names,
+			},
+		},
+	}, func(page *ec2.DescribeInstanceTypeOfferingsOutput, lastPage bool) bool {
+		for _, offering := range page.InstanceTypeOfferings {
+			offered[aws.StringValue(offering.InstanceType)] = true
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	offeringsCacheMu.Lock()
+	offeringsCache[region] = regionOfferings{InstanceTypes: offered, FetchedAt: time.Now()}
+	offeringsCacheMu.Unlock()
+
+	return offered, nil
+}
+
+// GetInstanceCatalog returns the supported instance types with prices for the
+// region. When a region is given, availability is looked up with the user's
+// AWS credentials.
+func GetInstanceCatalog(userID, region string) (models.InstanceCatalog, error) {
+	table, err := loadPriceTable()
+	if err != nil {
+		return models.InstanceCatalog{}, err
+	}
+
+	catalog := models.InstanceCatalog{
+		Region:          region,
+		Currency:        table.Currency,
+		PricesUpdatedAt: table.UpdatedAt,
+		Recommendations: instanceRecommendations,
+	}
+
+	var offered map[string]bool
+	if region != "" {
+		sess, err := GetAWSSessionForRegion(userID, region)
+		if err != nil {
+			return models.InstanceCatalog{}, fmt.Errorf("failed to get AWS session: %v", err)
+		}
+
+		offered, err = getRegionOfferings(ec2.New(sess), region)
+		if err != nil {
+			return models.InstanceCatalog{}, fmt.Errorf("failed to get instance type offerings: %v", err)
+		}
+	}
+
+	for _, info := range instanceCatalog {
+		info.HourlyPrice, info.Estimated = table.hourlyPrice(region, info.InstanceType)
+		info.MonthlyPrice = math.Round(info.HourlyPrice*hoursPerMonth*100) / 100
+		if offered != nil {
+			available := offered[info.InstanceType]
+			info.Available = &available
+		}
+		catalog.InstanceTypes = append(catalog.InstanceTypes, info)
+	}
+
+	return catalog, nil
+}
+
+// GetSupportedRegions returns the regions nodes can be deployed to
+func GetSupportedRegions() []models.RegionInfo {
+	return supportedRegions
+}
+
+// isSupportedRegion reports whether nodes can be deployed to a region
+func isSupportedRegion(region string) bool {
+	for _, info := range supportedRegions {
+		if info.Code == region {
+			return true
+		}
+	}
+	return false
+}
+
+// LookupInstanceType returns catalog details for an instance type
+func LookupInstanceType(instanceType string) (models.InstanceTypeInfo, bool) {
+	for _, info := range instanceCatalog {
+		if info.InstanceType == instanceType {
+			return info, true
+		}
+	}
+	return models.InstanceTypeInfo{}, false
+}
+
+// ValidateInstanceSelection checks a deploy request against the catalog and the
+// recommended minimums for its network and RPC type
+func ValidateInstanceSelection(req models.NodeDeployRequest) error {
+	info, ok := LookupInstanceType(req.InstanceType)
+	if !ok {
+		return fmt.Errorf("unsupported instance type %q", req.InstanceType)
+	}
+
+	if req.Region != "" && !isSupportedRegion(req.Region) {
+		return fmt.Errorf("unsupported region %q", req.Region)
+	}
+
+	if req.Architecture != "" && supportedArchitectures[req.Architecture] != info.Architecture {
+		return fmt.Errorf("instance type %s is %s, not %s", req.InstanceType, info.Architecture, req.Architecture)
+	}
+
+	networkType := req.NetworkType
+	if networkType == "" || networkType == "mainnet-beta" {
+		networkType = "mainnet"
+	}
+
+	for _, rec := range instanceRecommendations {
+		if rec.NetworkType != networkType || rec.RpcType != req.RpcType {
+			continue
+		}
+		if info.VCPU < rec.MinVCPU || info.MemoryGiB < rec.MinMemoryGiB {
+			return fmt.Errorf("%s %s nodes need at least %d vCPU and %d GiB memory, %s has %d vCPU and %d GiB",
+				networkType, req.RpcType, rec.MinVCPU, rec.MinMemoryGiB, req.InstanceType, info.VCPU, info.MemoryGiB)
+		}
+		if req.DiskSize < rec.MinDiskSize {
+			return fmt.Errorf("%s %s nodes need at least %d GB of disk", networkType, req.RpcType, rec.MinDiskSize)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,446 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/0saurabh0/NodeEase/models"
+)
+
+const (
+	// DefaultClient is the validator client used when a request doesn't specify one
+	DefaultClient = "agave"
+
+	// firedancerConfigPath is where the Firedancer TOML config is written on the node
+	firedancerConfigPath = "/home/solana/firedancer.toml"
+)
+
+// validatorClient describes how to install and run a validator client flavor
+type validatorClient struct {
+	Flavor         string
+	DisplayName    string
+	Versions       []string
+	DefaultVersion string
+	Architectures  []string
+
+	// InstallTemplate is the template that installs the client
+	InstallTemplate string
+	// InstallURL returns the installer URL for a version, if the client has one
+	InstallURL func(version string) string
+	// InstallDir is where the client's binaries end up
+	InstallDir string
+	// ReleaseLink is the path switched between releases on upgrade. Releases
+	// are installed next to each other and this link points at the active one.
+	ReleaseLink string
+	// BuildsFromSource is set for clients without release binaries
+	BuildsFromSource bool
+	// SupportsRestartWindow is set when the binary has wait-for-restart-window
+	SupportsRestartWindow bool
+	// BinaryName is the validator binary inside InstallDir/bin
+	BinaryName string
+	// ServiceUser is the user the systemd unit starts as
+	ServiceUser string
+	// ConfigFile is set for clients configured through a file instead of flags
+	ConfigFile string
+	// KeygenCommand creates the identity keypair as the solana user
+	KeygenCommand string
+
+	// SupportedFlags lists the validator flags the client accepts. Flags mapped
+	// to true are honored, flags mapped to false are accepted but have no
+	// effect. Anything missing is rejected.
+	SupportedFlags map[string]bool
+	// ExtraArgs are the flags users may add themselves, see validateExtraArgs
+	ExtraArgs map[string]argSchema
+}
+
+// agaveFlags are the agave-validator flags NodeEase renders
+var agaveFlags = map[string]bool{
+	"--ledger":                              true,
+	"--accounts":                            true,
+	"--identity":                            true,
+	"--entrypoint":                          true,
+	"--known-validator":                     true,
+	"--expected-genesis-hash":               true,
+	"--expected-shred-version":              true,
+	"--account-index":                       true,
+	"--account-index-exclude-key":           true,
+	"--rpc-port":                            true,
+	"--rpc-bind-address":                    true,
+	"--private-rpc":                         true,
+	"--full-rpc-api":                        true,
+	"--no-untrusted-rpc":                    true,
+	"--dynamic-port-range":                  true,
+	"--wal-recovery-mode":                   true,
+	"--no-voting":                           true,
+	"--enable-rpc-transaction-history":      true,
+	"--enable-extended-tx-metadata-storage": true,
+	"--enable-cpi-and-log-storage":          true,
+	"--limit-ledger-size":                   true,
+	"--no-snapshot-fetch":                   true,
+
+	"--full-snapshot-interval-slots":            true,
+	"--incremental-snapshot-interval-slots":     true,
+	"--maximum-full-snapshots-to-retain":        true,
+	"--maximum-incremental-snapshots-to-retain": true,
+	"--enable-rpc-bigtable-ledger-storage":      true,
+	"--rpc-bigtable-instance-name":              true,
+	"--rpc-bigtable-timeout":                    true,
+}
+
+// firedancerFlags are the flags that can be translated into a Firedancer config
+var firedancerFlags = map[string]bool{
+	"--ledger":                              true,
+	"--accounts":                            true,
+	"--identity":                            true,
+	"--entrypoint":                          true,
+	"--known-validator":                     true,
+	"--expected-genesis-hash":               true,
+	"--expected-shred-version":              true,
+	"--account-index":                       true,
+	"--account-index-exclude-key":           true,
+	"--rpc-port":                            true,
+	"--private-rpc":                         true,
+	"--full-rpc-api":                        true,
+	"--no-untrusted-rpc":                    true,
+	"--dynamic-port-range":                  true,
+	"--enable-rpc-transaction-history":      true,
+	"--enable-extended-tx-metadata-storage": true,
+	"--limit-ledger-size":                   true,
+
+	"--full-snapshot-interval-slots":            true,
+	"--incremental-snapshot-interval-slots":     true,
+	"--maximum-full-snapshots-to-retain":        true,
+	"--maximum-incremental-snapshots-to-retain": true,
+
+	// Firedancer binds RPC on all interfaces, doesn't vote without a vote
+	// account and has no WAL recovery setting
+	"--rpc-bind-address":  false,
+	"--no-voting":         false,
+	"--wal-recovery-mode": false,
+}
+
+// validatorClients is the catalog of supported validator clients
+var validatorClients = map[string]validatorClient{
+	"agave": {
+		Flavor:          "agave",
+		DisplayName:     "Anza Agave",
+		Versions:        []string{"v2.1.21", "v2.2.14", "v2.2.16"},
+		DefaultVersion:  "v2.2.14",
+		Architectures:   []string{"amd64", "arm64"},
+		InstallTemplate: "install_anza.sh.tmpl",
+		InstallURL: func(version string) string {
+			return fmt.Sprintf("https://release.anza.xyz/%s/install", version)
+		},
+		InstallDir:            solanaInstallDir,
+		ReleaseLink:           solanaInstallDir,
+		SupportsRestartWindow: true,
+		BinaryName:            "agave-validator",
+		ServiceUser:           "solana",
+		KeygenCommand:         "solana-keygen new -o /data/solana/validator-keypair.json --no-bip39-passphrase",
+		SupportedFlags:        agaveFlags,
+		ExtraArgs:             agaveExtraArgs,
+	},
+	"jito-solana": {
+		Flavor:          "jito-solana",
+		DisplayName:     "Jito-Solana",
+		Versions:        []string{"v2.1.21", "v2.2.14", "v2.2.16"},
+		DefaultVersion:  "v2.2.14",
+		Architectures:   []string{"amd64", "arm64"},
+		InstallTemplate: "install_anza.sh.tmpl",
+		InstallURL: func(version string) string {
+			return fmt.Sprintf("https://release.jito.wtf/%s-jito/install", version)
+		},
+		InstallDir:            solanaInstallDir,
+		ReleaseLink:           solanaInstallDir,
+		SupportsRestartWindow: true,
+		BinaryName:            "agave-validator",
+		ServiceUser:           "solana",
+		KeygenCommand:         "solana-keygen new -o /data/solana/validator-keypair.json --no-bip39-passphrase",
+		SupportedFlags:        agaveFlags,
+		ExtraArgs:             mergeArgs(agaveExtraArgs, jitoExtraArgs),
+	},
+	"firedancer": {
+		Flavor:           "firedancer",
+		DisplayName:      "Firedancer (Frankendancer)",
+		Versions:         []string{"v0.503.20214"},
+		DefaultVersion:   "v0.503.20214",
+		Architectures:    []string{"amd64"}, // Firedancer only builds for x86_64
+		InstallTemplate:  "install_firedancer.sh.tmpl",
+		InstallDir:       "/home/solana/firedancer/build/native/gcc",
+		ReleaseLink:      "/home/solana/firedancer",
+		BuildsFromSource: true,
+		BinaryName:       "fdctl",
+		ServiceUser:      "root", // fdctl starts as root and drops to the configured user
+		ConfigFile:       firedancerConfigPath,
+		KeygenCommand:    "fdctl keys new identity --config " + firedancerConfigPath,
+		SupportedFlags:   firedancerFlags,
+	},
+}
+
+// resolveClient returns the client and version a request asks for, applying defaults
+func resolveClient(flavor, version string) (validatorClient, string, error) {
+	if flavor == "" {
+		flavor = DefaultClient
+	}
+
+	client, ok := validatorClients[flavor]
+	if !ok {
+		return validatorClient{}, "", fmt.Errorf("unsupported validator client %q", flavor)
+	}
+
+	if version == "" {
+		return client, client.DefaultVersion, nil
+	}
+
+	for _, supported := range client.Versions {
+		if supported == version {
+			return client, version, nil
+		}
+	}
+
+	return validatorClient{}, "", fmt.Errorf("%s version %s is not supported (available: %s)",
+		client.DisplayName, version, strings.Join(client.Versions, ", "))
+}
+
+// validateClientFlags rejects flags the client doesn't support
+func validateClientFlags(client validatorClient, flags []models.ValidatorFlag) error {
+	var unsupported []string
+	seen := map[string]bool{}
+	for _, f := range flags {
+		_, managed := client.SupportedFlags[f.Name]
+		_, extra := client.ExtraArgs[f.Name]
+		if !managed && !extra && !seen[f.Name] {
+			unsupported = append(unsupported, f.Name)
+			seen[f.Name] = true
+		}
+	}
+
+	if len(unsupported) > 0 {
+		return fmt.Errorf("%s does not support %s", client.DisplayName, strings.Join(unsupported, ", "))
+	}
+
+	return nil
+}
+
+// validateClientSelection checks the requested client, version and the flags
+// the request would render for it
+func validateClientSelection(req models.NodeDeployRequest) error {
+	client, _, err := resolveClient(req.Client, req.ClientVersion)
+	if err != nil {
+		return err
+	}
+
+	arch := req.Architecture
+	if arch == "" {
+		arch = DefaultArchitecture
+	}
+	supported := false
+	for _, a := range client.Architectures {
+		if a == arch {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return fmt.Errorf("%s is not available for %s", client.DisplayName, arch)
+	}
+
+	if err := validateExtraArgs(client, req.ExtraArgs); err != nil {
+		return err
+	}
+
+	return validateClientFlags(client, buildValidatorFlags(buildNodeConfig(req, models.Cluster{}, "", "")))
+}
+
+// GetValidatorClients lists the supported validator clients and versions
+func GetValidatorClients() []models.ValidatorClientInfo {
+	clients := make([]models.ValidatorClientInfo, 0, len(validatorClients))
+	for _, client := range validatorClients {
+		flags := make([]string, 0, len(client.SupportedFlags))
+		for name, honored := range client.SupportedFlags {
+			if honored {
+				flags = append(flags, name)
+			}
+		}
+		sort.Strings(flags)
+
+		clients = append(clients, models.ValidatorClientInfo{
+			Flavor:         client.Flavor,
+			DisplayName:    client.DisplayName,
+			Versions:       client.Versions,
+			DefaultVersion: client.DefaultVersion,
+			BinaryName:     client.BinaryName,
+			Architectures:  client.Architectures,
+			SupportedFlags: flags,
+			ExtraArgs:      extraArgInfo(client),
+		})
+	}
+
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].Flavor < clients[j].Flavor
+	})
+
+	return clients
+}
+
+// renderFiredancerConfig translates validator flags into a Firedancer TOML config
+func renderFiredancerConfig(flags []models.ValidatorFlag) string {
+	var (
+		ledger, accounts, identity, genesisHash, portRange, shredVersion string
+		rpcPort, limitLedger                                             string
+		fullInterval, incrementalInterval, maxFull, maxIncremental       string
+		entrypoints, knownValidators, indexes, excludeKeys               []string
+		fullAPI, private, onlyKnown, history, extendedMetadata           bool
+	)
+
+	for _, f := range flags {
+		value := ""
+		if len(f.Values) > 0 {
+			value = f.Values[0]
+		}
+
+		switch f.Name {
+		case "--ledger":
+			ledger = value
+		case "--accounts":
+			accounts = value
+		case "--identity":
+			identity = value
+		case "--entrypoint":
+			entrypoints = append(entrypoints, value)
+		case "--known-validator":
+			knownValidators = append(knownValidators, value)
+		case "--expected-genesis-hash":
+			genesisHash = value
+		case "--expected-shred-version":
+			shredVersion = value
+		case "--account-index":
+			indexes = appendUnique(indexes, f.Values...)
+		case "--account-index-exclude-key":
+			excludeKeys = append(excludeKeys, value)
+		case "--rpc-port":
+			rpcPort = value
+		case "--private-rpc":
+			private = true
+		case "--full-rpc-api":
+			fullAPI = true
+		case "--no-untrusted-rpc":
+			onlyKnown = true
+		case "--dynamic-port-range":
+			portRange = value
+		case "--enable-rpc-transaction-history":
+			history = true
+		case "--enable-extended-tx-metadata-storage":
+			extendedMetadata = true
+		case "--limit-ledger-size":
+			limitLedger = value
+			if limitLedger == "" {
+				limitLedger = "200000000"
+			}
+		case "--full-snapshot-interval-slots":
+			fullInterval = value
+		case "--incremental-snapshot-interval-slots":
+			incrementalInterval = value
+		case "--maximum-full-snapshots-to-retain":
+			maxFull = value
+		case "--maximum-incremental-snapshots-to-retain":
+			maxIncremental = value
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("user = \"solana\"\n")
+	if portRange != "" {
+		fmt.Fprintf(&b, "dynamic_port_range = %s\n", tomlString(portRange))
+	}
+
+	b.WriteString("\n[ledger]\n")
+	fmt.Fprintf(&b, "path = %s\n", tomlString(ledger))
+	if accounts != "" {
+		fmt.Fprintf(&b, "accounts_path = %s\n", tomlString(accounts))
+	}
+	if limitLedger != "" {
+		fmt.Fprintf(&b, "limit_size = %s\n", limitLedger)
+	}
+	if len(indexes) > 0 {
+		fmt.Fprintf(&b, "account_indexes = %s\n", tomlArray(indexes))
+	}
+	if len(excludeKeys) > 0 {
+		fmt.Fprintf(&b, "account_index_exclude_keys = %s\n", tomlArray(excludeKeys))
+	}
+
+	b.WriteString("\n[gossip]\n")
+	fmt.Fprintf(&b, "entrypoints = %s\n", tomlArray(entrypoints))
+
+	b.WriteString("\n[consensus]\n")
+	fmt.Fprintf(&b, "identity_path = %s\n", tomlString(identity))
+	if genesisHash != "" {
+		fmt.Fprintf(&b, "expected_genesis_hash = %s\n", tomlString(genesisHash))
+	}
+	if shredVersion != "" {
+		fmt.Fprintf(&b, "expected_shred_version = %s\n", shredVersion)
+	}
+	if len(knownValidators) > 0 {
+		fmt.Fprintf(&b, "known_validators = %s\n", tomlArray(knownValidators))
+	}
+
+	if fullInterval != "" || incrementalInterval != "" || maxFull != "" || maxIncremental != "" {
+		b.WriteString("\n[snapshots]\n")
+		if fullInterval != "" {
+			fmt.Fprintf(&b, "full_snapshot_interval_slots = %s\n", fullInterval)
+		}
+		if incrementalInterval != "" {
+			fmt.Fprintf(&b, "incremental_snapshot_interval_slots = %s\n", incrementalInterval)
+		}
+		if maxFull != "" {
+			fmt.Fprintf(&b, "maximum_full_snapshots_to_retain = %s\n", maxFull)
+		}
+		if maxIncremental != "" {
+			fmt.Fprintf(&b, "maximum_incremental_snapshots_to_retain = %s\n", maxIncremental)
+		}
+	}
+
+	b.WriteString("\n[rpc]\n")
+	if rpcPort != "" {
+		fmt.Fprintf(&b, "port = %s\n", rpcPort)
+	}
+	fmt.Fprintf(&b, "full_api = %t\n", fullAPI)
+	fmt.Fprintf(&b, "private = %t\n", private)
+	fmt.Fprintf(&b, "only_known = %t\n", onlyKnown)
+	fmt.Fprintf(&b, "transaction_history = %t\n", history)
+	fmt.Fprintf(&b, "extended_tx_metadata_storage = %t\n", extendedMetadata)
+
+	return b.String()
+}
+
+// appendUnique appends values that aren't already present
+func appendUnique(list []string, values ...string) []string {
+	for _, value := range values {
+		found := false
+		for _, existing := range list {
+			if existing == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+// tomlString quotes a value as a TOML basic string
+func tomlString(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`
+}
+
+// tomlArray renders a list of strings as a TOML array
+func tomlArray(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = tomlString(value)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
@@ -0,0 +1,252 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/0saurabh0/NodeEase/db/repository"
+	"github.com/0saurabh0/NodeEase/models"
+)
+
+// maxClusterPeers bounds how many entrypoints or known validators a cluster lists
+const maxClusterPeers = 32
+
+var (
+	// clusterIDPattern matches cluster IDs, which are used as network types
+	clusterIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,39}$`)
+
+	// entrypointPattern matches a gossip entrypoint host:port
+	entrypointPattern = regexp.MustCompile(`^[A-Za-z0-9.-]+:[0-9]{1,5}$`)
+)
+
+// defaultClusters are seeded into the catalog on startup. Admins can edit
+// them afterwards; seeding never overwrites an existing cluster.
+var defaultClusters = []models.Cluster{
+	{
+		ID:   "mainnet-beta",
+		Name: "Mainnet Beta",
+		Entrypoints: []string{
+			"entrypoint.mainnet-beta.solana.com:8001",
+			"entrypoint2.mainnet-beta.solana.com:8001",
+			"entrypoint3.mainnet-beta.solana.com:8001",
+			"entrypoint4.mainnet-beta.solana.com:8001",
+			"entrypoint5.mainnet-beta.solana.com:8001",
+		},
+		KnownValidators: []string{
+			"5D1fNXzvv5NjV1ysLjirC4WY92RNsVH18vjmcszZd8on",
+			"dDzy5SR3AXdYWVqbDEkVFdvSPCtS9ihF5kJkHCtXoFs",
+			"eoKpUABi59aT4rR9HGS3LcMecfut9x7zJyodWWP43YQ",
+			"7XSY3MrYnK8vq693Rju17bbPkCN3Z7KvvfvJx4kdrsSY",
+			"Ft5fbkqNa76vnsjYNwjDZUXoTWpP7VYm3mtsaQckQADN",
+			"9QxCLckBiJc783jnMvXZubK4wH86Eqqvashtrwvcsgkv",
+			"7Np41oeYqPefeNQEHSv1UDhYrehxin3NStELsSKCT4K2",
+			"GdnSyH3YtwcxFvQrVVJMm1JhTS4QVX7MFsX56uJLUfiZ",
+			"DE1bawNcRJB9rVm3buyMVfr8mBEoyyu73NBovf2oXJsJ",
+		},
+		ExpectedGenesisHash: "5eykt4UsFv8P8NJdTREpY1vzqKqZKvdpKuc147dw2N9d",
+	},
+	{
+		ID:   "testnet",
+		Name: "Testnet",
+		Entrypoints: []string{
+			"entrypoint.testnet.solana.com:8001",
+			"entrypoint2.testnet.solana.com:8001",
+			"entrypoint3.testnet.solana.com:8001",
+		},
+		KnownValidators: []string{
+			"5D1fNXzvv5NjV1ysLjirC4WY92RNsVH18vjmcszZd8on",
+			"Ft5fbkqNa76vnsjYNwjDZUXoTWpP7VYm3mtsaQckQADN",
+			"7XSY3MrYnK8vq693Rju17bbPkCN3Z7KvvfvJx4kdrsSY",
+		},
+		ExpectedGenesisHash: "4uhcVJyU9pJkvQyS88uRDiswHXSCkY3zQawwpjk2NsNY",
+	},
+	{
+		ID:   "devnet",
+		Name: "Devnet",
+		Entrypoints: []string{
+			"entrypoint.devnet.solana.com:8001",
+			"entrypoint2.devnet.solana.com:8001",
+			"entrypoint3.devnet.solana.com:8001",
+		},
+		KnownValidators: []string{
+			"dv1ZAGvdsz5hHLwWXsVnM94hWf1pjbKVau1QVkaMJ92",
+			"dv2eQHeP4RFrJZ6UeiZWoc3XTtmtZCUKxxCApCDcRNV",
+			"dv4ACNkpYPcE3aKmYDqZm9G5EB3J4MRoeE7WNDRBVJB",
+		},
+		ExpectedGenesisHash: "EtWTRABZaYq6iMfeYKouRu166VU2xqa1wcaWoxPkrZBG",
+	},
+}
+
+// isDefaultCluster reports whether a cluster ID is one of the seeded clusters
+func isDefaultCluster(id string) bool {
+	for _, cluster := range defaultClusters {
+		if cluster.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// SeedDefaultClusters adds the public Solana clusters to the catalog
+func SeedDefaultClusters() error {
+	now := time.Now()
+	for _, cluster := range defaultClusters {
+		cluster.CreatedAt = now
+		cluster.UpdatedAt = now
+		if _, err := repository.CreateCluster(cluster); err != nil {
+			return fmt.Errorf("failed to seed cluster %s: %v", cluster.ID, err)
+		}
+	}
+	return nil
+}
+
+// resolveCluster looks up the cluster a network type names, as seen by the
+// user. The user's own private cluster comes before a public one.
+func resolveCluster(userID, networkType string) (models.Cluster, error) {
+	id := clusterForNetworkType(networkType)
+
+	cluster, err := repository.GetCluster(id, userID)
+	if err == nil && cluster.ID == "" {
+		cluster, err = repository.GetCluster(id, "")
+	}
+	if err != nil {
+		return models.Cluster{}, fmt.Errorf("failed to load cluster: %v", err)
+	}
+	if cluster.ID == "" {
+		return models.Cluster{}, fmt.Errorf("unknown network %q", networkType)
+	}
+
+	return cluster, nil
+}
+
+// validateCluster checks a cluster definition
+func validateCluster(cluster models.Cluster) error {
+	if !clusterIDPattern.MatchString(cluster.ID) {
+		return fmt.Errorf("cluster ID must be 2-40 lowercase letters, digits or dashes")
+	}
+	if clusterForNetworkType(cluster.ID) != cluster.ID {
+		return fmt.Errorf("cluster ID %q is reserved", cluster.ID)
+	}
+	if cluster.Name == "" {
+		return fmt.Errorf("cluster name is required")
+	}
+
+	if len(cluster.Entrypoints) == 0 {
+		return fmt.Errorf("at least one entrypoint is required")
+	}
+	if len(cluster.Entrypoints) > maxClusterPeers || len(cluster.KnownValidators) > maxClusterPeers {
+		return fmt.Errorf("clusters can list at most %d entrypoints and %d known validators", maxClusterPeers, maxClusterPeers)
+	}
+	for _, entrypoint := range cluster.Entrypoints {
+		if !entrypointPattern.MatchString(entrypoint) {
+			return fmt.Errorf("entrypoint %q must be host:port", entrypoint)
+		}
+	}
+	for _, key := range cluster.KnownValidators {
+		if !pubkeyPattern.MatchString(key) {
+			return fmt.Errorf("known validator %q is not a valid public key", key)
+		}
+	}
+
+	if cluster.ExpectedGenesisHash != "" && !pubkeyPattern.MatchString(cluster.ExpectedGenesisHash) {
+		return fmt.Errorf("expected genesis hash %q is not a valid hash", cluster.ExpectedGenesisHash)
+	}
+	if cluster.ExpectedShredVersion < 0 || cluster.ExpectedShredVersion > 65535 {
+		return fmt.Errorf("expected shred version must be between 0 and 65535")
+	}
+
+	return nil
+}
+
+// ListClusters lists the public clusters and the user's private ones
+func ListClusters(userID string) ([]models.Cluster, error) {
+	return repository.ListClusters(userID)
+}
+
+// CreateCluster adds a cluster to the catalog. Clusters with an owner are
+// private to that user; admins create public clusters with an empty owner.
+func CreateCluster(ownerID string, cluster models.Cluster) (models.Cluster, error) {
+	if err := validateCluster(cluster); err != nil {
+		return models.Cluster{}, err
+	}
+
+	// Private IDs are scoped to their owner, but can't shadow a public cluster
+	if ownerID != "" {
+		public, err := repository.GetCluster(cluster.ID, "")
+		if err != nil {
+			return models.Cluster{}, fmt.Errorf("failed to load cluster: %v", err)
+		}
+		if public.ID != "" {
+			return models.Cluster{}, fmt.Errorf("cluster ID %q is used by a public cluster", cluster.ID)
+		}
+	}
+
+	now := time.Now()
+	cluster.OwnerID = ownerID
+	cluster.Private = ownerID != ""
+	cluster.CreatedAt = now
+	cluster.UpdatedAt = now
+
+	created, err := repository.CreateCluster(cluster)
+	if err != nil {
+		return models.Cluster{}, fmt.Errorf("failed to save cluster: %v", err)
+	}
+	if !created {
+		return models.Cluster{}, fmt.Errorf("you already have a cluster with ID %q", cluster.ID)
+	}
+
+	return cluster, nil
+}
+
+// UpdateCluster replaces the definition of a cluster owned by ownerID. Nodes
+// already deployed keep the peers they were configured with.
+func UpdateCluster(ownerID, id string, cluster models.Cluster) (models.Cluster, error) {
+	existing, err := repository.GetCluster(id, ownerID)
+	if err != nil {
+		return models.Cluster{}, err
+	}
+	if existing.ID == "" {
+		return models.Cluster{}, fmt.Errorf("cluster not found or you don't have permission")
+	}
+
+	cluster.ID = id
+	if err := validateCluster(cluster); err != nil {
+		return models.Cluster{}, err
+	}
+
+	cluster.OwnerID = ownerID
+	cluster.Private = ownerID != ""
+	cluster.CreatedAt = existing.CreatedAt
+	cluster.UpdatedAt = time.Now()
+
+	if err := repository.UpdateCluster(cluster); err != nil {
+		return models.Cluster{}, fmt.Errorf("failed to update cluster: %v", err)
+	}
+
+	return cluster, nil
+}
+
+// DeleteCluster removes a cluster owned by ownerID that no node was deployed to
+func DeleteCluster(ownerID, id string) error {
+	existing, err := repository.GetCluster(id, ownerID)
+	if err != nil {
+		return err
+	}
+	if existing.ID == "" {
+		return fmt.Errorf("cluster not found or you don't have permission")
+	}
+	if isDefaultCluster(id) {
+		return fmt.Errorf("%s is a built-in cluster and can't be deleted", id)
+	}
+
+	inUse, err := repository.ClusterHasNodes(id, ownerID)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return fmt.Errorf("nodes were deployed to %s, delete them before the cluster", id)
+	}
+
+	return repository.DeleteCluster(id, ownerID)
+}
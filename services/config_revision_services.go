@@ -0,0 +1,304 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/0saurabh0/NodeEase/db/repository"
+	"github.com/0saurabh0/NodeEase/models"
+)
+
+// diffContextLines is how many unchanged lines surround each change in a diff
+const diffContextLines = 3
+
+// ignoredConfigFields are identical for every revision of a node
+var ignoredConfigFields = map[string]bool{
+	"nodeId":     true,
+	"apiBaseUrl": true,
+}
+
+// ListNodeConfigRevisions lists every config revision of a node, newest first
+func ListNodeConfigRevisions(nodeID, userID string) ([]models.NodeConfigRevision, error) {
+	return repository.ListNodeConfigRevisions(nodeID, userID)
+}
+
+// GetNodeConfigRevision returns a single config revision of a node
+func GetNodeConfigRevision(nodeID, userID string, number int) (models.NodeConfigRevision, error) {
+	revision, err := repository.GetNodeConfigRevision(nodeID, userID, number)
+	if err != nil {
+		return models.NodeConfigRevision{}, err
+	}
+	if revision.ID == "" {
+		return models.NodeConfigRevision{}, fmt.Errorf("revision %d not found", number)
+	}
+	return revision, nil
+}
+
+// DiffNodeConfigRevisions compares two config revisions of a node
+func DiffNodeConfigRevisions(nodeID, userID string, from, to int) (models.NodeConfigDiff, error) {
+	fromRevision, err := GetNodeConfigRevision(nodeID, userID, from)
+	if err != nil {
+		return models.NodeConfigDiff{}, err
+	}
+	toRevision, err := GetNodeConfigRevision(nodeID, userID, to)
+	if err != nil {
+		return models.NodeConfigDiff{}, err
+	}
+
+	changes, err := configChanges(fromRevision.Config, toRevision.Config)
+	if err != nil {
+		return models.NodeConfigDiff{}, err
+	}
+	if fromRevision.ClientVersion != toRevision.ClientVersion {
+		changes = append(changes, models.NodeConfigChange{
+			Field: "clientVersion",
+			From:  fromRevision.ClientVersion,
+			To:    toRevision.ClientVersion,
+		})
+	}
+
+	return models.NodeConfigDiff{
+		FromRevision: from,
+		ToRevision:   to,
+		Changes:      changes,
+		UnitDiff: unifiedDiff(
+			fmt.Sprintf("revision %d/solana-validator.service", from),
+			fmt.Sprintf("revision %d/solana-validator.service", to),
+			fromRevision.SystemdUnit, toRevision.SystemdUnit,
+		),
+		ClientDiff: unifiedDiff(
+			fmt.Sprintf("revision %d/client config", from),
+			fmt.Sprintf("revision %d/client config", to),
+			fromRevision.ClientConfig, toRevision.ClientConfig,
+		),
+	}, nil
+}
+
+// RollbackNodeConfig re-applies the configuration of an earlier revision. The
+// rollback is recorded as a new revision.
+func RollbackNodeConfig(nodeID, userID string, number int) (models.NodeConfigRevision, error) {
+	node, err := repository.GetNodeByID(nodeID, userID)
+	if err != nil {
+		return models.NodeConfigRevision{}, err
+	}
+	if node.ID == "" {
+		return models.NodeConfigRevision{}, fmt.Errorf("node not found or you don't have permission")
+	}
+	if err := requireBootstrappedNode(node, "rolled back"); err != nil {
+		return models.NodeConfigRevision{}, err
+	}
+	if node.Status != "running" {
+		return models.NodeConfigRevision{}, fmt.Errorf("node is %s, only running nodes can be rolled back", node.Status)
+	}
+
+	target, err := GetNodeConfigRevision(nodeID, userID, number)
+	if err != nil {
+		return models.NodeConfigRevision{}, err
+	}
+	if target.Status != "applied" {
+		return models.NodeConfigRevision{}, fmt.Errorf("revision %d was never applied", number)
+	}
+
+	// Unit files don't pin the client version, so a different version needs an upgrade
+	if target.ClientVersion != node.ClientVersion {
+		return models.NodeConfigRevision{}, fmt.Errorf("revision %d ran %s but the node runs %s, upgrade the node to %s first",
+			number, target.ClientVersion, node.ClientVersion, target.ClientVersion)
+	}
+
+	cfg := target.Config
+	cfg.Client = node.Client
+	cfg.ClientVersion = node.ClientVersion
+	cfg.DeployToken = node.DeployToken
+
+	return applyNodeConfig(node, cfg, models.NodeConfigRevision{
+		Reason:     "rollback",
+		RollbackOf: number,
+	})
+}
+
+// configChanges lists the config fields that differ between two configs
+func configChanges(from, to models.NodeConfig) ([]models.NodeConfigChange, error) {
+	fromFields, err := flattenConfig(from)
+	if err != nil {
+		return nil, err
+	}
+	toFields, err := flattenConfig(to)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := map[string]bool{}
+	for key := range fromFields {
+		keys[key] = true
+	}
+	for key := range toFields {
+		keys[key] = true
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	changes := []models.NodeConfigChange{}
+	for _, key := range sorted {
+		if ignoredConfigFields[key] || reflect.DeepEqual(fromFields[key], toFields[key]) {
+			continue
+		}
+		changes = append(changes, models.NodeConfigChange{Field: key, From: fromFields[key], To: toFields[key]})
+	}
+
+	return changes, nil
+}
+
+// flattenConfig turns a config into dotted field paths and their JSON values
+func flattenConfig(cfg models.NodeConfig) (map[string]interface{}, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal node config: %v", err)
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal node config: %v", err)
+	}
+
+	fields := map[string]interface{}{}
+	var flatten func(prefix string, value map[string]interface{})
+	flatten = func(prefix string, value map[string]interface{}) {
+		for key, v := range value {
+			if nested, ok := v.(map[string]interface{}); ok {
+				flatten(prefix+key+".", nested)
+				continue
+			}
+			fields[prefix+key] = v
+		}
+	}
+	flatten("", raw)
+
+	return fields, nil
+}
+
+// diffLine is one line of a line-based diff
+type diffLine struct {
+	kind     byte // ' ', '-' or '+'
+	text     string
+	fromLine int
+	toLine   int
+}
+
+// unifiedDiff renders a unified diff between two texts, or "" when equal
+func unifiedDiff(fromName, toName, a, b string) string {
+	if a == b {
+		return ""
+	}
+
+	lines := diffLines(splitLines(a), splitLines(b))
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+
+	for i := 0; i < len(lines); {
+		// Find the next change
+		if lines[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		// Extend the hunk while changes are close together
+		start := i - diffContextLines
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for j := i; j < len(lines); j++ {
+			if lines[j].kind != ' ' {
+				end = j
+			} else if j-end > 2*diffContextLines {
+				break
+			}
+		}
+		end += diffContextLines
+		if end >= len(lines) {
+			end = len(lines) - 1
+		}
+
+		fromStart, toStart, fromCount, toCount := lines[start].fromLine, lines[start].toLine, 0, 0
+		for _, line := range lines[start : end+1] {
+			if line.kind != '+' {
+				fromCount++
+			}
+			if line.kind != '-' {
+				toCount++
+			}
+		}
+
+		// An empty side points at the line before the hunk
+		if fromCount == 0 {
+			fromStart--
+		}
+		if toCount == 0 {
+			toStart--
+		}
+
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", fromStart, fromCount, toStart, toCount)
+		for _, line := range lines[start : end+1] {
+			fmt.Fprintf(&out, "%c%s\n", line.kind, line.text)
+		}
+
+		i = end + 1
+	}
+
+	return out.String()
+}
+
+// splitLines splits text into lines without the trailing newline
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffLines computes a line diff from the longest common subsequence
+func diffLines(a, b []string) []diffLine {
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i], i + 1, j + 1})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{'-', a[i], i + 1, j + 1})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j], i + 1, j + 1})
+			j++
+		}
+	}
+
+	return lines
+}
@@ -0,0 +1,275 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/0saurabh0/NodeEase/db/repository"
+	"github.com/0saurabh0/NodeEase/models"
+	"github.com/google/uuid"
+)
+
+const (
+	// minLedgerShreds is the smallest --limit-ledger-size agave accepts
+	minLedgerShreds = 50_000_000
+
+	// reconfigureTimeout bounds writing the new unit and restarting the validator
+	reconfigureTimeout = 5 * time.Minute
+
+	// reconfigureExitRestored means the node kept its previous configuration
+	reconfigureExitRestored = 3
+)
+
+// supportedAccountIndexes are the values --account-index accepts
+var supportedAccountIndexes = map[string]bool{
+	"program-id":      true,
+	"spl-token-owner": true,
+	"spl-token-mint":  true,
+}
+
+// pubkeyPattern matches a base58 encoded Solana public key
+var pubkeyPattern = regexp.MustCompile(`^[1-9A-HJ-NP-Za-km-z]{32,44}$`)
+
+// reconfigureTemplateData is what the reconfigure script is executed with
+type reconfigureTemplateData struct {
+	Client       validatorClient
+	Unit         string
+	ClientConfig string
+}
+
+// applyConfigDelta returns the settings with the delta's fields applied
+func applyConfigDelta(settings models.ValidatorSettings, delta models.NodeConfigDelta) models.ValidatorSettings {
+	if delta.AccountIndexes != nil {
+		settings.AccountIndexes = *delta.AccountIndexes
+	}
+	if delta.AccountIndexExcludeKeys != nil {
+		settings.AccountIndexExcludeKeys = *delta.AccountIndexExcludeKeys
+	}
+	if delta.PrivateRPC != nil {
+		settings.PrivateRPC = *delta.PrivateRPC
+	}
+	if delta.OnlyKnownRPC != nil {
+		settings.OnlyKnownRPC = *delta.OnlyKnownRPC
+	}
+	if delta.FullRPCAPI != nil {
+		settings.FullRPCAPI = *delta.FullRPCAPI
+	}
+	if delta.TransactionHistory != nil {
+		settings.TransactionHistory = *delta.TransactionHistory
+	}
+	if delta.ExtendedTxMetadataStorage != nil {
+		settings.ExtendedTxMetadataStorage = *delta.ExtendedTxMetadataStorage
+	}
+	if delta.CPIAndLogStorage != nil {
+		settings.CPIAndLogStorage = *delta.CPIAndLogStorage
+	}
+	if delta.LimitLedger != nil {
+		settings.LimitLedger = *delta.LimitLedger
+	}
+	if delta.LimitLedgerShreds != nil {
+		settings.LimitLedgerShreds = *delta.LimitLedgerShreds
+	}
+	if delta.NoSnapshotFetch != nil {
+		settings.NoSnapshotFetch = *delta.NoSnapshotFetch
+	}
+	if delta.ExtraArgs != nil {
+		settings.ExtraArgs = *delta.ExtraArgs
+	}
+	return settings
+}
+
+// validateValidatorSettings checks that settings make sense together
+func validateValidatorSettings(settings models.ValidatorSettings) error {
+	seen := map[string]bool{}
+	for _, index := range settings.AccountIndexes {
+		if !supportedAccountIndexes[index] {
+			return fmt.Errorf("unsupported account index %q", index)
+		}
+		if seen[index] {
+			return fmt.Errorf("account index %q is listed twice", index)
+		}
+		seen[index] = true
+	}
+
+	if len(settings.AccountIndexExcludeKeys) > 0 && len(settings.AccountIndexes) == 0 {
+		return fmt.Errorf("account index exclude keys require at least one account index")
+	}
+	for _, key := range settings.AccountIndexExcludeKeys {
+		if !pubkeyPattern.MatchString(key) {
+			return fmt.Errorf("account index exclude key %q is not a valid public key", key)
+		}
+	}
+
+	if settings.ExtendedTxMetadataStorage && !settings.TransactionHistory {
+		return fmt.Errorf("extended transaction metadata storage requires transaction history")
+	}
+	if settings.CPIAndLogStorage && !settings.TransactionHistory {
+		return fmt.Errorf("CPI and log storage requires transaction history")
+	}
+
+	if settings.LimitLedgerShreds < 0 {
+		return fmt.Errorf("ledger size limit can't be negative")
+	}
+	if settings.LimitLedgerShreds > 0 {
+		if !settings.LimitLedger {
+			return fmt.Errorf("a ledger size in shreds requires the ledger size limit to be enabled")
+		}
+		if settings.LimitLedgerShreds < minLedgerShreds {
+			return fmt.Errorf("ledger size limit must be at least %d shreds", minLedgerShreds)
+		}
+	}
+
+	return nil
+}
+
+// currentNodeConfig returns the configuration a node runs. Nodes deployed
+// before revisions were recorded get theirs rebuilt from the node record.
+func currentNodeConfig(node models.Node) (models.NodeConfig, error) {
+	latest, err := repository.GetLatestAppliedConfigRevision(node.ID)
+	if err != nil {
+		return models.NodeConfig{}, fmt.Errorf("failed to load node config: %v", err)
+	}
+
+	var cfg models.NodeConfig
+	if latest.ID != "" {
+		cfg = latest.Config
+	} else {
+		cluster, err := resolveCluster(node.UserID, node.NetworkType)
+		if err != nil {
+			return models.NodeConfig{}, err
+		}
+		cfg = buildNodeConfig(models.NodeDeployRequest{
+			NodeName:      node.Name,
+			RpcType:       node.NodeType,
+			NetworkType:   node.NetworkType,
+			HistoryLength: node.HistoryLength,
+			Client:        node.Client,
+			// Only whether any data sits on instance storage matters for the unit
+			InstanceStoreAccounts: node.EphemeralStorage,
+		}, cluster, node.ID, node.DeployToken)
+	}
+
+	// Upgrades change the version without a new revision
+	cfg.Client = node.Client
+	cfg.ClientVersion = node.ClientVersion
+	cfg.DeployToken = node.DeployToken
+
+	return cfg, nil
+}
+
+// renderReconfigureScript renders the script that installs a config on a node
+func renderReconfigureScript(rendered models.RenderedNodeScripts) (string, error) {
+	client, _, err := resolveClient(rendered.Config.Client, rendered.Config.ClientVersion)
+	if err != nil {
+		return "", err
+	}
+
+	var script bytes.Buffer
+	err = bootstrapTemplates.ExecuteTemplate(&script, "reconfigure.sh.tmpl", reconfigureTemplateData{
+		Client:       client,
+		Unit:         rendered.SystemdUnit,
+		ClientConfig: rendered.ClientConfig,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render reconfigure script: %v", err)
+	}
+
+	return script.String(), nil
+}
+
+// applyNodeConfig installs a config on a running node and records the outcome
+// as a new revision. The caller fills in why the revision is being made.
+func applyNodeConfig(node models.Node, cfg models.NodeConfig, revision models.NodeConfigRevision) (models.NodeConfigRevision, error) {
+	rendered, err := RenderNodeScripts(cfg)
+	if err != nil {
+		return models.NodeConfigRevision{}, err
+	}
+
+	script, err := renderReconfigureScript(rendered)
+	if err != nil {
+		return models.NodeConfigRevision{}, err
+	}
+
+	revision.ID = uuid.New().String()
+	revision.NodeID = node.ID
+	revision.UserID = node.UserID
+	revision.Config = cfg
+	revision.SystemdUnit = rendered.SystemdUnit
+	revision.ClientConfig = rendered.ClientConfig
+	revision.ClientVersion = cfg.ClientVersion
+	revision.Status = "applied"
+	revision.CreatedAt = time.Now()
+
+	// Apply the configuration through the agent, or over SSH
+	output, exitCode, err := execNodeScript(node, models.AgentCommandApplyConfig, script, reconfigureTimeout)
+	switch {
+	case err != nil:
+		revision.Status = "failed"
+		revision.Message = err.Error()
+	case exitCode == reconfigureExitRestored:
+		revision.Status = "failed"
+		revision.Message = "Validator did not start with the new configuration, the previous one was restored:\n" + tailLines(output, 20)
+	case exitCode != 0:
+		revision.Status = "failed"
+		revision.Message = fmt.Sprintf("Reconfigure script exited with code %d:\n%s", exitCode, tailLines(output, 20))
+	}
+
+	revision, saveErr := repository.SaveNodeConfigRevision(revision)
+	if saveErr != nil {
+		return models.NodeConfigRevision{}, fmt.Errorf("failed to save config revision: %v", saveErr)
+	}
+
+	if revision.Status == "applied" {
+		updateNodeWithLog(node.ID, node.Status, "reconfigure", fmt.Sprintf("Applied configuration revision %d", revision.Revision), 100)
+	} else {
+		updateNodeWithLog(node.ID, node.Status, "reconfigure", fmt.Sprintf("Configuration revision %d failed", revision.Revision), 0)
+	}
+
+	return revision, nil
+}
+
+// ReconfigureNode applies a settings change to a running node without redeploying
+func ReconfigureNode(nodeID, userID string, delta models.NodeConfigDelta) (models.NodeConfigRevision, error) {
+	node, err := repository.GetNodeByID(nodeID, userID)
+	if err != nil {
+		return models.NodeConfigRevision{}, err
+	}
+	if node.ID == "" {
+		return models.NodeConfigRevision{}, fmt.Errorf("node not found or you don't have permission")
+	}
+	if err := requireBootstrappedNode(node, "reconfigured"); err != nil {
+		return models.NodeConfigRevision{}, err
+	}
+	if node.Status != "running" {
+		return models.NodeConfigRevision{}, fmt.Errorf("node is %s, only running nodes can be reconfigured", node.Status)
+	}
+
+	cfg, err := currentNodeConfig(node)
+	if err != nil {
+		return models.NodeConfigRevision{}, err
+	}
+
+	// Apply and validate the change
+	cfg.Settings = applyConfigDelta(cfg.Settings, delta)
+	if err := validateValidatorSettings(cfg.Settings); err != nil {
+		return models.NodeConfigRevision{}, err
+	}
+
+	client, _, err := resolveClient(cfg.Client, cfg.ClientVersion)
+	if err != nil {
+		return models.NodeConfigRevision{}, err
+	}
+	if err := validateExtraArgs(client, cfg.Settings.ExtraArgs); err != nil {
+		return models.NodeConfigRevision{}, err
+	}
+	if err := validateClientFlags(client, buildValidatorFlags(cfg)); err != nil {
+		return models.NodeConfigRevision{}, err
+	}
+
+	return applyNodeConfig(node, cfg, models.NodeConfigRevision{
+		Reason: "reconfigure",
+		Delta:  &delta,
+	})
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/0saurabh0/NodeEase/db/repository"
+	"github.com/0saurabh0/NodeEase/models"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+const (
+	// deployWatchdogInterval is how often deploying nodes are checked for progress
+	deployWatchdogInterval = time.Minute
+
+	// consoleOutputLines is how much of the EC2 console output is kept
+	consoleOutputLines = 40
+)
+
+// deployStepTimeout is how long a deploy may stay on a step before the next
+// update arrives. Past Stall the node is flagged as stalled, past Fail the
+// deploy is failed.
+type deployStepTimeout struct {
+	Stall time.Duration
+	Fail  time.Duration
+}
+
+// defaultDeployStepTimeout applies to steps without their own entry
+var defaultDeployStepTimeout = deployStepTimeout{Stall: 15 * time.Minute, Fail: 45 * time.Minute}
+
+// deployStepTimeouts are keyed by the last step a deploy reported, from the
+// provider monitors and the bootstrap's update_status calls
+var deployStepTimeouts = map[string]deployStepTimeout{
+	// The machine is being created
+	"provision":    {Stall: 10 * time.Minute, Fail: 30 * time.Minute},
+	"provisioning": {Stall: 10 * time.Minute, Fail: 30 * time.Minute},
+	"pending":      {Stall: 10 * time.Minute, Fail: 30 * time.Minute},
+	// The startup script should report within a few minutes of boot
+	"vm_ready": {Stall: 10 * time.Minute, Fail: 30 * time.Minute},
+	// apt-get update and upgrade
+	"system_update": {Stall: 20 * time.Minute, Fail: 60 * time.Minute},
+	"system_deps":   {Stall: 5 * time.Minute, Fail: 20 * time.Minute},
+	// Formatting data volumes
+	"setup_user": {Stall: 10 * time.Minute, Fail: 30 * time.Minute},
+	"disk_setup": {Stall: 10 * time.Minute, Fail: 30 * time.Minute},
+	// Installing, or building, the validator client
+	"solana_install":    {Stall: 30 * time.Minute, Fail: 90 * time.Minute},
+	"config_setup":      {Stall: 5 * time.Minute, Fail: 20 * time.Minute},
+	"identity_setup":    {Stall: 5 * time.Minute, Fail: 20 * time.Minute},
+	"long_term_storage": {Stall: 5 * time.Minute, Fail: 20 * time.Minute},
+	"system_tuning":     {Stall: 5 * time.Minute, Fail: 20 * time.Minute},
+	"service_setup":     {Stall: 10 * time.Minute, Fail: 30 * time.Minute},
+	"monitoring_setup":  {Stall: 10 * time.Minute, Fail: 30 * time.Minute},
+}
+
+// timeoutForDeployStep returns the timeouts of a deploy step
+func timeoutForDeployStep(step string) deployStepTimeout {
+	if timeout, ok := deployStepTimeouts[step]; ok {
+		return timeout
+	}
+	return defaultDeployStepTimeout
+}
+
+// consoleOutputEnabled reports whether stalled EC2 deploys get their console
+// output captured, set with DEPLOY_CONSOLE_OUTPUT=true
+func consoleOutputEnabled() bool {
+	return os.Getenv("DEPLOY_CONSOLE_OUTPUT") == "true"
+}
+
+// StartDeployWatchdog checks deploying nodes for stalled steps in the background
+func StartDeployWatchdog() {
+	go func() {
+		ticker := time.NewTicker(deployWatchdogInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			checkStalledDeploys()
+		}
+	}()
+}
+
+// checkStalledDeploys flags deploys that stopped making progress and fails
+// the ones that stayed stuck past their step's timeout
+func checkStalledDeploys() {
+	progress, err := repository.GetDeployingNodeProgress()
+	if err != nil {
+		log.Printf("Failed to check deploy progress: %v", err)
+		return
+	}
+
+	for nodeID, last := range progress {
+		timeout := timeoutForDeployStep(last.Step)
+		idle := time.Since(last.Timestamp)
+
+		switch {
+		case idle > timeout.Fail:
+			detail := fmt.Sprintf("Deployment timed out at step %q, no progress for %s", last.Step, idle.Round(time.Minute))
+			if err := failStalledDeploy(nodeID, last, detail); err != nil {
+				log.Printf("Failed to fail stalled deploy of node %s: %v", nodeID, err)
+			}
+		case idle > timeout.Stall:
+			if err := flagStalledDeploy(nodeID, last, idle); err != nil {
+				log.Printf("Failed to flag stalled deploy of node %s: %v", nodeID, err)
+			}
+		}
+	}
+}
+
+// flagStalledDeploy marks a deploy as stalled at its last step. The node keeps
+// deploying, so it recovers by itself if the step finishes after all.
+func flagStalledDeploy(nodeID string, last models.NodeDeploymentLog, idle time.Duration) error {
+	node, err := repository.GetNodeByIDInternal(nodeID)
+	if err != nil {
+		return err
+	}
+	if node.Status != "deploying" || node.StalledStep == last.Step {
+		return nil
+	}
+
+	node.StalledStep = last.Step
+	node.StatusDetail = fmt.Sprintf("Deployment stalled at step %q, no progress for %s", last.Step, idle.Round(time.Minute))
+	node.UpdatedAt = time.Now()
+	if err := repository.SaveNode(node); err != nil {
+		return err
+	}
+
+	if consoleOutputEnabled() && node.Provider == providerAWS && node.InstanceID != "" {
+		go captureConsoleOutput(node)
+	}
+
+	return nil
+}
+
+// failStalledDeploy fails a deploy, keeping the step it got stuck on
+func failStalledDeploy(nodeID string, last models.NodeDeploymentLog, detail string) error {
+	node, err := repository.GetNodeByIDInternal(nodeID)
+	if err != nil {
+		return err
+	}
+	if node.Status != "deploying" {
+		return nil
+	}
+
+	node.StalledStep = last.Step
+	node.UpdatedAt = time.Now()
+	if err := repository.SaveNode(node); err != nil {
+		return err
+	}
+
+	return updateNodeWithLog(nodeID, "failed", "timeout", detail, last.Progress)
+}
+
+// captureConsoleOutput adds the tail of an instance's console output to its
+// deployment logs
+func captureConsoleOutput(node models.Node) {
+	ec2Client, err := getNodeEC2Client(node)
+	if err != nil {
+		log.Printf("Failed to get console output of node %s: %v", node.ID, err)
+		return
+	}
+
+	result, err := ec2Client.GetConsoleOutput(&ec2.GetConsoleOutputInput{
+		InstanceId: aws.String(node.InstanceID),
+		Latest:     aws.Bool(true),
+	})
+	if err != nil {
+		log.Printf("Failed to get console output of node %s: %v", node.ID, err)
+		return
+	}
+	if result.Output == nil {
+		return
+	}
+
+	output, err := base64.StdEncoding.DecodeString(*result.Output)
+	if err != nil {
+		log.Printf("Failed to decode console output of node %s: %v", node.ID, err)
+		return
+	}
+
+	message := "Console output:\n" + tailLines(strings.ReplaceAll(string(output), "\r", ""), consoleOutputLines)
+	if err := repository.AddNodeDeploymentLog(node.ID, models.NodeDeploymentLog{
+		Timestamp: time.Now(),
+		Step:      "console_output",
+		Message:   message,
+	}); err != nil {
+		log.Printf("Failed to save console output of node %s: %v", node.ID, err)
+	}
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+const (
+	// dockerAPIVersion is the Engine API version requests are made against
+	dockerAPIVersion = "v1.41"
+	// defaultDockerHost is the daemon used when DOCKER_HOST is not set
+	defaultDockerHost = "unix:///var/run/docker.sock"
+	// dockerRequestTimeout bounds a single Engine API call, image pulls included
+	dockerRequestTimeout = 10 * time.Minute
+)
+
+// dockerClient talks to the Docker Engine REST API
+type dockerClient struct {
+	http    *http.Client
+	baseURL string
+}
+
+// dockerError is an error response from the Engine API
+type dockerError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *dockerError) Error() string {
+	return fmt.Sprintf("docker returned %d: %s", e.StatusCode, e.Message)
+}
+
+// dockerContainer is the part of a container inspect response NodeEase uses
+type dockerContainer struct {
+	ID    string `json:"Id"`
+	State struct {
+		Status   string `json:"Status"`
+		Running  bool   `json:"Running"`
+		ExitCode int    `json:"ExitCode"`
+	} `json:"State"`
+	NetworkSettings struct {
+		Ports map[string][]struct {
+			HostIP   string `json:"HostIp"`
+			HostPort string `json:"HostPort"`
+		} `json:"Ports"`
+	} `json:"NetworkSettings"`
+}
+
+// newDockerClient connects to the daemon named by DOCKER_HOST, or the local
+// daemon's socket
+func newDockerClient() (*dockerClient, error) {
+	host := os.Getenv("DOCKER_HOST")
+	if host == "" {
+		host = defaultDockerHost
+	}
+
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DOCKER_HOST %q: %v", host, err)
+	}
+
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		transport := &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		}
+		return &dockerClient{
+			http:    &http.Client{Transport: transport, Timeout: dockerRequestTimeout},
+			baseURL: "http://docker",
+		}, nil
+	case "tcp", "http":
+		return &dockerClient{
+			http:    &http.Client{Timeout: dockerRequestTimeout},
+			baseURL: "http://" + u.Host,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported DOCKER_HOST scheme %q", u.Scheme)
+	}
+}
+
+// request sends an Engine API request and returns the response for the caller
+// to read and close. Error statuses are returned as a *dockerError.
+func (d *dockerClient) request(method, path string, query url.Values, body io.Reader, contentType string) (*http.Response, error) {
+	endpoint := d.baseURL + "/" + dockerAPIVersion + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(method, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := d.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		return nil, &dockerError{StatusCode: resp.StatusCode, Message: apiErr.Message}
+	}
+
+	return resp, nil
+}
+
+// do sends a JSON request and decodes the response into out, if set
+func (d *dockerClient) do(method, path string, query url.Values, in, out interface{}) error {
+	var body io.Reader
+	contentType := ""
+	if in != nil {
+		encoded, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(encoded)
+		contentType = "application/json"
+	}
+
+	resp, err := d.request(method, path, query, body, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// ping checks the daemon is reachable
+func (d *dockerClient) ping() error {
+	return d.do(http.MethodGet, "/_ping", nil, nil, nil)
+}
+
+// pullImage pulls an image, waiting for the pull to finish
+func (d *dockerClient) pullImage(image string) error {
+	resp, err := d.request(http.MethodPost, "/images/create", url.Values{"fromImage": {image}}, nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Progress is streamed as JSON messages, failures included
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var message struct {
+			Error string `json:"error"`
+		}
+		if err := decoder.Decode(&message); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("failed to read pull progress: %v", err)
+		}
+		if message.Error != "" {
+			return errors.New(message.Error)
+		}
+	}
+}
+
+// inspectContainer returns the state of a container
+func (d *dockerClient) inspectContainer(id string) (dockerContainer, error) {
+	var container dockerContainer
+	err := d.do(http.MethodGet, "/containers/"+url.PathEscape(id)+"/json", nil, nil, &container)
+	return container, err
+}
+
+// isDockerNotFound reports whether an error is the daemon saying an object doesn't exist
+func isDockerNotFound(err error) bool {
+	var apiErr *dockerError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,545 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/0saurabh0/NodeEase/models"
+	"google.golang.org/api/compute/v1"
+)
+
+// gcpImageProject is the project Canonical publishes Ubuntu images to
+const gcpImageProject = "ubuntu-os-cloud"
+
+var (
+	// gcpZonePattern matches a Compute Engine zone, e.g. us-central1-a
+	gcpZonePattern = regexp.MustCompile(`^[a-z]+-[a-z]+[0-9]+-[a-z]$`)
+
+	// gcpMachineTypePattern matches a Compute Engine machine type, e.g. n2-standard-32
+	gcpMachineTypePattern = regexp.MustCompile(`^[a-z][a-z0-9]*-[a-z0-9-]+$`)
+
+	// gcpPollInterval is how often instance state is polled
+	gcpPollInterval = 15 * time.Second
+)
+
+// gcpImageFamilies maps Ubuntu releases and architectures to image families
+var gcpImageFamilies = map[string]map[string]string{
+	"22.04": {"amd64": "ubuntu-2204-lts", "arm64": "ubuntu-2204-lts-arm64"},
+	"24.04": {"amd64": "ubuntu-2404-lts-amd64", "arm64": "ubuntu-2404-lts-arm64"},
+}
+
+// gcpProvider runs nodes on Compute Engine with the user's service account
+var gcpProvider = nodeProvider{
+	Name:         providerGCP,
+	DisplayName:  "GCP",
+	Bootstrapped: true,
+	Validate:     validateGCPRequest,
+	Preflight:    gcpPreflight,
+	Provision:    provisionGCPNode,
+	Start:        startGCPNode,
+	Stop:         stopGCPNode,
+	Reboot:       rebootGCPNode,
+	Delete:       deleteGCPNode,
+}
+
+// gcpNodeName names the instance, firewall and network tag of a node
+func gcpNodeName(nodeID string) string {
+	return fmt.Sprintf("solana-node-%s", nodeID)
+}
+
+// gcpImageFamily returns the Ubuntu image family for a request
+func gcpImageFamily(req models.NodeDeployRequest) (string, error) {
+	osRelease := req.OSRelease
+	if osRelease == "" {
+		osRelease = DefaultOSRelease
+	}
+	architecture := req.Architecture
+	if architecture == "" {
+		architecture = DefaultArchitecture
+	}
+
+	families, ok := gcpImageFamilies[osRelease]
+	if !ok {
+		return "", fmt.Errorf("unsupported OS release %q", osRelease)
+	}
+	family, ok := families[architecture]
+	if !ok {
+		return "", fmt.Errorf("unsupported architecture %q", architecture)
+	}
+	return family, nil
+}
+
+// gcpRegion returns the region a zone is in
+func gcpRegion(zone string) string {
+	return zone[:strings.LastIndex(zone, "-")]
+}
+
+// validateGCPRequest checks the zone and machine type of a GCP deploy request.
+// Region holds the zone and InstanceType the machine type.
+func validateGCPRequest(userID string, req models.NodeDeployRequest) error {
+	if !gcpZonePattern.MatchString(req.Region) {
+		return fmt.Errorf("invalid zone %q", req.Region)
+	}
+	if !gcpMachineTypePattern.MatchString(req.InstanceType) {
+		return fmt.Errorf("invalid machine type %q", req.InstanceType)
+	}
+	if req.DiskSize <= 0 {
+		return fmt.Errorf("disk size is required")
+	}
+	if _, err := gcpImageFamily(req); err != nil {
+		return err
+	}
+
+	return rejectEC2Options(req, "GCP")
+}
+
+// gcpPreflight checks the machine type, image, network and CPU quota a
+// deployment needs before anything is created
+func gcpPreflight(userID string, req models.NodeDeployRequest) (models.PreflightResult, error) {
+	svc, gcpData, err := getGCPCompute(userID)
+	if err != nil {
+		return models.PreflightResult{}, err
+	}
+	project := gcpData.ProjectID
+
+	result := models.PreflightResult{
+		Passed: true,
+		Region: req.Region,
+	}
+
+	add := func(check models.PreflightCheck) {
+		if !check.Passed {
+			result.Passed = false
+		}
+		result.Checks = append(result.Checks, check)
+	}
+
+	machineType, err := svc.MachineTypes.Get(project, req.Region, req.InstanceType).Do()
+	machineCheck := models.PreflightCheck{
+		Name:        "machine_type_offered",
+		Description: fmt.Sprintf("%s is offered in %s", req.InstanceType, req.Region),
+		Passed:      err == nil,
+	}
+	if err != nil {
+		machineCheck.Message = err.Error()
+		machineCheck.Remediation = "Choose a machine type available in this zone, or another zone"
+	}
+	add(machineCheck)
+
+	family, _ := gcpImageFamily(req)
+	image, err := svc.Images.GetFromFamily(gcpImageProject, family).Do()
+	imageCheck := models.PreflightCheck{
+		Name:        "image",
+		Description: "A bootable image exists for the requested OS release and architecture",
+		Passed:      err == nil,
+	}
+	if err != nil {
+		imageCheck.Message = err.Error()
+		imageCheck.Remediation = "Choose a supported OS release/architecture"
+	} else {
+		imageCheck.Message = fmt.Sprintf("Using %s", image.Name)
+	}
+	add(imageCheck)
+
+	_, err = svc.Networks.Get(project, "default").Do()
+	networkCheck := models.PreflightCheck{
+		Name:        "default_network",
+		Description: "The project has a default VPC network",
+		Passed:      err == nil,
+	}
+	if err != nil {
+		networkCheck.Message = err.Error()
+		networkCheck.Remediation = "Create a VPC network named default with auto-mode subnets"
+	}
+	add(networkCheck)
+
+	if machineType != nil {
+		add(checkGCPCPUQuota(svc, project, gcpRegion(req.Region), machineType.GuestCpus))
+	}
+
+	return result, nil
+}
+
+// checkGCPCPUQuota checks the region has room for the machine type's vCPUs
+func checkGCPCPUQuota(svc *compute.Service, project, region string, cpus int64) models.PreflightCheck {
+	check := models.PreflightCheck{
+		Name:        "cpu_quota",
+		Description: fmt.Sprintf("The CPUS quota in %s has room for %d more vCPUs", region, cpus),
+	}
+
+	regionInfo, err := svc.Regions.Get(project, region).Do()
+	if err != nil {
+		check.Message = err.Error()
+		check.Remediation = "Grant the service account compute.regions.get"
+		return check
+	}
+
+	for _, quota := range regionInfo.Quotas {
+		if quota.Metric != "CPUS" {
+			continue
+		}
+		available := int64(quota.Limit - quota.Usage)
+		check.Passed = available >= cpus
+		check.Message = fmt.Sprintf("%d of %d vCPUs available", available, int64(quota.Limit))
+		if !check.Passed {
+			check.Remediation = "Request a CPUS quota increase for this region"
+		}
+		return check
+	}
+
+	// No quota reported for the metric means it isn't limited
+	check.Passed = true
+	return check
+}
+
+// waitGCPOperation waits for a zonal operation, or a global one if zone is empty
+func waitGCPOperation(svc *compute.Service, project, zone string, op *compute.Operation) error {
+	var err error
+	for op.Status != "DONE" {
+		if zone == "" {
+			op, err = svc.GlobalOperations.Wait(project, op.Name).Do()
+		} else {
+			op, err = svc.ZoneOperations.Wait(project, zone, op.Name).Do()
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if op.Error != nil && len(op.Error.Errors) > 0 {
+		return fmt.Errorf("%s", op.Error.Errors[0].Message)
+	}
+	return nil
+}
+
+// createGCPNodeFirewall opens the same ports as createNodeSecurityGroup to
+// instances tagged with the node's name
+func createGCPNodeFirewall(svc *compute.Service, project, nodeID string) error {
+	op, err := svc.Firewalls.Insert(project, &compute.Firewall{
+		Name:         gcpNodeName(nodeID),
+		Description:  "Firewall rules for Solana validator node",
+		Network:      "global/networks/default",
+		Direction:    "INGRESS",
+		SourceRanges: []string{"0.0.0.0/0"},
+		TargetTags:   []string{gcpNodeName(nodeID)},
+		Allowed: []*compute.FirewallAllowed{
+			// SSH, RPC, websocket and the dynamic port range incl. gossip
+			{IPProtocol: "tcp", Ports: []string{"22", "8899", "8900", "8000-8020"}},
+			{IPProtocol: "udp", Ports: []string{"8000-8020"}},
+		},
+	}).Do()
+	if err != nil {
+		// Left by an earlier attempt at deploying the node
+		if isGCPAlreadyExists(err) {
+			return nil
+		}
+		return err
+	}
+
+	return waitGCPOperation(svc, project, "", op)
+}
+
+// gcpExternalIP returns the ephemeral external IP of an instance, if it has one
+func gcpExternalIP(instance *compute.Instance) string {
+	for _, iface := range instance.NetworkInterfaces {
+		for _, access := range iface.AccessConfigs {
+			if access.NatIP != "" {
+				return access.NatIP
+			}
+		}
+	}
+	return ""
+}
+
+// provisionGCPNode creates the firewall and instance for a node. The startup
+// script is passed as cloud-init user-data so it only runs on first boot, as on EC2.
+func provisionGCPNode(node models.Node, req models.NodeDeployRequest, userData, publicKey string) {
+	nodeID := node.ID
+	zone := node.Region
+
+	svc, gcpData, err := getGCPCompute(node.UserID)
+	if err != nil {
+		updateNodeStatus(nodeID, "failed", fmt.Sprintf("Failed to connect to GCP: %v", err))
+		return
+	}
+	project := gcpData.ProjectID
+
+	// Create firewall rules
+	if err := createGCPNodeFirewall(svc, project, nodeID); err != nil {
+		updateNodeStatus(nodeID, "failed", fmt.Sprintf("Failed to create firewall rules: %v", err))
+		return
+	}
+
+	// Launch the instance
+	instance := gcpNodeInstance(nodeID, zone, req, userData, publicKey)
+	op, err := svc.Instances.Insert(project, zone, instance).Do()
+	if err != nil {
+		updateNodeStatus(nodeID, "failed", fmt.Sprintf("Failed to deploy: %v", err))
+		return
+	}
+
+	// Update node with instance name
+	updateNodeInstance(nodeID, instance.Name)
+
+	if err := waitGCPOperation(svc, project, zone, op); err != nil {
+		updateNodeStatus(nodeID, "failed", fmt.Sprintf("Failed to deploy: %v", err))
+		return
+	}
+
+	// Monitor instance until it's running and setup is complete
+	monitorGCPNodeDeployment(nodeID, project, zone, instance.Name, svc)
+}
+
+// gcpNodeInstance describes the instance of a node, tagged for its firewall
+func gcpNodeInstance(nodeID, zone string, req models.NodeDeployRequest, userData, publicKey string) *compute.Instance {
+	family, _ := gcpImageFamily(req)
+	name := gcpNodeName(nodeID)
+	sshKeys := fmt.Sprintf("%s:%s", nodeSSHUser, strings.TrimSpace(publicKey))
+
+	return &compute.Instance{
+		Name:        name,
+		MachineType: fmt.Sprintf("zones/%s/machineTypes/%s", zone, req.InstanceType),
+		Disks: []*compute.AttachedDisk{
+			{
+				Boot:       true,
+				AutoDelete: true,
+				InitializeParams: &compute.AttachedDiskInitializeParams{
+					SourceImage: fmt.Sprintf("projects/%s/global/images/family/%s", gcpImageProject, family),
+					DiskSizeGb:  int64(req.DiskSize),
+					DiskType:    fmt.Sprintf("zones/%s/diskTypes/pd-ssd", zone),
+				},
+			},
+		},
+		NetworkInterfaces: []*compute.NetworkInterface{
+			{
+				Network: "global/networks/default",
+				AccessConfigs: []*compute.AccessConfig{
+					{Name: "External NAT", Type: "ONE_TO_ONE_NAT"},
+				},
+			},
+		},
+		Metadata: &compute.Metadata{
+			Items: []*compute.MetadataItems{
+				{Key: "user-data", Value: &userData},
+				{Key: "ssh-keys", Value: &sshKeys},
+			},
+		},
+		Tags: &compute.Tags{Items: []string{name}},
+		Labels: map[string]string{
+			"nodeease-node-id": nodeID,
+		},
+	}
+}
+
+// monitorGCPNodeDeployment follows a new instance until it has an IP, like
+// monitorNodeDeployment does for EC2
+func monitorGCPNodeDeployment(nodeID, project, zone, name string, svc *compute.Service) {
+	// Create initial log entry
+	updateNodeWithLog(nodeID, "deploying", "provision", "Provisioning Compute Engine instance...", 5)
+
+	followGCPDeployment(svc, project, zone, name, func(update deployStateUpdate) {
+		applyDeployState(nodeID, update)
+	})
+}
+
+// followGCPDeployment polls an instance and reports each state until the
+// deployment is done with the VM
+func followGCPDeployment(svc *compute.Service, project, zone, name string, report func(deployStateUpdate)) {
+	for {
+		// Wait a bit before checking status
+		time.Sleep(gcpPollInterval)
+
+		instance, err := svc.Instances.Get(project, zone, name).Do()
+		if err != nil {
+			if isGCPNotFound(err) {
+				report(deployStateUpdate{Status: "failed", Step: "error", Message: "Instance not found", Done: true})
+			} else {
+				report(deployStateUpdate{Status: "failed", Step: "error", Message: fmt.Sprintf("Failed to get instance status: %v", err), Done: true})
+			}
+			return
+		}
+
+		update := gcpDeploymentState(instance)
+		report(update)
+		if update.Done {
+			return
+		}
+	}
+}
+
+// gcpDeploymentState maps the state of a new instance to a node update
+func gcpDeploymentState(instance *compute.Instance) deployStateUpdate {
+	switch instance.Status {
+	case "RUNNING":
+		// The startup script reports its own progress, record the IP address
+		if ip := gcpExternalIP(instance); ip != "" {
+			return deployStateUpdate{Status: "deploying", Step: "vm_ready", Message: "VM is running, setting up node software...", Progress: 15, IP: ip, Done: true}
+		}
+		return deployStateUpdate{}
+	case "STOPPING", "TERMINATED", "SUSPENDING", "SUSPENDED":
+		return deployStateUpdate{Status: "failed", Step: "terminated", Message: "Instance stopped unexpectedly", Done: true}
+	case "PROVISIONING", "STAGING":
+		return deployStateUpdate{Status: "deploying", Step: "pending", Message: "VM instance is being provisioned", Progress: 10}
+	default:
+		return deployStateUpdate{Status: "deploying", Step: "provisioning", Message: fmt.Sprintf("VM instance state: %s", instance.Status), Progress: 5}
+	}
+}
+
+// monitorGCPInstanceStateChange follows an instance after a start, stop or
+// reset, like monitorInstanceStateChange does for EC2
+func monitorGCPInstanceStateChange(nodeID, project, zone, name string, svc *compute.Service, actionType string) {
+	for {
+		// Wait a bit before checking status
+		time.Sleep(gcpPollInterval)
+
+		instance, err := svc.Instances.Get(project, zone, name).Do()
+		if err != nil {
+			updateNodeWithLog(nodeID, "failed", "error", fmt.Sprintf("Failed to get instance status: %v", err), 0)
+			return
+		}
+
+		switch instance.Status {
+		case "RUNNING":
+			if actionType == "start" || actionType == "reboot" {
+				updateNodeWithLog(nodeID, "running", "running", "Instance is now running", 100)
+
+				// Ephemeral external IPs change when an instance is stopped
+				if ip := gcpExternalIP(instance); ip != "" {
+					updateNodeIP(nodeID, ip)
+					updateNodeRPCEndpoint(nodeID, fmt.Sprintf("http://%s:8899", ip))
+				}
+				return
+			}
+		case "TERMINATED":
+			if actionType == "stop" {
+				updateNodeWithLog(nodeID, "stopped", "stopped", "Instance is now stopped", 0)
+				return
+			}
+		}
+	}
+}
+
+// gcpNodeCompute returns a Compute Engine client and project for a node's instance
+func gcpNodeCompute(node models.Node) (*compute.Service, string, error) {
+	if node.InstanceID == "" {
+		return nil, "", fmt.Errorf("no Compute Engine instance associated with this node")
+	}
+
+	svc, gcpData, err := getGCPCompute(node.UserID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return svc, gcpData.ProjectID, nil
+}
+
+// gcpInstanceAction starts, stops or resets ("reboot") an instance
+func gcpInstanceAction(svc *compute.Service, project, zone, name, action string) error {
+	var err error
+	switch action {
+	case "start":
+		_, err = svc.Instances.Start(project, zone, name).Do()
+	case "stop":
+		_, err = svc.Instances.Stop(project, zone, name).Do()
+	case "reboot":
+		action = "reset"
+		_, err = svc.Instances.Reset(project, zone, name).Do()
+	default:
+		return fmt.Errorf("unsupported instance action %q", action)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to %s instance: %v", action, err)
+	}
+	return nil
+}
+
+// startGCPNode starts a stopped instance
+func startGCPNode(node models.Node) error {
+	svc, project, err := gcpNodeCompute(node)
+	if err != nil {
+		return err
+	}
+
+	if err := gcpInstanceAction(svc, project, node.Region, node.InstanceID, "start"); err != nil {
+		return err
+	}
+
+	// Update node status
+	updateNodeStatus(node.ID, "starting", "Starting the Compute Engine instance...")
+
+	// Start monitoring the instance state
+	go monitorGCPInstanceStateChange(node.ID, project, node.Region, node.InstanceID, svc, "start")
+
+	return nil
+}
+
+// stopGCPNode stops a running instance
+func stopGCPNode(node models.Node) error {
+	svc, project, err := gcpNodeCompute(node)
+	if err != nil {
+		return err
+	}
+
+	if err := gcpInstanceAction(svc, project, node.Region, node.InstanceID, "stop"); err != nil {
+		return err
+	}
+
+	// Update node status
+	updateNodeStatus(node.ID, "stopping", "Stopping the Compute Engine instance...")
+
+	// Start monitoring the instance state
+	go monitorGCPInstanceStateChange(node.ID, project, node.Region, node.InstanceID, svc, "stop")
+
+	return nil
+}
+
+// rebootGCPNode resets a running instance
+func rebootGCPNode(node models.Node) error {
+	svc, project, err := gcpNodeCompute(node)
+	if err != nil {
+		return err
+	}
+
+	if err := gcpInstanceAction(svc, project, node.Region, node.InstanceID, "reboot"); err != nil {
+		return err
+	}
+
+	// Update node status
+	updateNodeStatus(node.ID, "rebooting", "Resetting the Compute Engine instance...")
+
+	// Start monitoring the instance state
+	go monitorGCPInstanceStateChange(node.ID, project, node.Region, node.InstanceID, svc, "reboot")
+
+	return nil
+}
+
+// deleteGCPNode deletes a node's instance and firewall rules
+func deleteGCPNode(node models.Node) error {
+	svc, gcpData, err := getGCPCompute(node.UserID)
+	if err != nil {
+		return err
+	}
+
+	return deleteGCPResources(svc, gcpData.ProjectID, node)
+}
+
+// deleteGCPResources deletes a node's instance and firewall rules, ignoring
+// the ones already gone
+func deleteGCPResources(svc *compute.Service, project string, node models.Node) error {
+	if node.InstanceID != "" {
+		_, err := svc.Instances.Delete(project, node.Region, node.InstanceID).Do()
+		if err != nil && !isGCPNotFound(err) {
+			return fmt.Errorf("failed to delete instance: %v", err)
+		}
+	}
+
+	_, err := svc.Firewalls.Delete(project, gcpNodeName(node.ID)).Do()
+	if err != nil && !isGCPNotFound(err) {
+		return fmt.Errorf("failed to delete firewall rules: %v", err)
+	}
+
+	return nil
+}
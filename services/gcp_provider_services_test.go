@@ -0,0 +1,243 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0saurabh0/NodeEase/models"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+)
+
+// computeStub stands in for the Compute REST API. Responses are keyed by
+// method and path below the project, e.g. "POST global/firewalls". A response
+// is encoded as JSON, an int is answered as an API error with that status, and
+// a slice is handed out one element per request, repeating the last.
+type computeStub struct {
+	mu        sync.Mutex
+	responses map[string]interface{}
+	bodies    map[string]string // Last request body per key
+}
+
+func newComputeStub(t *testing.T, responses map[string]interface{}) (*computeStub, *compute.Service) {
+	t.Helper()
+	stub := &computeStub{responses: responses, bodies: map[string]string{}}
+
+	srv := httptest.NewServer(stub)
+	t.Cleanup(srv.Close)
+
+	svc, err := newComputeService("{}", option.WithEndpoint(srv.URL+"/compute/v1/"), option.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatalf("newComputeService: %v", err)
+	}
+	return stub, svc
+}
+
+func (s *computeStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := r.Method + " " + strings.TrimPrefix(r.URL.Path, "/compute/v1/projects/test-project/")
+	body, _ := io.ReadAll(r.Body)
+	s.bodies[key] = string(body)
+
+	response, ok := s.responses[key]
+	if sequence, isSequence := response.([]interface{}); isSequence {
+		response = sequence[0]
+		if len(sequence) > 1 {
+			s.responses[key] = sequence[1:]
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	code, isCode := response.(int)
+	switch {
+	case !ok:
+		code = http.StatusNotFound
+	case !isCode:
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	w.WriteHeader(code)
+	fmt.Fprintf(w, `{"error": {"code": %d, "message": "%s"}}`, code, http.StatusText(code))
+}
+
+// body returns the last body sent for a key, decoded into v
+func (s *computeStub) body(t *testing.T, key string, v interface{}) {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := json.Unmarshal([]byte(s.bodies[key]), v); err != nil {
+		t.Fatalf("%s body: %v", key, err)
+	}
+}
+
+// pendingOp is an operation that finishes on its first wait
+func pendingOp(name string) *compute.Operation {
+	return &compute.Operation{Name: name, Status: "RUNNING"}
+}
+
+func TestProvisionGCPResources(t *testing.T) {
+	zone := "us-central1-a"
+	stub, svc := newComputeStub(t, map[string]interface{}{
+		"POST global/firewalls":                              pendingOp("firewall-op"),
+		"POST global/operations/firewall-op/wait":            &compute.Operation{Name: "firewall-op", Status: "DONE"},
+		"POST zones/us-central1-a/instances":                 pendingOp("insert-op"),
+		"POST zones/us-central1-a/operations/insert-op/wait": &compute.Operation{Name: "insert-op", Status: "DONE"},
+	})
+
+	if err := createGCPNodeFirewall(svc, "test-project", "node-1"); err != nil {
+		t.Fatalf("createGCPNodeFirewall: %v", err)
+	}
+	var firewall compute.Firewall
+	stub.body(t, "POST global/firewalls", &firewall)
+	if strings.Join(firewall.TargetTags, ",") != "solana-node-node-1" {
+		t.Errorf("target tags = %v, want the node's name", firewall.TargetTags)
+	}
+	if len(firewall.Allowed) != 2 || strings.Join(firewall.Allowed[0].Ports, ",") != "22,8899,8900,8000-8020" {
+		t.Errorf("allowed = %+v", firewall.Allowed)
+	}
+
+	// Left by an earlier attempt at deploying the node
+	stub.mu.Lock()
+	stub.responses["POST global/firewalls"] = http.StatusConflict
+	stub.mu.Unlock()
+	if err := createGCPNodeFirewall(svc, "test-project", "node-1"); err != nil {
+		t.Errorf("existing firewall: %v, want it reused", err)
+	}
+
+	req := models.NodeDeployRequest{InstanceType: "n2-standard-32", DiskSize: 2000}
+	op, err := svc.Instances.Insert("test-project", zone, gcpNodeInstance("node-1", zone, req, "#!/bin/bash\n", "ssh-ed25519 AAAA key\n")).Do()
+	if err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if err := waitGCPOperation(svc, "test-project", zone, op); err != nil {
+		t.Fatalf("waitGCPOperation: %v", err)
+	}
+
+	var instance compute.Instance
+	stub.body(t, "POST zones/us-central1-a/instances", &instance)
+	if instance.Name != "solana-node-node-1" || strings.Join(instance.Tags.Items, ",") != instance.Name {
+		t.Errorf("instance %q is tagged %v, want its own name for the firewall", instance.Name, instance.Tags.Items)
+	}
+	if instance.MachineType != "zones/us-central1-a/machineTypes/n2-standard-32" {
+		t.Errorf("machine type = %q", instance.MachineType)
+	}
+	if disk := instance.Disks[0].InitializeParams; disk.DiskSizeGb != 2000 || !strings.HasSuffix(disk.SourceImage, "/family/ubuntu-2204-lts") {
+		t.Errorf("boot disk = %+v", disk)
+	}
+	metadata := map[string]string{}
+	for _, item := range instance.Metadata.Items {
+		metadata[item.Key] = *item.Value
+	}
+	if metadata["user-data"] != "#!/bin/bash\n" || metadata["ssh-keys"] != "ubuntu:ssh-ed25519 AAAA key" {
+		t.Errorf("metadata = %q", metadata)
+	}
+
+	// An operation that finishes with an error fails the deploy
+	stub.mu.Lock()
+	stub.responses["POST zones/us-central1-a/operations/insert-op/wait"] = &compute.Operation{
+		Name:   "insert-op",
+		Status: "DONE",
+		Error:  &compute.OperationError{Errors: []*compute.OperationErrorErrors{{Message: "Quota 'CPUS' exceeded"}}},
+	}
+	stub.mu.Unlock()
+	if err := waitGCPOperation(svc, "test-project", zone, pendingOp("insert-op")); err == nil || err.Error() != "Quota 'CPUS' exceeded" {
+		t.Errorf("failed operation = %v, want its error", err)
+	}
+}
+
+func TestGCPInstanceActionsAndDelete(t *testing.T) {
+	instance := "zones/us-central1-a/instances/solana-node-node-1"
+	_, svc := newComputeStub(t, map[string]interface{}{
+		"POST " + instance + "/start":                pendingOp("start-op"),
+		"POST " + instance + "/stop":                 pendingOp("stop-op"),
+		"POST " + instance + "/reset":                pendingOp("reset-op"),
+		"DELETE " + instance:                         []interface{}{pendingOp("delete-op"), http.StatusInternalServerError},
+		"DELETE global/firewalls/solana-node-node-1": http.StatusNotFound,
+	})
+
+	for _, action := range []string{"start", "stop", "reboot"} {
+		if err := gcpInstanceAction(svc, "test-project", "us-central1-a", "solana-node-node-1", action); err != nil {
+			t.Errorf("%s: %v", action, err)
+		}
+	}
+	err := gcpInstanceAction(svc, "test-project", "us-central1-a", "solana-node-missing", "reboot")
+	if err == nil || !strings.Contains(err.Error(), "failed to reset instance") {
+		t.Errorf("missing instance = %v, want a reset error", err)
+	}
+
+	// Firewall rules already gone are skipped
+	node := models.Node{ID: "node-1", Region: "us-central1-a", InstanceID: "solana-node-node-1"}
+	if err := deleteGCPResources(svc, "test-project", node); err != nil {
+		t.Errorf("deleteGCPResources: %v", err)
+	}
+	if err := deleteGCPResources(svc, "test-project", node); err == nil || !strings.Contains(err.Error(), "failed to delete instance") {
+		t.Errorf("server error = %v, want a delete error", err)
+	}
+}
+
+func TestFollowGCPDeployment(t *testing.T) {
+	interval := gcpPollInterval
+	gcpPollInterval = time.Millisecond
+	t.Cleanup(func() { gcpPollInterval = interval })
+
+	state := func(status, ip string) *compute.Instance {
+		return &compute.Instance{Status: status, NetworkInterfaces: []*compute.NetworkInterface{
+			{AccessConfigs: []*compute.AccessConfig{{NatIP: ip}}},
+		}}
+	}
+
+	tests := []struct {
+		name   string
+		states interface{}
+		want   []deployStateUpdate
+	}{
+		{
+			name:   "running",
+			states: []interface{}{state("STAGING", ""), state("RUNNING", ""), state("RUNNING", "203.0.113.10")},
+			want: []deployStateUpdate{
+				{Status: "deploying", Step: "pending", Message: "VM instance is being provisioned", Progress: 10},
+				{},
+				{Status: "deploying", Step: "vm_ready", Message: "VM is running, setting up node software...", Progress: 15, IP: "203.0.113.10", Done: true},
+			},
+		},
+		{
+			name:   "terminated",
+			states: []interface{}{state("REPAIRING", ""), state("TERMINATED", "")},
+			want: []deployStateUpdate{
+				{Status: "deploying", Step: "provisioning", Message: "VM instance state: REPAIRING", Progress: 5},
+				{Status: "failed", Step: "terminated", Message: "Instance stopped unexpectedly", Done: true},
+			},
+		},
+		{
+			name:   "deleted",
+			states: http.StatusNotFound,
+			want:   []deployStateUpdate{{Status: "failed", Step: "error", Message: "Instance not found", Done: true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, svc := newComputeStub(t, map[string]interface{}{
+				"GET zones/us-central1-a/instances/solana-node-node-1": tt.states,
+			})
+
+			var got []deployStateUpdate
+			followGCPDeployment(svc, "test-project", "us-central1-a", "solana-node-node-1", func(update deployStateUpdate) {
+				got = append(got, update)
+			})
+
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("updates =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
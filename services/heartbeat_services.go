@@ -0,0 +1,104 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/0saurabh0/NodeEase/db/repository"
+	"github.com/0saurabh0/NodeEase/models"
+)
+
+const (
+	// heartbeatInterval is how often nodes report in, from the reporter timer
+	// the bootstrap installs and from the agent
+	heartbeatInterval = 30 * time.Second
+
+	// defaultMissedHeartbeats is how many intervals a running node may stay
+	// silent before it's marked unresponsive. HEARTBEAT_MISSED_INTERVALS
+	// overrides it.
+	defaultMissedHeartbeats = 3
+)
+
+// missedHeartbeatLimit returns how many missed intervals make a node unresponsive
+func missedHeartbeatLimit() int {
+	if limit, err := strconv.Atoi(os.Getenv("HEARTBEAT_MISSED_INTERVALS")); err == nil && limit > 0 {
+		return limit
+	}
+	return defaultMissedHeartbeats
+}
+
+// RecordNodeHeartbeat stores a heartbeat a node posted to the heartbeat endpoint
+func RecordNodeHeartbeat(nodeID, token string, heartbeat models.NodeHeartbeat) error {
+	// Get node without user ID check since this is coming from the VM
+	node, err := repository.GetNodeByIDInternal(nodeID)
+	if err != nil {
+		return err
+	}
+
+	// Verify token (in production use proper HMAC validation)
+	if node.ID == "" || node.DeployToken != token {
+		return fmt.Errorf("invalid deployment token")
+	}
+
+	return saveNodeHeartbeat(node, heartbeat)
+}
+
+// saveNodeHeartbeat stores the latest heartbeat of a node and brings an
+// unresponsive node back to running
+func saveNodeHeartbeat(node models.Node, heartbeat models.NodeHeartbeat) error {
+	heartbeat.NodeID = node.ID
+	heartbeat.ReceivedAt = time.Now()
+
+	if err := repository.SaveNodeHeartbeat(heartbeat); err != nil {
+		return fmt.Errorf("failed to save heartbeat: %v", err)
+	}
+
+	if node.Status == "unresponsive" {
+		return updateNodeWithLog(node.ID, "running", "heartbeat", "Heartbeats resumed, node is responsive again", 100)
+	}
+
+	return nil
+}
+
+// StartHeartbeatMonitor checks for running nodes that stopped sending
+// heartbeats once per interval, in the background
+func StartHeartbeatMonitor() {
+	go func() {
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			checkMissedHeartbeats()
+		}
+	}()
+}
+
+// checkMissedHeartbeats marks running nodes unresponsive once they have
+// missed too many heartbeats. Nodes that never sent one aren't watched, and a
+// node whose status just changed gets the same grace period.
+func checkMissedHeartbeats() {
+	limit := missedHeartbeatLimit()
+	cutoff := time.Now().Add(-time.Duration(limit) * heartbeatInterval)
+
+	nodeIDs, err := repository.GetNodesWithStaleHeartbeats(cutoff)
+	if err != nil {
+		log.Printf("Failed to check node heartbeats: %v", err)
+		return
+	}
+
+	for _, nodeID := range nodeIDs {
+		// The node may have been stopped or deleted since
+		node, err := repository.GetNodeByIDInternal(nodeID)
+		if err != nil || node.Status != "running" {
+			continue
+		}
+
+		detail := fmt.Sprintf("No heartbeat for %d intervals, node is unresponsive", limit)
+		if err := updateNodeWithLog(nodeID, "unresponsive", "heartbeat", detail, 0); err != nil {
+			log.Printf("Failed to mark node %s unresponsive: %v", nodeID, err)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/0saurabh0/NodeEase/models"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// InstanceStoreConfirmationError is returned when a lifecycle action would
+// wipe a node's instance storage and the request didn't confirm it
+type InstanceStoreConfirmationError struct {
+	Warning string
+}
+
+func (e *InstanceStoreConfirmationError) Error() string {
+	return e.Warning
+}
+
+// validateInstanceStore checks that instance storage placement makes sense for
+// the chosen instance type and doesn't clash with separate EBS volumes
+func validateInstanceStore(userID string, req models.NodeDeployRequest) error {
+	if !req.InstanceStoreAccounts && !req.InstanceStoreLedger {
+		return nil
+	}
+
+	if req.InstanceStoreLedger && !req.InstanceStoreAccounts {
+		return fmt.Errorf("the ledger can only be placed on instance storage together with accounts")
+	}
+
+	storageGB, err := instanceStorageGB(userID, req.Region, req.InstanceType)
+	if err != nil {
+		return err
+	}
+	if storageGB == 0 {
+		return fmt.Errorf("instance type %s has no local NVMe instance storage", req.InstanceType)
+	}
+
+	if req.AccountsVolume != nil {
+		return fmt.Errorf("accounts can't use both instance storage and a separate EBS volume")
+	}
+	if req.InstanceStoreLedger && req.LedgerVolume != nil {
+		return fmt.Errorf("the ledger can't use both instance storage and a separate EBS volume")
+	}
+
+	return nil
+}
+
+// instanceStorageGB returns the instance storage of an instance type as
+// reported by EC2. The catalog is only used when EC2 can't be asked, and a
+// type it doesn't list is an error rather than EBS-only.
+func instanceStorageGB(userID, region, instanceType string) (int64, error) {
+	sess, err := GetAWSSessionForRegion(userID, region)
+	if err == nil {
+		var types *ec2.DescribeInstanceTypesOutput
+		types, err = ec2.New(sess).DescribeInstanceTypes(&ec2.DescribeInstanceTypesInput{
+			InstanceTypes: []*string{aws.String(instanceType)},
+		})
+		if err == nil && len(types.InstanceTypes) > 0 {
+			storage := types.InstanceTypes[0].InstanceStorageInfo
+			if storage == nil {
+				return 0, nil
+			}
+			return aws.Int64Value(storage.TotalSizeInGB), nil
+		}
+	}
+
+	if info, ok := LookupInstanceType(instanceType); ok {
+		return int64(info.LocalNVMeGB), nil
+	}
+	return 0, fmt.Errorf("failed to look up instance storage of %s: %v", instanceType, err)
+}
+
+// usesInstanceStore reports whether any node data is placed on instance storage
+func usesInstanceStore(req models.NodeDeployRequest) bool {
+	return req.InstanceStoreAccounts || req.InstanceStoreLedger
+}
+
+// instanceStoreWarning explains what a lifecycle action means for a node whose
+// data lives on instance storage. It returns an empty string otherwise.
+func instanceStoreWarning(node models.Node, action string) string {
+	if !node.EphemeralStorage {
+		return ""
+	}
+
+	switch action {
+	case "stop":
+		return "This node keeps data on instance storage. Stopping the instance wipes it, and the node will resync from a snapshot when started again."
+	case "reboot":
+		return "This node keeps data on instance storage. A reboot keeps it, but if the instance fails to come back and has to be stopped, the data is wiped and the node will resync from a snapshot."
+	case "start":
+		return "Instance storage was wiped while the node was stopped. The storage array is rebuilt on boot and the node will resync from a snapshot."
+	}
+
+	return ""
+}
+
+// confirmInstanceStoreAction refuses a lifecycle action that puts a node's
+// instance storage at risk unless the request confirmed it with force
+func confirmInstanceStoreAction(node models.Node, action string, force bool) error {
+	if warning := instanceStoreWarning(node, action); warning != "" && !force {
+		return &InstanceStoreConfirmationError{Warning: warning}
+	}
+	return nil
+}
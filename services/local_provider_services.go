@@ -0,0 +1,483 @@
+package services
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/0saurabh0/NodeEase/models"
+)
+
+const (
+	// localRPCPort and localWsPort are the test validator's ports inside the container
+	localRPCPort = "8899/tcp"
+	localWsPort  = "8900/tcp"
+	// localGenesisDir holds the preloaded programs and accounts inside the container
+	localGenesisDir = "/genesis"
+	// defaultLocalImage is the image solana-test-validator runs from, tagged
+	// with the Agave release
+	defaultLocalImage = "anzaxyz/agave"
+	// maxSandboxPreloads bounds the programs and accounts loaded at genesis
+	maxSandboxPreloads = 64
+	// maxSandboxProgramBytes bounds the size of a single preloaded program
+	maxSandboxProgramBytes = 10 << 20
+	// localStartTimeout is how long a test validator gets to answer RPC
+	localStartTimeout = 3 * time.Minute
+	// localStopTimeout is how long Docker waits before killing the container
+	localStopTimeout = "30"
+	// defaultLocalBindIP keeps published sandbox ports on the Docker host's loopback
+	defaultLocalBindIP = "127.0.0.1"
+)
+
+// localProvider runs solana-test-validator in Docker for developer sandboxes
+var localProvider = nodeProvider{
+	Name:        providerLocal,
+	DisplayName: "Local sandbox",
+	Validate:    validateLocalRequest,
+	Preflight:   localPreflight,
+	Prepare:     prepareLocalNode,
+	Provision:   provisionLocalNode,
+	Start:       startLocalNode,
+	Stop:        stopLocalNode,
+	Reboot:      rebootLocalNode,
+	Delete:      deleteLocalNode,
+}
+
+// localValidatorImage returns the image for an Agave release. LOCAL_VALIDATOR_IMAGE
+// replaces the repository, for registries that mirror it.
+func localValidatorImage(version string) string {
+	image := os.Getenv("LOCAL_VALIDATOR_IMAGE")
+	if image == "" {
+		image = defaultLocalImage
+	}
+	return image + ":" + version
+}
+
+// localNodeHost is the address clients use to reach published container ports:
+// LOCAL_NODE_HOST, the host of a TCP DOCKER_HOST, or localhost
+func localNodeHost() string {
+	if host := os.Getenv("LOCAL_NODE_HOST"); host != "" {
+		return host
+	}
+	if u, err := url.Parse(os.Getenv("DOCKER_HOST")); err == nil && (u.Scheme == "tcp" || u.Scheme == "http") {
+		return u.Hostname()
+	}
+	return "localhost"
+}
+
+// localBindIP is the host address sandbox ports are published on. They stay
+// on loopback unless LOCAL_NODE_BIND_IP opens them wider, e.g. 0.0.0.0 for a
+// remote Docker daemon.
+func localBindIP() (string, error) {
+	ip := os.Getenv("LOCAL_NODE_BIND_IP")
+	if ip == "" {
+		return defaultLocalBindIP, nil
+	}
+	if net.ParseIP(ip) == nil {
+		return "", fmt.Errorf("LOCAL_NODE_BIND_IP %q is not an IP address", ip)
+	}
+	return ip, nil
+}
+
+// validateLocalRequest checks the sandbox options of a local deploy request
+func validateLocalRequest(userID string, req models.NodeDeployRequest) error {
+	if os.Getenv("LOCAL_PROVIDER_ENABLED") != "true" {
+		return fmt.Errorf("the local provider is not enabled on this server")
+	}
+	if _, err := localBindIP(); err != nil {
+		return err
+	}
+
+	// solana-test-validator ships with Agave
+	if req.Client != "" && req.Client != "agave" {
+		return fmt.Errorf("local nodes run solana-test-validator from agave, not %s", req.Client)
+	}
+	if _, _, err := resolveClient("agave", req.ClientVersion); err != nil {
+		return err
+	}
+
+	if req.Sandbox == nil {
+		return nil
+	}
+	if len(req.Sandbox.Programs)+len(req.Sandbox.Accounts) > maxSandboxPreloads {
+		return fmt.Errorf("at most %d programs and accounts can be loaded at genesis", maxSandboxPreloads)
+	}
+
+	seen := map[string]bool{}
+	for _, program := range req.Sandbox.Programs {
+		if !pubkeyPattern.MatchString(program.ProgramID) {
+			return fmt.Errorf("program ID %q is not a valid public key", program.ProgramID)
+		}
+		if seen[program.ProgramID] {
+			return fmt.Errorf("%s is loaded more than once", program.ProgramID)
+		}
+		seen[program.ProgramID] = true
+
+		elf, err := base64.StdEncoding.DecodeString(program.ELF)
+		if err != nil {
+			return fmt.Errorf("program %s is not valid base64: %v", program.ProgramID, err)
+		}
+		if len(elf) == 0 || len(elf) > maxSandboxProgramBytes {
+			return fmt.Errorf("program %s must be between 1 byte and %d MB", program.ProgramID, maxSandboxProgramBytes>>20)
+		}
+	}
+
+	for _, account := range req.Sandbox.Accounts {
+		if !pubkeyPattern.MatchString(account.Address) {
+			return fmt.Errorf("account address %q is not a valid public key", account.Address)
+		}
+		if seen[account.Address] {
+			return fmt.Errorf("%s is loaded more than once", account.Address)
+		}
+		seen[account.Address] = true
+
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(account.Account, &fields); err != nil || fields["account"] == nil {
+			return fmt.Errorf("account %s must be the JSON printed by `solana account --output json`", account.Address)
+		}
+	}
+
+	return nil
+}
+
+// localPreflight checks the Docker daemon can be reached
+func localPreflight(userID string, req models.NodeDeployRequest) (models.PreflightResult, error) {
+	check := models.PreflightCheck{
+		Name:        "docker_daemon",
+		Description: "The Docker daemon that runs local nodes is reachable",
+		Passed:      true,
+		Message:     "Docker is reachable",
+	}
+
+	docker, err := newDockerClient()
+	if err == nil {
+		err = docker.ping()
+	}
+	if err != nil {
+		check.Passed = false
+		check.Message = err.Error()
+		check.Remediation = "Start Docker on the NodeEase host or point DOCKER_HOST at a reachable daemon"
+	}
+
+	return models.PreflightResult{
+		Passed: check.Passed,
+		Region: "local",
+		Checks: []models.PreflightCheck{check},
+	}, nil
+}
+
+// prepareLocalNode fills in the node record of a local sandbox
+func prepareLocalNode(node *models.Node, req models.NodeDeployRequest) {
+	_, version, _ := resolveClient("agave", req.ClientVersion)
+
+	node.Region = "local"
+	node.InstanceType = "solana-test-validator"
+	node.NetworkType = "localnet"
+	node.Client = "agave"
+	node.ClientVersion = version
+}
+
+// localValidatorArgs builds the solana-test-validator command line
+func localValidatorArgs(sandbox *models.SandboxSpec) []string {
+	args := []string{
+		"--ledger", "/ledger",
+		"--bind-address", "0.0.0.0",
+		"--rpc-port", strings.TrimSuffix(localRPCPort, "/tcp"),
+		"--log",
+	}
+
+	if sandbox != nil {
+		for _, program := range sandbox.Programs {
+			args = append(args, "--bpf-program", program.ProgramID, path.Join(localGenesisDir, program.ProgramID+".so"))
+		}
+		for _, account := range sandbox.Accounts {
+			args = append(args, "--account", account.Address, path.Join(localGenesisDir, account.Address+".json"))
+		}
+	}
+
+	return args
+}
+
+// sandboxArchive packs the preloaded programs and accounts into a tar archive
+// that unpacks into localGenesisDir
+func sandboxArchive(sandbox *models.SandboxSpec) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
+	archive := tar.NewWriter(&buf)
+	dir := strings.TrimPrefix(localGenesisDir, "/")
+
+	if err := archive.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: dir + "/", Mode: 0755}); err != nil {
+		return nil, err
+	}
+
+	add := func(name string, content []byte) error {
+		if err := archive.WriteHeader(&tar.Header{Name: path.Join(dir, name), Mode: 0644, Size: int64(len(content))}); err != nil {
+			return err
+		}
+		_, err := archive.Write(content)
+		return err
+	}
+
+	for _, program := range sandbox.Programs {
+		elf, err := base64.StdEncoding.DecodeString(program.ELF)
+		if err != nil {
+			return nil, err
+		}
+		if err := add(program.ProgramID+".so", elf); err != nil {
+			return nil, err
+		}
+	}
+	for _, account := range sandbox.Accounts {
+		if err := add(account.Address+".json", account.Account); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// provisionLocalNode pulls the image, creates the container with the genesis
+// files copied in and starts it
+func provisionLocalNode(node models.Node, req models.NodeDeployRequest, userData, publicKey string) {
+	docker, err := newDockerClient()
+	if err != nil {
+		updateNodeStatus(node.ID, "failed", fmt.Sprintf("Failed to connect to Docker: %v", err))
+		return
+	}
+
+	// Pull the image
+	image := localValidatorImage(node.ClientVersion)
+	updateNodeWithLog(node.ID, "deploying", "pull_image", fmt.Sprintf("Pulling %s...", image), 5)
+	if err := docker.pullImage(image); err != nil {
+		updateNodeWithLog(node.ID, "failed", "error", fmt.Sprintf("Failed to pull %s: %v", image, err), 0)
+		return
+	}
+
+	// Create the container, publishing RPC and websocket on ports Docker picks
+	bindIP, err := localBindIP()
+	if err != nil {
+		updateNodeWithLog(node.ID, "failed", "error", err.Error(), 0)
+		return
+	}
+	portBinding := []map[string]string{{"HostIp": bindIP, "HostPort": ""}}
+	var created struct {
+		ID string `json:"Id"`
+	}
+	err = docker.do(http.MethodPost, "/containers/create", url.Values{"name": {"nodeease-" + node.ID}}, map[string]interface{}{
+		"Image":      image,
+		"Entrypoint": []string{"solana-test-validator"},
+		"Cmd":        localValidatorArgs(req.Sandbox),
+		"Labels": map[string]string{
+			"nodeease.node-id": node.ID,
+			"nodeease.user-id": node.UserID,
+		},
+		"ExposedPorts": map[string]struct{}{localRPCPort: {}, localWsPort: {}},
+		"HostConfig": map[string]interface{}{
+			"PortBindings": map[string]interface{}{localRPCPort: portBinding, localWsPort: portBinding},
+		},
+	}, &created)
+	if err != nil {
+		updateNodeWithLog(node.ID, "failed", "error", fmt.Sprintf("Failed to create container: %v", err), 0)
+		return
+	}
+
+	// Update node with container ID
+	updateNodeInstance(node.ID, created.ID)
+
+	// Copy the genesis programs and accounts in before the first start
+	if req.Sandbox != nil && len(req.Sandbox.Programs)+len(req.Sandbox.Accounts) > 0 {
+		updateNodeWithLog(node.ID, "deploying", "genesis", "Copying genesis programs and accounts...", 30)
+
+		archive, err := sandboxArchive(req.Sandbox)
+		if err != nil {
+			updateNodeWithLog(node.ID, "failed", "error", fmt.Sprintf("Failed to pack genesis files: %v", err), 0)
+			return
+		}
+		resp, err := docker.request(http.MethodPut, "/containers/"+created.ID+"/archive", url.Values{"path": {"/"}}, archive, "application/x-tar")
+		if err != nil {
+			updateNodeWithLog(node.ID, "failed", "error", fmt.Sprintf("Failed to copy genesis files: %v", err), 0)
+			return
+		}
+		resp.Body.Close()
+	}
+
+	// Start the test validator
+	updateNodeWithLog(node.ID, "deploying", "start_container", "Starting solana-test-validator...", 50)
+	if err := docker.do(http.MethodPost, "/containers/"+created.ID+"/start", nil, nil, nil); err != nil {
+		updateNodeWithLog(node.ID, "failed", "error", fmt.Sprintf("Failed to start container: %v", err), 0)
+		return
+	}
+
+	monitorLocalNode(node.ID, created.ID, docker)
+}
+
+// monitorLocalNode waits for a started container to answer RPC, then records
+// the host ports Docker published and marks the node running
+func monitorLocalNode(nodeID, containerID string, docker *dockerClient) {
+	deadline := time.Now().Add(localStartTimeout)
+	for time.Now().Before(deadline) {
+		// Wait a bit before checking status
+		time.Sleep(3 * time.Second)
+
+		container, err := docker.inspectContainer(containerID)
+		if err != nil {
+			updateNodeWithLog(nodeID, "failed", "error", fmt.Sprintf("Failed to get container status: %v", err), 0)
+			return
+		}
+
+		if !container.State.Running {
+			if container.State.Status == "exited" || container.State.Status == "dead" {
+				updateNodeWithLog(nodeID, "failed", "exited", fmt.Sprintf("solana-test-validator exited with code %d", container.State.ExitCode), 0)
+				return
+			}
+			continue
+		}
+
+		rpcBindings := container.NetworkSettings.Ports[localRPCPort]
+		wsBindings := container.NetworkSettings.Ports[localWsPort]
+		if len(rpcBindings) == 0 || len(wsBindings) == 0 {
+			continue
+		}
+
+		host := localNodeHost()
+		rpcEndpoint := "http://" + net.JoinHostPort(host, rpcBindings[0].HostPort)
+		if !rpcHealthy(rpcEndpoint) {
+			continue
+		}
+
+		updateNodeIP(nodeID, host)
+		updateNodeRPCEndpoint(nodeID, rpcEndpoint)
+		updateNodeWsEndpoint(nodeID, "ws://"+net.JoinHostPort(host, wsBindings[0].HostPort))
+		updateNodeWithLog(nodeID, "running", "running", "solana-test-validator is running", 100)
+		return
+	}
+
+	updateNodeWithLog(nodeID, "failed", "timeout", "solana-test-validator did not answer RPC in time", 0)
+}
+
+// rpcHealthy reports whether a node's RPC endpoint answers getHealth with ok
+func rpcHealthy(endpoint string) bool {
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Post(endpoint, "application/json",
+		strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"getHealth"}`))
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	var health struct {
+		Result string `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		return false
+	}
+	return health.Result == "ok"
+}
+
+// localNodeContainer returns a Docker client and the container of a local node
+func localNodeContainer(node models.Node) (*dockerClient, string, error) {
+	if node.InstanceID == "" {
+		return nil, "", fmt.Errorf("no container associated with this node")
+	}
+
+	docker, err := newDockerClient()
+	if err != nil {
+		return nil, "", err
+	}
+
+	return docker, node.InstanceID, nil
+}
+
+// startLocalNode starts a stopped test validator container
+func startLocalNode(node models.Node) error {
+	docker, containerID, err := localNodeContainer(node)
+	if err != nil {
+		return err
+	}
+
+	if err := docker.do(http.MethodPost, "/containers/"+containerID+"/start", nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to start container: %v", err)
+	}
+
+	// Update node status
+	updateNodeStatus(node.ID, "starting", "Starting the test validator container...")
+
+	// Docker may publish different host ports, the monitor records them
+	go monitorLocalNode(node.ID, containerID, docker)
+
+	return nil
+}
+
+// stopLocalNode stops a running test validator container. The ledger is kept.
+func stopLocalNode(node models.Node) error {
+	docker, containerID, err := localNodeContainer(node)
+	if err != nil {
+		return err
+	}
+
+	// Update node status
+	updateNodeStatus(node.ID, "stopping", "Stopping the test validator container...")
+
+	go func() {
+		err := docker.do(http.MethodPost, "/containers/"+containerID+"/stop", url.Values{"t": {localStopTimeout}}, nil, nil)
+		if err != nil {
+			updateNodeWithLog(node.ID, "failed", "error", fmt.Sprintf("Failed to stop container: %v", err), 0)
+			return
+		}
+		updateNodeWithLog(node.ID, "stopped", "stopped", "Container is now stopped", 0)
+	}()
+
+	return nil
+}
+
+// rebootLocalNode restarts a test validator container
+func rebootLocalNode(node models.Node) error {
+	docker, containerID, err := localNodeContainer(node)
+	if err != nil {
+		return err
+	}
+
+	// Update node status
+	updateNodeStatus(node.ID, "rebooting", "Restarting the test validator container...")
+
+	go func() {
+		err := docker.do(http.MethodPost, "/containers/"+containerID+"/restart", url.Values{"t": {localStopTimeout}}, nil, nil)
+		if err != nil {
+			updateNodeWithLog(node.ID, "failed", "error", fmt.Sprintf("Failed to restart container: %v", err), 0)
+			return
+		}
+		monitorLocalNode(node.ID, containerID, docker)
+	}()
+
+	return nil
+}
+
+// deleteLocalNode removes a node's container along with its ledger
+func deleteLocalNode(node models.Node) error {
+	if node.InstanceID == "" {
+		return nil
+	}
+
+	docker, err := newDockerClient()
+	if err != nil {
+		return err
+	}
+
+	err = docker.do(http.MethodDelete, "/containers/"+node.InstanceID, url.Values{"force": {"true"}, "v": {"true"}}, nil, nil)
+	if err != nil && !isDockerNotFound(err) {
+		return fmt.Errorf("failed to remove container: %v", err)
+	}
+
+	return nil
+}
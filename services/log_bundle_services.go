@@ -0,0 +1,167 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/0saurabh0/NodeEase/db/repository"
+	"github.com/0saurabh0/NodeEase/models"
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxLogBundleBytes is the largest log bundle a node may upload. The
+	// bootstrap's upload script trims logs to stay well below it.
+	MaxLogBundleBytes = 20 << 20
+
+	// maxLogBundlesPerNode is how many bundles are kept per node, newest first
+	maxLogBundlesPerNode = 10
+
+	// defaultLogBundleRetentionDays is how long bundles are kept.
+	// LOG_BUNDLE_RETENTION_DAYS overrides it.
+	defaultLogBundleRetentionDays = 14
+
+	// logBundleCleanupInterval is how often expired bundles are deleted
+	logBundleCleanupInterval = time.Hour
+
+	// logBundleUploadTimeout bounds collecting and uploading a bundle on demand
+	logBundleUploadTimeout = 5 * time.Minute
+
+	// logBundleUploadScript is installed by the bootstrap script
+	logBundleUploadScript = "/usr/local/bin/nodeease-upload-logs.sh"
+)
+
+// gzipMagic starts every gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// logBundleRetention returns how long log bundles are kept
+func logBundleRetention() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("LOG_BUNDLE_RETENTION_DAYS"))
+	if err != nil || days <= 0 {
+		days = defaultLogBundleRetentionDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// StoreNodeLogBundle stores a log bundle uploaded by a node the caller
+// authenticated with AuthenticateNode, and drops its oldest bundles past the
+// per-node limit
+func StoreNodeLogBundle(nodeID, reason string, data []byte) (models.NodeLogBundle, error) {
+	if reason != models.LogBundleReasonFailure && reason != models.LogBundleReasonOnDemand {
+		return models.NodeLogBundle{}, fmt.Errorf("unsupported log bundle reason %q", reason)
+	}
+	if len(data) > MaxLogBundleBytes {
+		return models.NodeLogBundle{}, fmt.Errorf("log bundle is larger than %d bytes", MaxLogBundleBytes)
+	}
+	if !bytes.HasPrefix(data, gzipMagic) {
+		return models.NodeLogBundle{}, fmt.Errorf("log bundle must be a gzipped tarball")
+	}
+
+	bundle := models.NodeLogBundle{
+		ID:        uuid.New().String(),
+		NodeID:    nodeID,
+		Reason:    reason,
+		SizeBytes: int64(len(data)),
+		CreatedAt: time.Now(),
+	}
+	if err := repository.SaveNodeLogBundle(bundle, data); err != nil {
+		return models.NodeLogBundle{}, fmt.Errorf("failed to save log bundle: %v", err)
+	}
+
+	if err := repository.PruneNodeLogBundles(nodeID, maxLogBundlesPerNode); err != nil {
+		log.Printf("Failed to prune log bundles of node %s: %v", nodeID, err)
+	}
+
+	return bundle, nil
+}
+
+// ListNodeLogBundles lists the log bundles of a node, newest first
+func ListNodeLogBundles(nodeID, userID string) ([]models.NodeLogBundle, error) {
+	node, err := repository.GetNodeByID(nodeID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if node.ID == "" {
+		return nil, fmt.Errorf("node not found or you don't have permission")
+	}
+
+	return repository.GetNodeLogBundles(nodeID)
+}
+
+// GetNodeLogBundle retrieves a log bundle of a node with its archive
+func GetNodeLogBundle(bundleID, nodeID, userID string) (models.NodeLogBundle, []byte, error) {
+	node, err := repository.GetNodeByID(nodeID, userID)
+	if err != nil {
+		return models.NodeLogBundle{}, nil, err
+	}
+	if node.ID == "" {
+		return models.NodeLogBundle{}, nil, fmt.Errorf("node not found or you don't have permission")
+	}
+
+	bundle, data, err := repository.GetNodeLogBundle(bundleID, nodeID)
+	if err != nil {
+		return models.NodeLogBundle{}, nil, err
+	}
+	if bundle.ID == "" {
+		return models.NodeLogBundle{}, nil, fmt.Errorf("log bundle not found")
+	}
+
+	return bundle, data, nil
+}
+
+// RequestNodeLogBundle has a node upload a log bundle now, through its agent
+// or over SSH, and returns the bundle it uploaded
+func RequestNodeLogBundle(nodeID, userID string) (models.NodeLogBundle, error) {
+	node, err := repository.GetNodeByID(nodeID, userID)
+	if err != nil {
+		return models.NodeLogBundle{}, err
+	}
+	if node.ID == "" {
+		return models.NodeLogBundle{}, fmt.Errorf("node not found or you don't have permission")
+	}
+	if err := requireBootstrappedNode(node, "asked for log bundles"); err != nil {
+		return models.NodeLogBundle{}, err
+	}
+
+	requested := time.Now()
+	output, exitCode, err := execNodeScript(node, models.AgentCommandUploadLogs,
+		logBundleUploadScript+" "+models.LogBundleReasonOnDemand+"\n", logBundleUploadTimeout)
+	if err != nil {
+		return models.NodeLogBundle{}, err
+	}
+	if exitCode != 0 {
+		return models.NodeLogBundle{}, fmt.Errorf("log upload exited with code %d:\n%s", exitCode, tailLines(output, 20))
+	}
+
+	// The node posted the bundle to the upload endpoint before the script exited
+	bundles, err := repository.GetNodeLogBundles(nodeID)
+	if err != nil {
+		return models.NodeLogBundle{}, err
+	}
+	if len(bundles) == 0 || bundles[0].CreatedAt.Before(requested) {
+		return models.NodeLogBundle{}, fmt.Errorf("the node didn't upload a log bundle")
+	}
+
+	return bundles[0], nil
+}
+
+// StartLogBundleCleanup deletes log bundles past their retention in the background
+func StartLogBundleCleanup() {
+	go func() {
+		ticker := time.NewTicker(logBundleCleanupInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			deleted, err := repository.DeleteLogBundlesBefore(time.Now().Add(-logBundleRetention()))
+			if err != nil {
+				log.Printf("Failed to delete expired log bundles: %v", err)
+			} else if deleted > 0 {
+				log.Printf("Deleted %d expired log bundles", deleted)
+			}
+		}
+	}()
+}
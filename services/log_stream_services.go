@@ -0,0 +1,274 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0saurabh0/NodeEase/db/repository"
+	"github.com/0saurabh0/NodeEase/models"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// defaultLogStreamLines is the backlog sent before following the journal
+	defaultLogStreamLines = 100
+	maxLogStreamLines     = 1000
+
+	// defaultMaxLogStreams is how many log streams a user may have open at
+	// once. LOG_STREAM_MAX_PER_USER overrides it.
+	defaultMaxLogStreams = 3
+
+	// maxLogStreamDuration closes streams left open, they can be reopened
+	maxLogStreamDuration = time.Hour
+
+	// maxLogStreamGrepLength bounds the grep pattern
+	maxLogStreamGrepLength = 256
+
+	// maxLogStreamLineBytes is the longest journal line passed on
+	maxLogStreamLineBytes = 1024 * 1024
+)
+
+// journalPriorities are the priorities journalctl -p accepts
+var journalPriorities = map[string]bool{
+	"emerg": true, "alert": true, "crit": true, "err": true,
+	"warning": true, "notice": true, "info": true, "debug": true,
+	"0": true, "1": true, "2": true, "3": true, "4": true, "5": true, "6": true, "7": true,
+}
+
+// Open log streams per user
+var (
+	logStreamCounts   = map[string]int{}
+	logStreamCountsMu sync.Mutex
+)
+
+// maxLogStreams returns how many log streams a user may have open at once
+func maxLogStreams() int {
+	if limit, err := strconv.Atoi(os.Getenv("LOG_STREAM_MAX_PER_USER")); err == nil && limit > 0 {
+		return limit
+	}
+	return defaultMaxLogStreams
+}
+
+// acquireLogStream counts a new stream against a user's limit
+func acquireLogStream(userID string) error {
+	logStreamCountsMu.Lock()
+	defer logStreamCountsMu.Unlock()
+
+	limit := maxLogStreams()
+	if logStreamCounts[userID] >= limit {
+		return fmt.Errorf("at most %d log streams can be open at once", limit)
+	}
+	logStreamCounts[userID]++
+	return nil
+}
+
+// releaseLogStream returns a stream to a user's limit
+func releaseLogStream(userID string) {
+	logStreamCountsMu.Lock()
+	defer logStreamCountsMu.Unlock()
+
+	if logStreamCounts[userID]--; logStreamCounts[userID] <= 0 {
+		delete(logStreamCounts, userID)
+	}
+}
+
+// NodeLogStream follows a node's validator journal over an SSH connection
+type NodeLogStream struct {
+	userID  string
+	client  *ssh.Client
+	command string
+	grep    *regexp.Regexp
+
+	closeOnce sync.Once
+}
+
+// OpenNodeLogStream connects to a node to stream its validator journal. The
+// stream counts against the user's limit until it's closed.
+func OpenNodeLogStream(nodeID, userID string, filter models.NodeLogStreamFilter) (*NodeLogStream, error) {
+	node, err := repository.GetNodeByID(nodeID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if node.ID == "" {
+		return nil, fmt.Errorf("node not found or you don't have permission")
+	}
+	if err := requireBootstrappedNode(node, "streamed logs from"); err != nil {
+		return nil, err
+	}
+
+	command, grep, err := buildJournalCommand(filter, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := acquireLogStream(userID); err != nil {
+		return nil, err
+	}
+
+	client, err := dialNode(node)
+	if err != nil {
+		releaseLogStream(userID)
+		return nil, err
+	}
+
+	return &NodeLogStream{
+		userID:  userID,
+		client:  client,
+		command: command,
+		grep:    grep,
+	}, nil
+}
+
+// Serve sends journal lines to a WebSocket until the client disconnects, the
+// journal ends or the stream reaches its maximum duration
+func (s *NodeLogStream) Serve(ws *websocket.Conn) {
+	defer s.Close()
+
+	// Clients only ever close the stream, anything they send is ignored
+	go func() {
+		var discard []byte
+		for websocket.Message.Receive(ws, &discard) == nil {
+		}
+		s.Close()
+	}()
+
+	timer := time.AfterFunc(maxLogStreamDuration, s.Close)
+	defer timer.Stop()
+
+	err := streamJournal(s.client, s.command, s.grep, func(line string) error {
+		ws.SetWriteDeadline(time.Now().Add(agentWriteTimeout))
+		return websocket.JSON.Send(ws, models.NodeLogStreamMessage{Type: models.LogStreamLine, Line: line})
+	})
+
+	// The client may be gone already
+	message := models.NodeLogStreamMessage{Type: models.LogStreamEnd}
+	if err != nil {
+		message = models.NodeLogStreamMessage{Type: models.LogStreamError, Error: err.Error()}
+	}
+	ws.SetWriteDeadline(time.Now().Add(agentWriteTimeout))
+	websocket.JSON.Send(ws, message)
+}
+
+// Close ends the stream and its SSH connection
+func (s *NodeLogStream) Close() {
+	s.closeOnce.Do(func() {
+		s.client.Close()
+		releaseLogStream(s.userID)
+	})
+}
+
+// buildJournalCommand returns the journalctl command a filter asks for and
+// the pattern lines are matched against, if any. Relative since values are
+// resolved against now.
+func buildJournalCommand(filter models.NodeLogStreamFilter, now time.Time) (string, *regexp.Regexp, error) {
+	args := []string{"sudo", "journalctl", "-u", "solana-validator", "--no-pager", "-o", "short-iso", "-f"}
+
+	if filter.Priority != "" {
+		if !journalPriorities[filter.Priority] {
+			return "", nil, fmt.Errorf("priority must be a journal priority name or 0-7")
+		}
+		args = append(args, "-p", filter.Priority)
+	}
+
+	if filter.Since != "" {
+		since, err := parseLogStreamSince(filter.Since, now)
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, "--since", fmt.Sprintf("@%d", since.Unix()))
+	}
+
+	// Everything since the requested time unless a backlog size was given
+	lines := filter.Lines
+	if lines < 0 || lines > maxLogStreamLines {
+		return "", nil, fmt.Errorf("lines must be between 0 and %d", maxLogStreamLines)
+	}
+	if lines == 0 && filter.Since == "" {
+		lines = defaultLogStreamLines
+	}
+	if lines > 0 {
+		args = append(args, "-n", strconv.Itoa(lines))
+	}
+
+	// Matching is done here rather than with journalctl --grep, which needs
+	// a journalctl built with PCRE2
+	var grep *regexp.Regexp
+	if filter.Grep != "" {
+		if len(filter.Grep) > maxLogStreamGrepLength {
+			return "", nil, fmt.Errorf("grep pattern must be at most %d characters", maxLogStreamGrepLength)
+		}
+		var err error
+		if grep, err = regexp.Compile(filter.Grep); err != nil {
+			return "", nil, fmt.Errorf("invalid grep pattern: %v", err)
+		}
+	}
+
+	for i, arg := range args {
+		args[i] = shellQuote(arg)
+	}
+	return strings.Join(args, " "), grep, nil
+}
+
+// parseLogStreamSince reads a since filter, a duration back from now or an RFC 3339 time
+func parseLogStreamSince(since string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(since); err == nil && d > 0 {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, since); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("since must be a duration like 15m or an RFC 3339 time")
+}
+
+// streamJournal runs a journalctl command on an SSH connection and passes
+// each line matching grep to send until the command ends or send fails
+func streamJournal(client *ssh.Client, command string, grep *regexp.Regexp, send func(line string) error) error {
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to open SSH session: %v", err)
+	}
+	defer session.Close()
+
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to read journal: %v", err)
+	}
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+
+	if err := session.Start(command); err != nil {
+		return fmt.Errorf("failed to start journalctl: %v", err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxLogStreamLineBytes)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if grep != nil && !grep.MatchString(line) {
+			continue
+		}
+		if err := send(line); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read journal: %v", err)
+	}
+
+	if err := session.Wait(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return fmt.Errorf("journalctl failed: %s", message)
+		}
+		return fmt.Errorf("journalctl failed: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0saurabh0/NodeEase/models"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/net/websocket"
+)
+
+// startJournalServer runs an SSH server on loopback that answers every exec
+// request with the given output and exit status. It returns a client
+// connected to it and the commands it is asked to run.
+func startJournalServer(t *testing.T, stdout, stderr string, status uint32) (*ssh.Client, <-chan string) {
+	t.Helper()
+
+	_, hostKey, _ := ed25519.GenerateKey(rand.Reader)
+	signer, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	commands := make(chan string, 10)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		_, channels, requests, err := ssh.NewServerConn(conn, config)
+		if err != nil {
+			return
+		}
+		go ssh.DiscardRequests(requests)
+
+		for newChannel := range channels {
+			channel, requests, err := newChannel.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer channel.Close()
+				for req := range requests {
+					var exec struct{ Command string }
+					if req.Type != "exec" || ssh.Unmarshal(req.Payload, &exec) != nil {
+						req.Reply(false, nil)
+						continue
+					}
+					req.Reply(true, nil)
+					commands <- exec.Command
+
+					io.WriteString(channel, stdout)
+					io.WriteString(channel.Stderr(), stderr)
+					channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+					return
+				}
+			}()
+		}
+	}()
+
+	client, err := ssh.Dial("tcp", listener.Addr().String(), &ssh.ClientConfig{
+		User:            nodeSSHUser,
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("dial journal server: %v", err)
+	}
+	return client, commands
+}
+
+func TestBuildJournalCommand(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	base := "'sudo' 'journalctl' '-u' 'solana-validator' '--no-pager' '-o' 'short-iso' '-f'"
+
+	tests := []struct {
+		filter  models.NodeLogStreamFilter
+		want    string
+		wantErr string
+	}{
+		{filter: models.NodeLogStreamFilter{}, want: base + " '-n' '100'"},
+		{
+			filter: models.NodeLogStreamFilter{Priority: "err", Since: "15m"},
+			want:   base + fmt.Sprintf(" '-p' 'err' '--since' '@%d'", now.Add(-15*time.Minute).Unix()),
+		},
+		{
+			filter: models.NodeLogStreamFilter{Since: "2025-01-01T11:00:00Z", Lines: 50},
+			want:   base + fmt.Sprintf(" '--since' '@%d' '-n' '50'", now.Add(-time.Hour).Unix()),
+		},
+		// Matched on the server, so never part of the command
+		{filter: models.NodeLogStreamFilter{Grep: `it's '; reboot`}, want: base + " '-n' '100'"},
+		{filter: models.NodeLogStreamFilter{Priority: "err'; reboot; '"}, wantErr: "priority must be"},
+		{filter: models.NodeLogStreamFilter{Lines: maxLogStreamLines + 1}, wantErr: "lines must be"},
+		{filter: models.NodeLogStreamFilter{Grep: "("}, wantErr: "invalid grep pattern"},
+	}
+
+	for _, tt := range tests {
+		command, _, err := buildJournalCommand(tt.filter, now)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%+v: err = %v, want %q", tt.filter, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || command != tt.want {
+			t.Errorf("%+v: command = %s (%v), want %s", tt.filter, command, err, tt.want)
+		}
+	}
+}
+
+func TestNodeLogStream(t *testing.T) {
+	journal := "2025-01-01T00:00:00+0000 node agave-validator[1]: new root slot 100\n" +
+		"2025-01-01T00:00:01+0000 node agave-validator[1]: gossip: 12 peers\n" +
+		"2025-01-01T00:00:02+0000 node agave-validator[1]: new root slot 101\n"
+
+	tests := []struct {
+		name   string
+		stderr string
+		status uint32
+		want   []models.NodeLogStreamMessage
+	}{
+		{
+			name: "journal ends",
+			want: []models.NodeLogStreamMessage{
+				{Type: models.LogStreamLine, Line: "2025-01-01T00:00:00+0000 node agave-validator[1]: new root slot 100"},
+				{Type: models.LogStreamLine, Line: "2025-01-01T00:00:02+0000 node agave-validator[1]: new root slot 101"},
+				{Type: models.LogStreamEnd},
+			},
+		},
+		{
+			name:   "non-zero exit",
+			stderr: "Unit solana-validator.service could not be found.\n",
+			status: 1,
+			want: []models.NodeLogStreamMessage{
+				{Type: models.LogStreamLine, Line: "2025-01-01T00:00:00+0000 node agave-validator[1]: new root slot 100"},
+				{Type: models.LogStreamLine, Line: "2025-01-01T00:00:02+0000 node agave-validator[1]: new root slot 101"},
+				{Type: models.LogStreamError, Error: "journalctl failed: Unit solana-validator.service could not be found."},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, commands := startJournalServer(t, journal, tt.stderr, tt.status)
+			command, grep, err := buildJournalCommand(models.NodeLogStreamFilter{Grep: `root slot \d+`}, time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := acquireLogStream("user-1"); err != nil {
+				t.Fatal(err)
+			}
+			stream := &NodeLogStream{userID: "user-1", client: client, command: command, grep: grep}
+
+			srv := httptest.NewServer(websocket.Handler(stream.Serve))
+			defer srv.Close()
+			ws, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), "", "http://localhost/")
+			if err != nil {
+				t.Fatalf("dial log stream: %v", err)
+			}
+			defer ws.Close()
+
+			var got []models.NodeLogStreamMessage
+			for len(got) == 0 || got[len(got)-1].Type == models.LogStreamLine {
+				var frame models.NodeLogStreamMessage
+				ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+				if err := websocket.JSON.Receive(ws, &frame); err != nil {
+					t.Fatalf("receive after %+v: %v", got, err)
+				}
+				got = append(got, frame)
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("frames =\n%+v\nwant\n%+v", got, tt.want)
+			}
+			if ran := <-commands; ran != command {
+				t.Errorf("node ran %s, want %s", ran, command)
+			}
+
+			// Ending the stream returns it to the user's limit
+			logStreamCountsMu.Lock()
+			open := logStreamCounts["user-1"]
+			logStreamCountsMu.Unlock()
+			if open != 0 {
+				t.Errorf("%d streams still counted", open)
+			}
+		})
+	}
+}
+
+func TestLogStreamLimit(t *testing.T) {
+	t.Setenv("LOG_STREAM_MAX_PER_USER", "2")
+
+	for i := 0; i < 2; i++ {
+		if err := acquireLogStream("user-1"); err != nil {
+			t.Fatalf("stream %d: %v", i+1, err)
+		}
+	}
+	if err := acquireLogStream("user-1"); err == nil || !strings.Contains(err.Error(), "at most 2 log streams") {
+		t.Errorf("third stream = %v, want the limit", err)
+	}
+	if err := acquireLogStream("user-2"); err != nil {
+		t.Errorf("other user: %v", err)
+	}
+
+	// Closing a stream twice releases it once
+	client, _ := startJournalServer(t, "", "", 0)
+	stream := &NodeLogStream{userID: "user-1", client: client}
+	stream.Close()
+	stream.Close()
+	if err := acquireLogStream("user-1"); err != nil {
+		t.Errorf("after close: %v", err)
+	}
+	if err := acquireLogStream("user-1"); err == nil {
+		t.Error("double close released two streams")
+	}
+
+	releaseLogStream("user-1")
+	releaseLogStream("user-1")
+	releaseLogStream("user-2")
+	logStreamCountsMu.Lock()
+	defer logStreamCountsMu.Unlock()
+	if len(logStreamCounts) != 0 {
+		t.Errorf("counts left after releasing every stream: %v", logStreamCounts)
+	}
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/0saurabh0/NodeEase/db/repository"
+	"github.com/0saurabh0/NodeEase/utils"
+)
+
+// Errors a node can get when calling back or fetching a secret, besides
+// lookup failures
+var (
+	ErrInvalidDeployToken    = errors.New("invalid deployment token")
+	ErrNodeSecretUnavailable = errors.New("secrets are only available while the node is deploying")
+	ErrNodeSecretNotFound    = errors.New("secret not found")
+)
+
+// saveNodeSecret encrypts and stores a secret the node fetches while bootstrapping
+func saveNodeSecret(nodeID, name, value string) error {
+	encrypted, err := utils.Encrypt(value)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt node secret: %v", err)
+	}
+
+	if err := repository.SaveNodeSecret(nodeID, name, encrypted); err != nil {
+		return fmt.Errorf("failed to save node secret: %v", err)
+	}
+
+	return nil
+}
+
+// GetNodeSecretForNode returns a secret to the node it belongs to. Secrets are
+// only handed out while the node is deploying.
+func GetNodeSecretForNode(nodeID, token, name string) (string, error) {
+	// Get node without user ID check since this is coming from the VM
+	node, err := repository.GetNodeByIDInternal(nodeID)
+	if err != nil {
+		return "", err
+	}
+
+	// Verify token (in production use proper HMAC validation)
+	if node.ID == "" || node.DeployToken != token {
+		return "", ErrInvalidDeployToken
+	}
+	if node.Status != "deploying" {
+		return "", ErrNodeSecretUnavailable
+	}
+
+	encrypted, err := repository.GetNodeSecret(nodeID, name)
+	if err != nil {
+		return "", err
+	}
+	if encrypted == "" {
+		return "", fmt.Errorf("%w: %q", ErrNodeSecretNotFound, name)
+	}
+
+	return decrypt(encrypted)
+}
@@ -7,7 +7,8 @@ import (
 	"encoding/base64"
 	"encoding/pem"
 	"fmt"
-	"strings"
+	"sort"
+	"sync"
 	"time"
 
 	"golang.org/x/crypto/ssh"
@@ -15,10 +16,50 @@ import (
 	"github.com/0saurabh0/NodeEase/db/repository"
 	"github.com/0saurabh0/NodeEase/models"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/google/uuid"
 )
 
+// maxStatusBatchSize bounds how many status updates a node may send at once
+const maxStatusBatchSize = 500
+
+// nodeStatusLock serializes the status changes of one node so sequence
+// checks don't race. It is dropped once nobody holds or waits for it.
+type nodeStatusLock struct {
+	mu    sync.Mutex
+	users int
+}
+
+var (
+	nodeStatusLocks   = map[string]*nodeStatusLock{}
+	nodeStatusLocksMu sync.Mutex
+)
+
+// lockNodeStatus locks the status of a node and returns the unlock function
+func lockNodeStatus(nodeID string) func() {
+	nodeStatusLocksMu.Lock()
+	lock := nodeStatusLocks[nodeID]
+	if lock == nil {
+		lock = &nodeStatusLock{}
+		nodeStatusLocks[nodeID] = lock
+	}
+	lock.users++
+	nodeStatusLocksMu.Unlock()
+
+	lock.mu.Lock()
+	return func() {
+		lock.mu.Unlock()
+
+		nodeStatusLocksMu.Lock()
+		lock.users--
+		if lock.users == 0 {
+			delete(nodeStatusLocks, nodeID)
+		}
+		nodeStatusLocksMu.Unlock()
+	}
+}
+
 // createNodeSecurityGroup creates a security group for Solana nodes
 func createNodeSecurityGroup(ec2Client *ec2.EC2, nodeID string, vpcID string) (string, error) {
 	// Create security group
@@ -176,12 +217,170 @@ func createNodeSecurityGroup(ec2Client *ec2.EC2, nodeID string, vpcID string) (s
 
 // DeployNode deploys a new Solana node
 func DeployNode(userID string, req models.NodeDeployRequest) (string, error) {
-	// Get AWS session
-	sess, err := GetAWSSession(userID)
+	// Nodes on other providers are provisioned through the provider catalog
+	providerName, err := resolveProvider(req.Provider)
+	if err != nil {
+		return "", err
+	}
+	if providerName != providerAWS {
+		return deployProviderNode(userID, nodeProviders[providerName], req)
+	}
+
+	// Get AWS session scoped to the requested region so the instance and the
+	// AMI both come from the region recorded on the node
+	sess, err := GetAWSSessionForRegion(userID, req.Region)
 	if err != nil {
 		return "", fmt.Errorf("failed to get AWS session: %v", err)
 	}
 
+	// Resolve the image up front so a missing AMI is reported to the caller
+	// instead of failing inside the provisioning goroutine
+	imageID, err := resolveSolanaAMI(sess, req.CustomAMI, req.OSRelease, req.Architecture)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve AMI: %v", err)
+	}
+
+	node, rendered, publicKey, err := saveDeployingNode(userID, providerAWS, true, req, func(node *models.Node, req models.NodeDeployRequest) {
+		node.EphemeralStorage = usesInstanceStore(req)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// Start EC2 instance provisioning in a separate goroutine
+	go provisionEC2Node(node, ec2.New(sess), req, imageID, rendered.UserData, publicKey)
+
+	return node.ID, nil
+}
+
+// provisionEC2Node creates the key pair, security group and instance of a
+// saved node. A key pair or security group left by an earlier attempt at
+// deploying the node is reused.
+func provisionEC2Node(node models.Node, ec2Client *ec2.EC2, req models.NodeDeployRequest, imageID, userData, publicKey string) {
+	nodeID := node.ID
+
+	// Create key pair in AWS
+	keyName := fmt.Sprintf("nodeease-key-%s", nodeID[:8])
+	_, err := ec2Client.ImportKeyPair(&ec2.ImportKeyPairInput{
+		KeyName:           aws.String(keyName),
+		PublicKeyMaterial: []byte(publicKey),
+	})
+
+	if err != nil && !isAWSErrorCode(err, "InvalidKeyPair.Duplicate") {
+		updateNodeStatus(nodeID, "failed", fmt.Sprintf("Failed to import key pair: %v", err))
+		return
+	}
+
+	// Get default VPC
+	describeVpcsOutput, err := ec2Client.DescribeVpcs(&ec2.DescribeVpcsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("isDefault"),
+				Values: []*string{aws.String("true")},
+			},
+		},
+	})
+	if err != nil || len(describeVpcsOutput.Vpcs) == 0 {
+		updateNodeStatus(nodeID, "failed", fmt.Sprintf("Failed to get default VPC: %v", err))
+		return
+	}
+
+	vpcID := *describeVpcsOutput.Vpcs[0].VpcId
+
+	// Reuse the node's security group, or create it
+	sgID, err := findNodeSecurityGroup(ec2Client, nodeID, vpcID)
+	if err == nil && sgID == "" {
+		sgID, err = createNodeSecurityGroup(ec2Client, nodeID, vpcID)
+	}
+	if err != nil {
+		updateNodeStatus(nodeID, "failed", fmt.Sprintf("Failed to create security group: %v", err))
+		return
+	}
+
+	// Define EC2 instance parameters
+	runParams := &ec2.RunInstancesInput{
+		ImageId:             aws.String(imageID),
+		InstanceType:        aws.String(req.InstanceType),
+		MinCount:            aws.Int64(1),
+		MaxCount:            aws.Int64(1),
+		UserData:            aws.String(base64.StdEncoding.EncodeToString([]byte(userData))),
+		BlockDeviceMappings: buildBlockDeviceMappings(req),
+		TagSpecifications: []*ec2.TagSpecification{
+			{
+				ResourceType: aws.String("instance"),
+				Tags: []*ec2.Tag{
+					{
+						Key:   aws.String("Name"),
+						Value: aws.String(req.NodeName),
+					},
+					{
+						Key:   aws.String("NodeID"),
+						Value: aws.String(nodeID),
+					},
+					{
+						Key:   aws.String("UserID"),
+						Value: aws.String(node.UserID),
+					},
+				},
+			},
+		},
+		KeyName:          aws.String(keyName), // Add SSH key name here
+		SecurityGroupIds: []*string{aws.String(sgID)},
+	}
+
+	// Launch EC2 instance
+	runResult, err := ec2Client.RunInstances(runParams)
+
+	if err != nil {
+		updateNodeStatus(nodeID, "failed", fmt.Sprintf("Failed to deploy: %v", err))
+		return
+	}
+
+	// Get instance ID
+	instanceID := *runResult.Instances[0].InstanceId
+
+	// Update node with instance ID
+	updateNodeInstance(nodeID, instanceID)
+
+	// Monitor instance until it's running and setup is complete
+	go monitorNodeDeployment(nodeID, instanceID, ec2Client)
+}
+
+// findNodeSecurityGroup returns the ID of the security group created for a
+// node, or an empty string if there is none
+func findNodeSecurityGroup(ec2Client *ec2.EC2, nodeID, vpcID string) (string, error) {
+	output, err := ec2Client.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("group-name"),
+				Values: []*string{aws.String(fmt.Sprintf("solana-node-%s", nodeID))},
+			},
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []*string{aws.String(vpcID)},
+			},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(output.SecurityGroups) == 0 {
+		return "", nil
+	}
+	return aws.StringValue(output.SecurityGroups[0].GroupId), nil
+}
+
+// isAWSErrorCode reports whether an AWS API error has the given code
+func isAWSErrorCode(err error, code string) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == code
+}
+
+// saveDeployingNode creates the record for a node about to be provisioned.
+// Bootstrapped nodes also get an SSH key pair, their rendered startup script
+// and a first config revision; the public key is returned with the scripts.
+func saveDeployingNode(userID, provider string, bootstrapped bool, req models.NodeDeployRequest, prepare func(node *models.Node, req models.NodeDeployRequest)) (models.Node, models.RenderedNodeScripts, string, error) {
 	// Generate node ID
 	nodeID := uuid.New().String()
 	now := time.Now()
@@ -189,18 +388,11 @@ func DeployNode(userID string, req models.NodeDeployRequest) (string, error) {
 	// Generate deployment token
 	deployToken := generateDeploymentToken(nodeID)
 
-	// Generate SSH key pair
-	privateKey, publicKey, err := generateSSHKeyPair()
-	if err != nil {
-		return "", fmt.Errorf("failed to generate SSH key: %v", err)
-	}
-
-	// Create node record in database
 	node := models.Node{
 		ID:            nodeID,
 		UserID:        userID,
 		Name:          req.NodeName,
-		Provider:      "AWS",
+		Provider:      provider,
 		Region:        req.Region,
 		InstanceType:  req.InstanceType,
 		NodeType:      req.RpcType,
@@ -208,7 +400,7 @@ func DeployNode(userID string, req models.NodeDeployRequest) (string, error) {
 		Status:        "deploying",
 		DiskSize:      req.DiskSize,
 		DeployToken:   deployToken,
-		SshPrivateKey: privateKey,
+		DeployAttempt: 1,
 		CreatedAt:     now,
 		UpdatedAt:     now,
 		DeploymentLogs: []models.NodeDeploymentLog{
@@ -221,111 +413,125 @@ func DeployNode(userID string, req models.NodeDeployRequest) (string, error) {
 		},
 	}
 
-	if err := repository.SaveNode(node); err != nil {
-		return "", fmt.Errorf("failed to save node record: %v", err)
-	}
+	var rendered models.RenderedNodeScripts
+	var publicKey string
+	if bootstrapped {
+		// Generate SSH key pair
+		privateKey, pub, err := generateSSHKeyPair()
+		if err != nil {
+			return models.Node{}, rendered, "", fmt.Errorf("failed to generate SSH key: %v", err)
+		}
+		publicKey = pub
 
-	// Start EC2 instance provisioning in a separate goroutine
-	go func() {
-		ec2Client := ec2.New(sess)
-
-		// Create key pair in AWS
-		keyName := fmt.Sprintf("nodeease-key-%s", nodeID[:8])
-		_, err := ec2Client.ImportKeyPair(&ec2.ImportKeyPairInput{
-			KeyName:           aws.String(keyName),
-			PublicKeyMaterial: []byte(publicKey),
-		})
+		// Look up the cluster's entrypoints and known validators
+		cluster, err := resolveCluster(userID, req.NetworkType)
+		if err != nil {
+			return models.Node{}, rendered, "", err
+		}
 
+		// Render the startup script now so template problems are reported to the caller
+		rendered, err = RenderNodeScripts(buildNodeConfig(req, cluster, nodeID, deployToken))
 		if err != nil {
-			updateNodeStatus(nodeID, "failed", fmt.Sprintf("Failed to import key pair: %v", err))
-			return
+			return models.Node{}, rendered, "", err
 		}
 
-		// Get default VPC
-		describeVpcsOutput, err := ec2Client.DescribeVpcs(&ec2.DescribeVpcsInput{
-			Filters: []*ec2.Filter{
-				{
-					Name:   aws.String("isDefault"),
-					Values: []*string{aws.String("true")},
-				},
-			},
-		})
-		if err != nil || len(describeVpcsOutput.Vpcs) == 0 {
-			updateNodeStatus(nodeID, "failed", fmt.Sprintf("Failed to get default VPC: %v", err))
-			return
+		node.SshPrivateKey = privateKey
+		node.Client = rendered.Config.Client
+		node.ClientVersion = rendered.Config.ClientVersion
+		node.HistoryLength = rendered.Config.HistoryLength
+	}
+
+	if prepare != nil {
+		prepare(&node, req)
+	}
+
+	// Create node record in database
+	if err := repository.SaveNode(node); err != nil {
+		return models.Node{}, rendered, "", fmt.Errorf("failed to save node record: %v", err)
+	}
+
+	if !bootstrapped {
+		return node, rendered, "", nil
+	}
+
+	// Hand the long-term storage credentials to the node as a secret
+	if req.LongTermStorage != nil {
+		if err := saveNodeSecret(nodeID, longTermStorageSecret, req.LongTermStorage.Credentials); err != nil {
+			updateNodeStatus(nodeID, "failed", "Failed to store node secrets")
+			return models.Node{}, rendered, "", err
 		}
+	}
 
-		vpcID := *describeVpcsOutput.Vpcs[0].VpcId
+	// Record what the node is deployed with as its first config revision
+	recordedRequest := redactDeployRequest(req)
+	_, err := repository.SaveNodeConfigRevision(models.NodeConfigRevision{
+		ID:            uuid.New().String(),
+		NodeID:        nodeID,
+		UserID:        userID,
+		Config:        rendered.Config,
+		SystemdUnit:   rendered.SystemdUnit,
+		ClientConfig:  rendered.ClientConfig,
+		ClientVersion: rendered.Config.ClientVersion,
+		Reason:        "deploy",
+		Status:        "applied",
+		DeployRequest: &recordedRequest,
+		CreatedAt:     now,
+	})
+	if err != nil {
+		updateNodeStatus(nodeID, "failed", "Failed to record node configuration")
+		return models.Node{}, rendered, "", fmt.Errorf("failed to save config revision: %v", err)
+	}
 
-		// Create security group
-		sgID, err := createNodeSecurityGroup(ec2Client, nodeID, vpcID)
-		if err != nil {
-			updateNodeStatus(nodeID, "failed", fmt.Sprintf("Failed to create security group: %v", err))
-			return
+	return node, rendered, publicKey, nil
+}
+
+// ValidateDeployRequest checks a deploy request before any resources are created
+func ValidateDeployRequest(userID string, req models.NodeDeployRequest) error {
+	providerName, err := resolveProvider(req.Provider)
+	if err != nil {
+		return err
+	}
+
+	// Other providers check their own machine selection
+	if providerName != providerAWS {
+		provider := nodeProviders[providerName]
+		if err := provider.Validate(userID, req); err != nil {
+			return err
+		}
+		if !provider.Bootstrapped {
+			return nil
 		}
 
-		// Generate startup script for Solana node
-		userData := generateSolanaNodeScript(req, nodeID, node.DeployToken)
-
-		// Define EC2 instance parameters
-		runParams := &ec2.RunInstancesInput{
-			ImageId:      aws.String(getSolanaAMI(req.Region)),
-			InstanceType: aws.String(req.InstanceType),
-			MinCount:     aws.Int64(1),
-			MaxCount:     aws.Int64(1),
-			UserData:     aws.String(base64.StdEncoding.EncodeToString([]byte(userData))),
-			BlockDeviceMappings: []*ec2.BlockDeviceMapping{
-				{
-					DeviceName: aws.String("/dev/sda1"),
-					Ebs: &ec2.EbsBlockDevice{
-						DeleteOnTermination: aws.Bool(true),
-						VolumeSize:          aws.Int64(int64(req.DiskSize)),
-						VolumeType:          aws.String("gp3"),
-					},
-				},
-			},
-			TagSpecifications: []*ec2.TagSpecification{
-				{
-					ResourceType: aws.String("instance"),
-					Tags: []*ec2.Tag{
-						{
-							Key:   aws.String("Name"),
-							Value: aws.String(req.NodeName),
-						},
-						{
-							Key:   aws.String("NodeID"),
-							Value: aws.String(nodeID),
-						},
-						{
-							Key:   aws.String("UserID"),
-							Value: aws.String(userID),
-						},
-					},
-				},
-			},
-			KeyName:          aws.String(keyName), // Add SSH key name here
-			SecurityGroupIds: []*string{aws.String(sgID)},
+		if _, err := resolveCluster(userID, req.NetworkType); err != nil {
+			return err
+		}
+		if err := validateRetention(req); err != nil {
+			return err
 		}
+		return validateClientSelection(req)
+	}
 
-		// Launch EC2 instance
-		runResult, err := ec2Client.RunInstances(runParams)
+	if _, err := resolveCluster(userID, req.NetworkType); err != nil {
+		return err
+	}
 
-		if err != nil {
-			updateNodeStatus(nodeID, "failed", fmt.Sprintf("Failed to deploy: %v", err))
-			return
-		}
+	if err := ValidateInstanceSelection(req); err != nil {
+		return err
+	}
 
-		// Get instance ID
-		instanceID := *runResult.Instances[0].InstanceId
+	if err := validateDataVolumes(req); err != nil {
+		return err
+	}
 
-		// Update node with instance ID
-		updateNodeInstance(nodeID, instanceID)
+	if err := validateInstanceStore(userID, req); err != nil {
+		return err
+	}
 
-		// Monitor instance until it's running and setup is complete
-		go monitorNodeDeployment(nodeID, instanceID, ec2Client)
-	}()
+	if err := validateRetention(req); err != nil {
+		return err
+	}
 
-	return nodeID, nil
+	return validateClientSelection(req)
 }
 
 // GetNodeByID retrieves a node by ID
@@ -338,7 +544,7 @@ func GetNodesByUserID(userID string) ([]models.Node, error) {
 	return repository.GetNodesByUserID(userID)
 }
 
-// DeleteNode deletes a node and terminates the associated AWS instance
+// DeleteNode deletes a node and terminates the machine it runs on
 func DeleteNode(nodeID, userID string) error {
 	// Get node details
 	node, err := repository.GetNodeByID(nodeID, userID)
@@ -351,15 +557,18 @@ func DeleteNode(nodeID, userID string) error {
 		return fmt.Errorf("node not found or you don't have permission")
 	}
 
-	// If there's an instance ID, terminate the EC2 instance
-	if node.InstanceID != "" {
-		sess, err := GetAWSSession(userID)
+	// Nodes on other providers are released through the provider catalog,
+	// otherwise terminate the EC2 instance if there is one
+	if provider, ok := nodeProviders[node.Provider]; ok {
+		if err := provider.Delete(node); err != nil {
+			return err
+		}
+	} else if node.InstanceID != "" {
+		ec2Client, err := getNodeEC2Client(node)
 		if err != nil {
 			return err
 		}
 
-		ec2Client := ec2.New(sess)
-
 		_, err = ec2Client.TerminateInstances(&ec2.TerminateInstancesInput{
 			InstanceIds: []*string{
 				aws.String(node.InstanceID),
@@ -375,449 +584,6 @@ func DeleteNode(nodeID, userID string) error {
 	return repository.DeleteNode(nodeID, userID)
 }
 
-// Generate a script to setup a Solana node
-func generateSolanaNodeScript(req models.NodeDeployRequest, nodeID string, deployToken string) string {
-	script := `#!/bin/bash
-# Exit on command failures but allow the script to handle and report errors
-set -e
-
-# Log all output to a file
-exec > >(tee -a /var/log/solana-deployment.log) 2>&1
-echo "Starting Solana node deployment at $(date)"
-
-# Function to send deployment status updates to NodeEase API
-function update_status() {
-    local STEP=$1
-    local MESSAGE=$2
-    local PROGRESS=$3
-    local STATUS=$4  # Optional status parameter
-    
-    if [ -z "$STATUS" ]; then
-        STATUS="deploying"
-    fi
-    
-    # Also log status locally before attempting to send it
-    echo "$(date): [$STEP] $MESSAGE ($PROGRESS%)" >> /var/log/solana-deployment.log
-    echo "$(date): [$STEP] $MESSAGE ($PROGRESS%)"
-    
-    # Try sending the status update to the API with retries
-    local MAX_RETRIES=5
-    local RETRY_COUNT=0
-    local SUCCESS=false
-    
-    while [ $RETRY_COUNT -lt $MAX_RETRIES ] && [ "$SUCCESS" != "true" ]; do
-        HTTP_RESPONSE=$(curl -s -X POST "$API_BASE_URL/node-status/$NODE_ID/$DEPLOY_TOKEN" \
-            -H "Content-Type: application/json" \
-            -d "{\"nodeId\": \"$NODE_ID\", \"step\": \"$STEP\", \"message\": \"$MESSAGE\", \"progress\": $PROGRESS, \"status\": \"$STATUS\"}" \
-            -o /dev/null -w "%{http_code}" 2>/dev/null || echo "0")
-        
-        if [ "$HTTP_RESPONSE" == "200" ]; then
-            SUCCESS=true
-            echo "Status update sent successfully"
-            break
-        else
-            RETRY_COUNT=$((RETRY_COUNT+1))
-            echo "$(date): Warning: Failed to send status update (HTTP $HTTP_RESPONSE). Retry $RETRY_COUNT of $MAX_RETRIES..."
-            echo "API URL being used: $API_BASE_URL/node-status/$NODE_ID/$DEPLOY_TOKEN"
-            sleep 3
-        fi
-    done
-    
-    if [ "$SUCCESS" != "true" ]; then
-        echo "$(date): Warning: Failed to send status update after $MAX_RETRIES retries. Continuing deployment..."
-        # Save the failed status update to try sending it again later
-        echo "{\"step\": \"$STEP\", \"message\": \"$MESSAGE\", \"progress\": $PROGRESS, \"status\": \"$STATUS\"}" >> /tmp/failed_status_updates.log
-    fi
-}
-
-# Set deployment variables
-NODE_ID="NODE_ID_PLACEHOLDER"
-DEPLOY_TOKEN="TOKEN_PLACEHOLDER"
-API_BASE_URL="API_BASE_URL_PLACEHOLDER"
-
-# Start deployment
-update_status "system_update" "Updating system packages" 5
-
-# We'll add a small sleep to ensure system is ready
-sleep 10
-echo "Starting system update and installation..."
-
-# Update system and install dependencies
-apt-get update && apt-get upgrade -y
-apt-get install -y git curl jq build-essential pkg-config libssl-dev libudev-dev unzip chrony
-systemctl start chronyd
-update_status "system_deps" "System dependencies installed" 10
-
-# Create solana user
-id -u solana &>/dev/null || useradd -m -s /bin/bash solana
-update_status "setup_user" "Created Solana user" 15
-
-# Mount data volume and setup solana directory
-mkdir -p /data/solana
-mkdir -p /data/solana/ledger
-mkdir -p /data/solana/accounts
-chown -R solana:solana /data/solana
-update_status "disk_setup" "Data directory prepared" 30
-
-# Install Solana - Direct approach with the Anza client
-update_status "solana_install" "Installing Solana software (Anza Agave client)" 35
-
-# Install Solana using the official Anza installer
-su - solana -c 'sh -c "$(curl -sSfL https://release.anza.xyz/v2.2.14/install)"'
-
-# Add to system PATH for everyone
-echo 'export PATH="/home/solana/.local/share/solana/install/active_release/bin:$PATH"' > /etc/profile.d/solana-path.sh
-chmod +x /etc/profile.d/solana-path.sh
-
-# Also add to solana user's bash profile
-echo 'export PATH="/home/solana/.local/share/solana/install/active_release/bin:$PATH"' >> /home/solana/.bashrc
-echo 'export PATH="/home/solana/.local/share/solana/install/active_release/bin:$PATH"' >> /home/solana/.profile
-chown solana:solana /home/solana/.bashrc /home/solana/.profile
-
-# Source the path for current session
-source /etc/profile.d/solana-path.sh
-export PATH="/home/solana/.local/share/solana/install/active_release/bin:$PATH"
-
-# Just use the direct path to the validator binary
-VALIDATOR_BIN="/home/solana/.local/share/solana/install/active_release/bin/agave-validator"
-
-# Verify the binary exists and is executable
-if [ -x "$VALIDATOR_BIN" ]; then
-    update_status "solana_install" "Anza Agave v2.2.14 installed successfully" 40
-else
-    update_status "error" "Validator binary not found or not executable" 35 "failed"
-    echo "Expected binary at $VALIDATOR_BIN"
-    ls -la /home/solana/.local/share/solana/install/active_release/bin/
-    exit 1
-fi
-
-SOLANA_INSTALL_DIR="/home/solana/.local/share/solana/install/active_release"
-
-`
-
-	// Configure Solana RPC based on node type and network
-	network := "mainnet-beta"
-	if req.NetworkType == "testnet" {
-		network = "testnet"
-	} else if req.NetworkType == "devnet" {
-		network = "devnet"
-	}
-
-	script += fmt.Sprintf(`
-# Configure Solana for %s on %s
-cat > /etc/systemd/system/solana-validator.service << EOF
-[Unit]
-Description=Solana Validator
-After=network.target
-
-[Service]
-User=solana
-Group=solana
-Environment="PATH=/home/solana/.local/share/solana/install/active_release/bin:/usr/local/bin:/bin:/usr/bin"
-ExecStart=${VALIDATOR_BIN} \\
-`, req.RpcType, network)
-
-	// Add node type specific parameters with network-specific configurations
-	if req.RpcType == "base" {
-		// Common parameters first
-		script += fmt.Sprintf(`  --ledger /data/solana/ledger \\
-  --accounts /data/solana/accounts \\
-  --identity /data/solana/validator-keypair.json \\
-  --account-index program-id \\
-`)
-
-		// Network-specific entrypoints
-		if network == "mainnet-beta" {
-			script += fmt.Sprintf(`  --entrypoint entrypoint.%s.solana.com:8001 \\
-  --entrypoint entrypoint2.%s.solana.com:8001 \\
-  --entrypoint entrypoint3.%s.solana.com:8001 \\
-  --entrypoint entrypoint4.%s.solana.com:8001 \\
-  --entrypoint entrypoint5.%s.solana.com:8001 \\
-`, network, network, network, network, network)
-		} else {
-			// For testnet and devnet, use only the first 3 entrypoints
-			script += fmt.Sprintf(`  --entrypoint entrypoint.%s.solana.com:8001 \\
-  --entrypoint entrypoint2.%s.solana.com:8001 \\
-  --entrypoint entrypoint3.%s.solana.com:8001 \\
-`, network, network, network)
-		}
-
-		// Network-specific known validators
-		if network == "mainnet-beta" {
-			script += `  --known-validator 5D1fNXzvv5NjV1ysLjirC4WY92RNsVH18vjmcszZd8on \\
-  --known-validator dDzy5SR3AXdYWVqbDEkVFdvSPCtS9ihF5kJkHCtXoFs \\
-  --known-validator eoKpUABi59aT4rR9HGS3LcMecfut9x7zJyodWWP43YQ \\
-  --known-validator 7XSY3MrYnK8vq693Rju17bbPkCN3Z7KvvfvJx4kdrsSY \\
-  --known-validator Ft5fbkqNa76vnsjYNwjDZUXoTWpP7VYm3mtsaQckQADN \\
-  --known-validator 9QxCLckBiJc783jnMvXZubK4wH86Eqqvashtrwvcsgkv \\
-`
-		} else if network == "testnet" {
-			script += `  --known-validator 5D1fNXzvv5NjV1ysLjirC4WY92RNsVH18vjmcszZd8on \\
-  --known-validator Ft5fbkqNa76vnsjYNwjDZUXoTWpP7VYm3mtsaQckQADN \\
-  --known-validator 7XSY3MrYnK8vq693Rju17bbPkCN3Z7KvvfvJx4kdrsSY \\
-`
-		} else {
-			// devnet validators
-			script += `  --known-validator dv1ZAGvdsz5hHLwWXsVnM94hWf1pjbKVau1QVkaMJ92 \\
-  --known-validator dv2eQHeP4RFrJZ6UeiZWoc3XTtmtZCUKxxCApCDcRNV \\
-  --known-validator dv4ACNkpYPcE3aKmYDqZm9G5EB3J4MRoeE7WNDRBVJB \\
-`
-		}
-
-		// Add common parameters after network-specific ones
-		script += `  --account-index program-id spl-token-owner spl-token-mint \\
-  --account-index-exclude-key kinXdEcpDQeHPEuQnqmUgtYykqKGVFq6CeVX5iAHJq6 \\
-  --account-index-exclude-key TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA \\
-  --rpc-port 8899 \\
-  --private-rpc \\
-  --full-rpc-api \\
-  --dynamic-port-range 8000-8020 \\
-  --wal-recovery-mode skip_any_corrupted_record \\
-  --no-voting \\
-  --enable-rpc-transaction-history \\
-  --limit-ledger-size \\
-  --rpc-bind-address 0.0.0.0 \\
-`
-	} else if req.RpcType == "extended" {
-		// Extended node configuration with network-specific settings
-		script += fmt.Sprintf(`  --ledger /data/solana/ledger \\
-  --identity /data/solana/validator-keypair.json \\
-`)
-
-		// Network-specific entrypoints for extended nodes
-		if network == "mainnet-beta" {
-			script += fmt.Sprintf(`  --entrypoint entrypoint.%s.solana.com:8001 \\
-  --entrypoint entrypoint2.%s.solana.com:8001 \\
-  --entrypoint entrypoint3.%s.solana.com:8001 \\
-`, network, network, network)
-		} else {
-			// For testnet and devnet, use only the first 3 entrypoints
-			script += fmt.Sprintf(`  --entrypoint entrypoint.%s.solana.com:8001 \\
-  --entrypoint entrypoint2.%s.solana.com:8001 \\
-  --entrypoint entrypoint3.%s.solana.com:8001 \\
-`, network, network, network)
-		}
-
-		// Network-specific validators for extended nodes
-		if network == "mainnet-beta" {
-			script += `  --known-validator 7Np41oeYqPefeNQEHSv1UDhYrehxin3NStELsSKCT4K2 \\
-  --known-validator GdnSyH3YtwcxFvQrVVJMm1JhTS4QVX7MFsX56uJLUfiZ \\
-  --known-validator DE1bawNcRJB9rVm3buyMVfr8mBEoyyu73NBovf2oXJsJ \\
-  --expected-genesis-hash 5eykt4UsFv8P8NJdTREpY1vzqKqZKvdpKuc147dw2N9d \\
-`
-		} else if network == "testnet" {
-			script += `  --known-validator 5D1fNXzvv5NjV1ysLjirC4WY92RNsVH18vjmcszZd8on \\
-  --known-validator Ft5fbkqNa76vnsjYNwjDZUXoTWpP7VYm3mtsaQckQADN \\
-  --known-validator 7XSY3MrYnK8vq693Rju17bbPkCN3Z7KvvfvJx4kdrsSY \\
-`
-		} else {
-			// devnet validators
-			script += `  --known-validator dv1ZAGvdsz5hHLwWXsVnM94hWf1pjbKVau1QVkaMJ92 \\
-  --known-validator dv2eQHeP4RFrJZ6UeiZWoc3XTtmtZCUKxxCApCDcRNV \\
-  --known-validator dv4ACNkpYPcE3aKmYDqZm9G5EB3J4MRoeE7WNDRBVJB \\
-`
-		}
-
-		// Common extended mode settings
-		script += `  --rpc-port 8899 \\
-  --dynamic-port-range 8000-8020 \\
-  --no-untrusted-rpc \\
-  --no-voting \\
-  --full-rpc-api \\
-  --enable-rpc-transaction-history \\
-  --enable-extended-tx-metadata-storage \\
-  --enable-cpi-and-log-storage \\
-`
-
-		if req.HistoryLength == "full" {
-			script += "  --no-snapshot-fetch \\\n"
-		}
-	}
-
-	// Complete the systemd service definition
-	script += `
-Restart=always
-RestartSec=30s
-LimitNOFILE=700000
-
-[Install]
-WantedBy=multi-user.target
-EOF
-update_status "config_setup" "Solana validator service configured" 60
-
-# Create validator identity using the correct solana-keygen binary
-su - solana -c 'solana-keygen new -o /data/solana/validator-keypair.json --no-bip39-passphrase'
-if [ ! -f "/data/solana/validator-keypair.json" ]; then
-    update_status "error" "Failed to create validator keypair" 70 "failed"
-    ls -la /data/solana
-    exit 1
-fi
-
-# Set proper permissions for the Solana data directory
-chown -R solana:solana /data/solana
-chmod -R 700 /data/solana
-update_status "identity_setup" "Validator identity created" 70
-
-# System tuning for Solana
-update_status "system_tuning" "Applying system performance tuning" 72
-
-# Create sysctl config file for Solana
-cat > /etc/sysctl.d/21-solana-validator.conf << EOF
-# Increase UDP buffer sizes
-net.core.rmem_default = 134217728
-net.core.rmem_max = 134217728
-net.core.wmem_default = 134217728
-net.core.wmem_max = 134217728
-
-# Increase memory mapped files limit
-vm.max_map_count = 1000000
-
-# Increase number of allowed open files
-fs.nr_open = 1000000
-EOF
-
-# Apply sysctl settings
-sysctl -p /etc/sysctl.d/21-solana-validator.conf
-
-# Update limits.conf to increase file descriptor limits
-cat > /etc/security/limits.d/90-solana.conf << EOF
-* soft nofile 1000000
-* hard nofile 1000000
-solana soft nofile 1000000
-solana hard nofile 1000000
-EOF
-
-# Configure swap area if you want to
-fallocate -l 8G /swap
-chmod 600 /swap
-mkswap /swap
-swapon /swap
-echo '/swap none swap sw 0 0' >> /etc/fstab
-
-update_status "system_tuning" "System performance tuning applied" 74
-
-# Start Solana validator service
-systemctl daemon-reload
-systemctl enable solana-validator
-update_status "service_setup" "Solana services enabled" 75
-
-# Install monitoring software
-update_status "monitoring_setup" "Installing monitoring tools" 80
-curl -LO https://github.com/prometheus/node_exporter/releases/download/v1.5.0/node_exporter-1.5.0.linux-amd64.tar.gz
-tar -xzf node_exporter-1.5.0.linux-amd64.tar.gz
-cp node_exporter-1.5.0.linux-amd64/node_exporter /usr/local/bin/
-rm -rf node_exporter-1.5.0.linux-amd64*
-
-# Create systemd service for node_exporter
-cat > /etc/systemd/system/node_exporter.service << EOF
-[Unit]
-Description=Node Exporter
-After=network.target
-
-[Service]
-User=root
-Group=root
-Type=simple
-ExecStart=/usr/local/bin/node_exporter
-
-[Install]
-WantedBy=multi-user.target
-EOF
-
-systemctl daemon-reload
-systemctl enable node_exporter
-systemctl start node_exporter
-update_status "monitoring_setup" "Monitoring tools installed" 90
-
-# Start Solana validator service
-systemctl start solana-validator
-sleep 20
-
-# Report completion
-update_status "complete" "Solana node deployment complete" 100 "running"
-
-# Setup status reporter and logs
-mkdir -p /var/log/solana
-
-# Create a script to periodically collect validator logs
-cat > /usr/local/bin/collect-solana-logs.sh << 'EOF'
-#!/bin/bash
-journalctl -u solana-validator --no-pager -n 1000 > /var/log/solana/validator-recent.log
-journalctl -u solana-validator --no-pager -p err > /var/log/solana/validator-errors.log
-EOF
-
-chmod +x /usr/local/bin/collect-solana-logs.sh
-
-# Set up timers for monitoring
-cat > /etc/systemd/system/nodeease-reporter.service << EOF
-[Unit]
-Description=NodeEase Status Reporter
-After=solana-validator.service
-
-[Service]
-Type=oneshot
-Environment="NODE_ID=$NODE_ID"
-Environment="DEPLOY_TOKEN=$DEPLOY_TOKEN" 
-Environment="API_BASE_URL=$API_BASE_URL"
-ExecStart=/bin/bash -c 'if systemctl is-active --quiet solana-validator; then curl -s -X POST "$API_BASE_URL/node-status/$NODE_ID/$DEPLOY_TOKEN" -H "Content-Type: application/json" -d "{\"nodeId\": \"$NODE_ID\", \"step\": \"running\", \"message\": \"Solana validator service is running\", \"progress\": 100, \"status\": \"running\"}"; else curl -s -X POST "$API_BASE_URL/node-status/$NODE_ID/$DEPLOY_TOKEN" -H "Content-Type: application/json" -d "{\"nodeId\": \"$NODE_ID\", \"step\": \"warning\", \"message\": \"Solana validator service is not running\", \"progress\": 100, \"status\": \"failed\"}"; fi'
-
-[Install]
-WantedBy=multi-user.target
-EOF
-
-# Set up timers
-systemctl daemon-reload
-systemctl enable nodeease-reporter.service
-systemctl start nodeease-reporter.service
-
-# Get public IP and make final callback
-PUBLIC_IP=$(curl -s http://checkip.amazonaws.com || curl -s https://api.ipify.org || hostname -I | awk '{print $1}')
-update_status "complete" "Deployment complete. RPC endpoint: http://$PUBLIC_IP:8899" 100 "running"
-echo "Node deployment complete at $(date)"
-echo "RPC Endpoint: http://$PUBLIC_IP:8899"
-`
-
-	// Replace placeholders with actual values
-	script = strings.ReplaceAll(script, "NODE_ID_PLACEHOLDER", nodeID)
-	script = strings.ReplaceAll(script, "TOKEN_PLACEHOLDER", deployToken)
-	// Use your server's public IP address so the VM can reach it
-	script = strings.ReplaceAll(script, "API_BASE_URL_PLACEHOLDER", "http://nodeease.up.railway.app/api")
-
-	return script
-}
-
-// Get the right AMI for the region
-func getSolanaAMI(region string) string {
-	// Map of Ubuntu 22.04 LTS AMIs by region (updated May 2025)
-	amiMap := map[string]string{
-		"us-east-1":      "ami-0fc5d935ebf8bc3bc", // N. Virginia
-		"us-east-2":      "ami-0f09ef696435ff61a", // Ohio
-		"us-west-1":      "ami-0cbd40f694b804622", // N. California
-		"us-west-2":      "ami-0efcece6bed30fd98", // Oregon
-		"eu-west-1":      "ami-0694d931cee245f1e", // Ireland
-		"eu-central-1":   "ami-0faab6bdbac9486fb", // Frankfurt
-		"ap-northeast-1": "ami-0bc3d34a629664f3f", // Tokyo
-		"ap-southeast-1": "ami-0d7901b37615eece7", // Singapore
-		"ap-southeast-2": "ami-0d6f74b9139d25896", // Sydney
-	}
-
-	// If the region is in our map, return the associated AMI
-	if ami, ok := amiMap[region]; ok {
-		return ami
-	}
-
-	// If the region isn't in our map, return the us-east-1 AMI and log a warning
-	fmt.Printf("Warning: No AMI defined for region %s, falling back to us-east-1 AMI\n", region)
-
-	// Check if us-east-1 AMI exists as a fallback
-	if fallbackAmi, exists := amiMap["us-east-1"]; exists {
-		return fallbackAmi
-	}
-
-	// In case of any issues, return Ubuntu 22.04 LTS default AMI for us-east-1
-	return "ami-0fc5d935ebf8bc3bc" // Latest stable Ubuntu 22.04 LTS AMI
-}
-
 // Update node status
 func updateNodeStatus(nodeID, status, detail string) error {
 	node, err := repository.GetNodeByIDInternal(nodeID)
@@ -862,6 +628,11 @@ func updateNodeWithLog(nodeID, status, step, detail string, progress int) error
 	node.StatusDetail = detail
 	node.UpdatedAt = time.Now()
 
+	// Progress means the deploy is no longer stalled
+	if status == "deploying" {
+		node.StalledStep = ""
+	}
+
 	return repository.SaveNode(node)
 }
 
@@ -917,6 +688,15 @@ func monitorNodeDeployment(nodeID, instanceID string, ec2Client *ec2.EC2) {
 				rpcEndpoint := fmt.Sprintf("http://%s:8899", *instance.PublicIpAddress)
 				updateNodeRPCEndpoint(nodeID, rpcEndpoint)
 
+				// Record the data volume IDs for later resize and snapshot operations
+				if err := updateNodeVolumes(nodeID, instance); err != nil {
+					repository.AddNodeDeploymentLog(nodeID, models.NodeDeploymentLog{
+						Timestamp: time.Now(),
+						Step:      "vm_ready",
+						Message:   fmt.Sprintf("Failed to record data volume IDs, resizes and snapshots won't find them: %v", err),
+					})
+				}
+
 				// Add a log entry that we've successfully provisioned the VM
 				updateNodeWithLog(nodeID, "deploying", "vm_ready", "VM is running, setting up node software...", 15)
 
@@ -960,6 +740,19 @@ func updateNodeRPCEndpoint(nodeID, endpoint string) error {
 	return repository.SaveNode(node)
 }
 
+// Update node websocket endpoint
+func updateNodeWsEndpoint(nodeID, endpoint string) error {
+	node, err := repository.GetNodeByIDInternal(nodeID)
+	if err != nil {
+		return err
+	}
+
+	node.WsEndpoint = endpoint
+	node.UpdatedAt = time.Now()
+
+	return repository.SaveNode(node)
+}
+
 // GetNodeWithDeploymentLogs retrieves a node with its deployment logs
 func GetNodeWithDeploymentLogs(nodeID, userID string) (models.Node, error) {
 	node, err := repository.GetNodeByID(nodeID, userID)
@@ -986,8 +779,9 @@ func generateDeploymentToken(nodeID string) string {
 	return fmt.Sprintf("%s-%d", nodeID, time.Now().Unix())
 }
 
-// UpdateNodeDeploymentStatus updates node status based on VM callbacks
-func UpdateNodeDeploymentStatus(nodeID, token string, update models.NodeStatusUpdate) error {
+// AuthenticateNode checks the deployment token a node calls back with. It
+// returns ErrInvalidDeployToken for an unknown node or a wrong token.
+func AuthenticateNode(nodeID, token string) error {
 	// Get node without user ID check since this is coming from the VM
 	node, err := repository.GetNodeByIDInternal(nodeID)
 	if err != nil {
@@ -995,39 +789,99 @@ func UpdateNodeDeploymentStatus(nodeID, token string, update models.NodeStatusUp
 	}
 
 	// Verify token (in production use proper HMAC validation)
-	if node.DeployToken != token {
-		return fmt.Errorf("invalid deployment token")
+	if node.ID == "" || token == "" || node.DeployToken != token {
+		return ErrInvalidDeployToken
 	}
 
-	// Create a log entry
-	logEntry := models.NodeDeploymentLog{
-		Timestamp: time.Now(),
-		Step:      update.Step,
-		Message:   update.Message,
-		Progress:  update.Progress,
+	return nil
+}
+
+// UpdateNodeDeploymentStatus updates node status based on VM callbacks
+func UpdateNodeDeploymentStatus(nodeID, token string, update models.NodeStatusUpdate) error {
+	_, err := UpdateNodeDeploymentStatuses(nodeID, token, []models.NodeStatusUpdate{update})
+	return err
+}
+
+// UpdateNodeDeploymentStatuses applies a batch of VM callbacks in sequence
+// order. A sequenced update is recorded once, and one older than the newest
+// applied update is logged as stale without changing the node's status, so late
+// retries can't undo newer progress.
+func UpdateNodeDeploymentStatuses(nodeID, token string, updates []models.NodeStatusUpdate) (models.NodeStatusBatchResult, error) {
+	if len(updates) > maxStatusBatchSize {
+		return models.NodeStatusBatchResult{}, fmt.Errorf("at most %d updates can be sent at once", maxStatusBatchSize)
 	}
 
-	// Initialize logs array if nil
-	if node.DeploymentLogs == nil {
-		node.DeploymentLogs = []models.NodeDeploymentLog{}
+	// Updates of a node are applied one request at a time
+	defer lockNodeStatus(nodeID)()
+
+	// Get node without user ID check since this is coming from the VM
+	node, err := repository.GetNodeByIDInternal(nodeID)
+	if err != nil {
+		return models.NodeStatusBatchResult{}, err
 	}
 
-	// Add log entry
-	node.DeploymentLogs = append(node.DeploymentLogs, logEntry)
+	// Verify token (in production use proper HMAC validation)
+	if node.ID == "" || node.DeployToken != token {
+		return models.NodeStatusBatchResult{}, fmt.Errorf("invalid deployment token")
+	}
 
-	if err := repository.AddNodeDeploymentLog(nodeID, logEntry); err != nil {
-		return err
+	latest, err := repository.GetLatestStatusSequence(nodeID)
+	if err != nil {
+		return models.NodeStatusBatchResult{}, err
 	}
 
-	// Update status
-	if update.Status != "" {
-		node.Status = update.Status
+	// Unsequenced updates keep their order ahead of sequenced ones
+	sort.SliceStable(updates, func(i, j int) bool {
+		return updates[i].Sequence < updates[j].Sequence
+	})
+
+	var result models.NodeStatusBatchResult
+	for _, update := range updates {
+		// Create a log entry
+		logEntry := models.NodeDeploymentLog{
+			Timestamp: time.Now(),
+			Step:      update.Step,
+			Message:   update.Message,
+			Progress:  update.Progress,
+		}
+
+		if update.Sequence <= 0 {
+			if err := repository.AddNodeDeploymentLog(nodeID, logEntry); err != nil {
+				return result, err
+			}
+		} else {
+			recorded, err := repository.AddSequencedNodeDeploymentLog(nodeID, logEntry, update.Sequence, update.Sequence < latest)
+			if err != nil {
+				return result, err
+			}
+			if !recorded {
+				result.Duplicates++
+				continue
+			}
+			if update.Sequence < latest {
+				result.Stale++
+				continue
+			}
+			latest = update.Sequence
+		}
+
+		// Update status
+		if update.Status != "" {
+			node.Status = update.Status
+		}
+		node.StatusDetail = update.Message
+		node.StalledStep = ""
+		result.Applied++
+	}
+	result.LastSequence = latest
+
+	if result.Applied == 0 {
+		return result, nil
 	}
-	node.StatusDetail = update.Message
-	node.UpdatedAt = time.Now()
 
 	// Save node
-	return repository.SaveNode(node)
+	node.UpdatedAt = time.Now()
+	return result, repository.SaveNode(node)
 }
 
 // Add this function to generate SSH key pairs
@@ -1070,32 +924,36 @@ func GetNodeSSHKey(nodeID, userID string) (string, error) {
 	return node.SshPrivateKey, nil
 }
 
-// StartNode starts a stopped EC2 instance
-func StartNode(nodeID, userID string) error {
+// StartNode starts a stopped EC2 instance. The returned warning is non-empty
+// when starting has side effects the user should know about.
+func StartNode(nodeID, userID string) (string, error) {
 	// Get node details
 	node, err := repository.GetNodeByID(nodeID, userID)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	// Only proceed if this is the user's node
 	if node.UserID != userID {
-		return fmt.Errorf("node not found or you don't have permission")
+		return "", fmt.Errorf("node not found or you don't have permission")
+	}
+
+	// Nodes on other providers are controlled through the provider catalog
+	if provider, ok := nodeProviders[node.Provider]; ok {
+		return "", provider.Start(node)
 	}
 
 	// Ensure there's an instance ID
 	if node.InstanceID == "" {
-		return fmt.Errorf("no EC2 instance associated with this node")
+		return "", fmt.Errorf("no EC2 instance associated with this node")
 	}
 
-	// Get AWS session
-	sess, err := GetAWSSession(userID)
+	// Get an EC2 client in the node's region
+	ec2Client, err := getNodeEC2Client(node)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	ec2Client := ec2.New(sess)
-
 	// Start the EC2 instance
 	_, err = ec2Client.StartInstances(&ec2.StartInstancesInput{
 		InstanceIds: []*string{
@@ -1104,44 +962,59 @@ func StartNode(nodeID, userID string) error {
 	})
 
 	if err != nil {
-		return fmt.Errorf("failed to start instance: %v", err)
+		return "", fmt.Errorf("failed to start instance: %v", err)
 	}
 
 	// Update node status
 	updateNodeStatus(nodeID, "starting", "Starting the EC2 instance...")
 
+	// Let the user know instance storage was reset
+	warning := instanceStoreWarning(node, "start")
+	if warning != "" {
+		updateNodeWithLog(nodeID, "starting", "instance_store", warning, 0)
+	}
+
 	// Start monitoring the instance state
 	go monitorInstanceStateChange(nodeID, node.InstanceID, ec2Client, "start")
 
-	return nil
+	return warning, nil
 }
 
-// StopNode stops a running EC2 instance
-func StopNode(nodeID, userID string) error {
+// StopNode stops a running EC2 instance. A node with data on instance storage
+// is only stopped with force, and the returned warning is non-empty then.
+func StopNode(nodeID, userID string, force bool) (string, error) {
 	// Get node details
 	node, err := repository.GetNodeByID(nodeID, userID)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	// Only proceed if this is the user's node
 	if node.UserID != userID {
-		return fmt.Errorf("node not found or you don't have permission")
+		return "", fmt.Errorf("node not found or you don't have permission")
+	}
+
+	// Stopping wipes instance storage, so it has to be confirmed
+	if err := confirmInstanceStoreAction(node, "stop", force); err != nil {
+		return "", err
+	}
+
+	// Nodes on other providers are controlled through the provider catalog
+	if provider, ok := nodeProviders[node.Provider]; ok {
+		return "", provider.Stop(node)
 	}
 
 	// Ensure there's an instance ID
 	if node.InstanceID == "" {
-		return fmt.Errorf("no EC2 instance associated with this node")
+		return "", fmt.Errorf("no EC2 instance associated with this node")
 	}
 
-	// Get AWS session
-	sess, err := GetAWSSession(userID)
+	// Get an EC2 client in the node's region
+	ec2Client, err := getNodeEC2Client(node)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	ec2Client := ec2.New(sess)
-
 	// Stop the EC2 instance
 	_, err = ec2Client.StopInstances(&ec2.StopInstancesInput{
 		InstanceIds: []*string{
@@ -1150,44 +1023,54 @@ func StopNode(nodeID, userID string) error {
 	})
 
 	if err != nil {
-		return fmt.Errorf("failed to stop instance: %v", err)
+		return "", fmt.Errorf("failed to stop instance: %v", err)
 	}
 
 	// Update node status
 	updateNodeStatus(nodeID, "stopping", "Stopping the EC2 instance...")
 
+	// Let the user know instance storage is about to be wiped
+	warning := instanceStoreWarning(node, "stop")
+	if warning != "" {
+		updateNodeWithLog(nodeID, "stopping", "instance_store", warning, 0)
+	}
+
 	// Start monitoring the instance state
 	go monitorInstanceStateChange(nodeID, node.InstanceID, ec2Client, "stop")
 
-	return nil
+	return warning, nil
 }
 
-// RebootNode reboots a running EC2 instance
-func RebootNode(nodeID, userID string) error {
+// RebootNode reboots a running EC2 instance. The returned warning is
+// non-empty when the node keeps data on instance storage.
+func RebootNode(nodeID, userID string) (string, error) {
 	// Get node details
 	node, err := repository.GetNodeByID(nodeID, userID)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	// Only proceed if this is the user's node
 	if node.UserID != userID {
-		return fmt.Errorf("node not found or you don't have permission")
+		return "", fmt.Errorf("node not found or you don't have permission")
+	}
+
+	// Nodes on other providers are controlled through the provider catalog
+	if provider, ok := nodeProviders[node.Provider]; ok {
+		return "", provider.Reboot(node)
 	}
 
 	// Ensure there's an instance ID
 	if node.InstanceID == "" {
-		return fmt.Errorf("no EC2 instance associated with this node")
+		return "", fmt.Errorf("no EC2 instance associated with this node")
 	}
 
-	// Get AWS session
-	sess, err := GetAWSSession(userID)
+	// Get an EC2 client in the node's region
+	ec2Client, err := getNodeEC2Client(node)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	ec2Client := ec2.New(sess)
-
 	// Reboot the EC2 instance
 	_, err = ec2Client.RebootInstances(&ec2.RebootInstancesInput{
 		InstanceIds: []*string{
@@ -1196,16 +1079,32 @@ func RebootNode(nodeID, userID string) error {
 	})
 
 	if err != nil {
-		return fmt.Errorf("failed to reboot instance: %v", err)
+		return "", fmt.Errorf("failed to reboot instance: %v", err)
 	}
 
 	// Update node status
 	updateNodeStatus(nodeID, "rebooting", "Rebooting the EC2 instance...")
 
+	// Let the user know what a failed reboot means for instance storage
+	warning := instanceStoreWarning(node, "reboot")
+	if warning != "" {
+		updateNodeWithLog(nodeID, "rebooting", "instance_store", warning, 0)
+	}
+
 	// Start monitoring the instance state
 	go monitorInstanceStateChange(nodeID, node.InstanceID, ec2Client, "reboot")
 
-	return nil
+	return warning, nil
+}
+
+// getNodeEC2Client returns an EC2 client for the region the node was deployed to
+func getNodeEC2Client(node models.Node) (*ec2.EC2, error) {
+	sess, err := GetAWSSessionForRegion(node.UserID, node.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	return ec2.New(sess), nil
 }
 
 // Helper function to monitor instance state changes
@@ -0,0 +1,360 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/0saurabh0/NodeEase/models"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+	"github.com/google/uuid"
+)
+
+// vcpuQuota identifies the Service Quotas entry that limits an instance family
+type vcpuQuota struct {
+	Code string
+	Name string
+}
+
+// vcpuQuotasByFamily maps the letter prefix of an instance family to the
+// On-Demand vCPU quota it counts against
+var vcpuQuotasByFamily = map[string]vcpuQuota{
+	"a":   {Code: "L-1216C47A", Name: "Running On-Demand Standard instances"},
+	"c":   {Code: "L-1216C47A", Name: "Running On-Demand Standard instances"},
+	"d":   {Code: "L-1216C47A", Name: "Running On-Demand Standard instances"},
+	"h":   {Code: "L-1216C47A", Name: "Running On-Demand Standard instances"},
+	"i":   {Code: "L-1216C47A", Name: "Running On-Demand Standard instances"},
+	"m":   {Code: "L-1216C47A", Name: "Running On-Demand Standard instances"},
+	"r":   {Code: "L-1216C47A", Name: "Running On-Demand Standard instances"},
+	"t":   {Code: "L-1216C47A", Name: "Running On-Demand Standard instances"},
+	"z":   {Code: "L-1216C47A", Name: "Running On-Demand Standard instances"},
+	"f":   {Code: "L-74FC7D96", Name: "Running On-Demand F instances"},
+	"g":   {Code: "L-DB2E81BA", Name: "Running On-Demand G and VT instances"},
+	"vt":  {Code: "L-DB2E81BA", Name: "Running On-Demand G and VT instances"},
+	"inf": {Code: "L-1945791B", Name: "Running On-Demand Inf instances"},
+	"p":   {Code: "L-417A185B", Name: "Running On-Demand P instances"},
+	"x":   {Code: "L-7295265B", Name: "Running On-Demand X instances"},
+	"trn": {Code: "L-2C3B7624", Name: "Running On-Demand Trn instances"},
+	"dl":  {Code: "L-6E869C2A", Name: "Running On-Demand DL instances"},
+	"u":   {Code: "L-43DA4232", Name: "Running On-Demand High Memory instances"},
+}
+
+// instanceFamilyPrefix returns the leading letters of an instance type, e.g. "r" for "r7a.16xlarge"
+func instanceFamilyPrefix(instanceType string) string {
+	family := strings.ToLower(strings.SplitN(instanceType, ".", 2)[0])
+	end := 0
+	for end < len(family) && family[end] >= 'a' && family[end] <= 'z' {
+		end++
+	}
+	return family[:end]
+}
+
+// RunDeployPreflight checks that a deployment can succeed before any resources
+// are created: IAM permissions via dry-run calls, vCPU quota headroom, a default
+// VPC/subnet and regional availability of the instance type.
+func RunDeployPreflight(userID string, req models.NodeDeployRequest) (models.PreflightResult, error) {
+	// Other providers run their own checks
+	providerName, err := resolveProvider(req.Provider)
+	if err != nil {
+		return models.PreflightResult{}, err
+	}
+	if providerName != providerAWS {
+		return nodeProviders[providerName].Preflight(userID, req)
+	}
+
+	sess, err := GetAWSSessionForRegion(userID, req.Region)
+	if err != nil {
+		return models.PreflightResult{}, fmt.Errorf("failed to get AWS session: %v", err)
+	}
+
+	ec2Client := ec2.New(sess)
+	result := models.PreflightResult{
+		Passed: true,
+		Region: aws.StringValue(sess.Config.Region),
+	}
+
+	// Checks that couldn't be evaluated are reported but don't block the deploy
+	add := func(check models.PreflightCheck) {
+		if !check.Passed && !check.Skipped {
+			result.Passed = false
+		}
+		result.Checks = append(result.Checks, check)
+	}
+
+	add(checkInstanceTypeOffered(ec2Client, req.InstanceType, result.Region))
+
+	vpcCheck, vpcID := checkDefaultNetwork(ec2Client)
+	add(vpcCheck)
+
+	// Resolve the image, the RunInstances dry-run needs it
+	imageID, err := resolveSolanaAMI(sess, req.CustomAMI, req.OSRelease, req.Architecture)
+	imageCheck := models.PreflightCheck{
+		Name:        "image",
+		Description: "A bootable image exists for the requested OS release and architecture",
+		Passed:      err == nil,
+	}
+	if err != nil {
+		imageCheck.Message = err.Error()
+		imageCheck.Remediation = "Choose a supported OS release/architecture or pin a custom AMI that exists in this region"
+	} else {
+		imageCheck.Message = fmt.Sprintf("Using %s", imageID)
+	}
+	add(imageCheck)
+
+	// IAM permissions, checked with dry-run calls so nothing is created
+	_, publicKey, err := generateSSHKeyPair()
+	if err != nil {
+		return models.PreflightResult{}, fmt.Errorf("failed to generate SSH key: %v", err)
+	}
+	_, err = ec2Client.ImportKeyPair(&ec2.ImportKeyPairInput{
+		DryRun:            aws.Bool(true),
+		KeyName:           aws.String("nodeease-preflight-" + uuid.New().String()[:8]),
+		PublicKeyMaterial: []byte(publicKey),
+	})
+	add(dryRunCheck("iam_import_key_pair", "ec2:ImportKeyPair", err))
+
+	if vpcID != "" {
+		_, err = ec2Client.CreateSecurityGroup(&ec2.CreateSecurityGroupInput{
+			DryRun:      aws.Bool(true),
+			GroupName:   aws.String("nodeease-preflight-" + uuid.New().String()[:8]),
+			Description: aws.String("NodeEase preflight"),
+			VpcId:       aws.String(vpcID),
+		})
+		add(dryRunCheck("iam_create_security_group", "ec2:CreateSecurityGroup", err))
+	}
+
+	if imageID != "" {
+		_, err = ec2Client.RunInstances(&ec2.RunInstancesInput{
+			DryRun:       aws.Bool(true),
+			ImageId:      aws.String(imageID),
+			InstanceType: aws.String(req.InstanceType),
+			MinCount:     aws.Int64(1),
+			MaxCount:     aws.Int64(1),
+			TagSpecifications: []*ec2.TagSpecification{
+				{
+					ResourceType: aws.String("instance"),
+					Tags: []*ec2.Tag{
+						{
+							Key:   aws.String("Name"),
+							Value: aws.String(req.NodeName),
+						},
+					},
+				},
+			},
+		})
+		add(dryRunCheck("iam_run_instances", "ec2:RunInstances and ec2:CreateTags", err))
+	}
+
+	add(checkVCPUQuota(sess, ec2Client, req.InstanceType))
+
+	return result, nil
+}
+
+// dryRunCheck interprets the error returned from an EC2 call made with DryRun set
+func dryRunCheck(name, permission string, err error) models.PreflightCheck {
+	check := models.PreflightCheck{
+		Name:        name,
+		Description: fmt.Sprintf("Credentials are allowed to call %s", permission),
+	}
+
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		case "DryRunOperation":
+			check.Passed = true
+			return check
+		case "UnauthorizedOperation", "AccessDenied", "AccessDeniedException":
+			check.Message = aerr.Message()
+			check.Remediation = fmt.Sprintf("Add %s to the IAM policy attached to the NodeEase credentials", permission)
+			return check
+		}
+		check.Message = fmt.Sprintf("%s: %s", aerr.Code(), aerr.Message())
+		check.Remediation = "Review the error above; the request was rejected before permissions could be evaluated"
+		return check
+	}
+
+	if err != nil {
+		check.Message = err.Error()
+		return check
+	}
+
+	// A dry run should never succeed outright, but treat it as allowed
+	check.Passed = true
+	return check
+}
+
+// skipCheck marks a check that couldn't be evaluated, e.g. because the
+// credentials may not call the API it relies on. It's reported as a warning.
+func skipCheck(check models.PreflightCheck, message, remediation string) models.PreflightCheck {
+	check.Skipped = true
+	check.Message = message
+	check.Remediation = remediation
+	return check
+}
+
+// checkInstanceTypeOffered confirms the instance type can be launched in the region
+func checkInstanceTypeOffered(ec2Client *ec2.EC2, instanceType, region string) models.PreflightCheck {
+	check := models.PreflightCheck{
+		Name:        "instance_type_offered",
+		Description: fmt.Sprintf("%s is offered in %s", instanceType, region),
+	}
+
+	output, err := ec2Client.DescribeInstanceTypeOfferings(&ec2.DescribeInstanceTypeOfferingsInput{
+		LocationType: aws.String("region"),
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("instance-type"),
+				Values: []*string{aws.String(instanceType)},
+			},
+		},
+	})
+	if err != nil {
+		return skipCheck(check, fmt.Sprintf("Could not verify availability: %v", err),
+			"Allow ec2:DescribeInstanceTypeOfferings so availability can be verified")
+	}
+
+	if len(output.InstanceTypeOfferings) == 0 {
+		check.Message = fmt.Sprintf("%s is not available in %s", instanceType, region)
+		check.Remediation = "Pick a different instance type or deploy to a region that offers it"
+		return check
+	}
+
+	check.Passed = true
+	return check
+}
+
+// checkDefaultNetwork confirms a default VPC with at least one default subnet exists
+func checkDefaultNetwork(ec2Client *ec2.EC2) (models.PreflightCheck, string) {
+	check := models.PreflightCheck{
+		Name:        "default_vpc",
+		Description: "A default VPC with a default subnet exists",
+	}
+
+	vpcs, err := ec2Client.DescribeVpcs(&ec2.DescribeVpcsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("isDefault"),
+				Values: []*string{aws.String("true")},
+			},
+		},
+	})
+	if err != nil {
+		check.Message = err.Error()
+		check.Remediation = "Allow ec2:DescribeVpcs for the NodeEase credentials"
+		return check, ""
+	}
+	if len(vpcs.Vpcs) == 0 {
+		check.Message = "No default VPC in this region"
+		check.Remediation = "Create one with `aws ec2 create-default-vpc` in this region"
+		return check, ""
+	}
+
+	vpcID := aws.StringValue(vpcs.Vpcs[0].VpcId)
+
+	subnets, err := ec2Client.DescribeSubnets(&ec2.DescribeSubnetsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []*string{aws.String(vpcID)},
+			},
+			{
+				Name:   aws.String("default-for-az"),
+				Values: []*string{aws.String("true")},
+			},
+		},
+	})
+	if err != nil {
+		check.Message = err.Error()
+		check.Remediation = "Allow ec2:DescribeSubnets for the NodeEase credentials"
+		return check, vpcID
+	}
+	if len(subnets.Subnets) == 0 {
+		check.Message = fmt.Sprintf("Default VPC %s has no default subnets", vpcID)
+		check.Remediation = "Create one with `aws ec2 create-default-subnet --availability-zone <az>`"
+		return check, vpcID
+	}
+
+	check.Passed = true
+	check.Message = fmt.Sprintf("Using %s", vpcID)
+	return check, vpcID
+}
+
+// checkVCPUQuota confirms the account's On-Demand vCPU quota has room for the instance
+func checkVCPUQuota(sess *session.Session, ec2Client *ec2.EC2, instanceType string) models.PreflightCheck {
+	check := models.PreflightCheck{
+		Name:        "vcpu_quota",
+		Description: "The On-Demand vCPU quota has room for this instance",
+	}
+
+	quota, ok := vcpuQuotasByFamily[instanceFamilyPrefix(instanceType)]
+	if !ok {
+		return skipCheck(check, fmt.Sprintf("Unknown: no known vCPU quota for %s", instanceType),
+			"Check the instance family's On-Demand vCPU quota in the Service Quotas console")
+	}
+	check.Description = fmt.Sprintf("The %q vCPU quota has room for this instance", quota.Name)
+
+	// vCPUs needed by the new instance
+	types, err := ec2Client.DescribeInstanceTypes(&ec2.DescribeInstanceTypesInput{
+		InstanceTypes: []*string{aws.String(instanceType)},
+	})
+	if err != nil {
+		return skipCheck(check, fmt.Sprintf("Could not look up vCPUs for %s: %v", instanceType, err),
+			"Allow ec2:DescribeInstanceTypes so the quota can be verified")
+	}
+	if len(types.InstanceTypes) == 0 || types.InstanceTypes[0].VCpuInfo == nil {
+		check.Message = fmt.Sprintf("Unknown instance type %s", instanceType)
+		check.Remediation = "Check the instance type name"
+		return check
+	}
+	required := aws.Int64Value(types.InstanceTypes[0].VCpuInfo.DefaultVCpus)
+
+	// Applied quota value for the account
+	quotaOutput, err := servicequotas.New(sess).GetServiceQuota(&servicequotas.GetServiceQuotaInput{
+		ServiceCode: aws.String("ec2"),
+		QuotaCode:   aws.String(quota.Code),
+	})
+	if err != nil || quotaOutput.Quota == nil {
+		return skipCheck(check, fmt.Sprintf("Could not read quota %s: %v", quota.Code, err),
+			"Allow servicequotas:GetServiceQuota so the quota can be verified")
+	}
+	limit := int64(aws.Float64Value(quotaOutput.Quota.Value))
+
+	// vCPUs already in use by running instances counting against the same quota
+	var inUse int64
+	err = ec2Client.DescribeInstancesPages(&ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("instance-state-name"),
+				Values: []*string{aws.String("pending"), aws.String("running")},
+			},
+		},
+	}, func(page *ec2.DescribeInstancesOutput, lastPage bool) bool {
+		for _, reservation := range page.Reservations {
+			for _, instance := range reservation.Instances {
+				if vcpuQuotasByFamily[instanceFamilyPrefix(aws.StringValue(instance.InstanceType))].Code != quota.Code {
+					continue
+				}
+				if instance.CpuOptions != nil {
+					inUse += aws.Int64Value(instance.CpuOptions.CoreCount) * aws.Int64Value(instance.CpuOptions.ThreadsPerCore)
+				}
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return skipCheck(check, fmt.Sprintf("Could not count running instances: %v", err),
+			"Allow ec2:DescribeInstances so the quota can be verified")
+	}
+
+	if inUse+required > limit {
+		check.Message = fmt.Sprintf("Needs %d vCPUs, %d of %d already in use", required, inUse, limit)
+		check.Remediation = fmt.Sprintf("Request an increase for quota %s (%s) in the Service Quotas console", quota.Code, quota.Name)
+		return check
+	}
+
+	check.Passed = true
+	check.Message = fmt.Sprintf("Needs %d vCPUs, %d of %d in use", required, inUse, limit)
+	return check
+}
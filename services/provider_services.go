@@ -0,0 +1,128 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/0saurabh0/NodeEase/models"
+)
+
+// Node providers, as recorded in Node.Provider
+const (
+	providerAWS       = "AWS"
+	providerLocal     = "Local"
+	providerGCP       = "GCP"
+	providerBareMetal = "BareMetal"
+)
+
+// nodeProvider runs nodes somewhere other than EC2. EC2 nodes predate the
+// catalog and keep their own code paths in node_services.go.
+type nodeProvider struct {
+	Name        string
+	DisplayName string
+
+	// Bootstrapped is set for providers that boot a fresh machine with the
+	// rendered startup script. Their nodes get an SSH key and config revisions
+	// and can be reconfigured and upgraded like EC2 nodes.
+	Bootstrapped bool
+
+	// Validate checks the provider specific parts of a deploy request
+	Validate func(userID string, req models.NodeDeployRequest) error
+	// Preflight checks the provider can be used before anything is created
+	Preflight func(userID string, req models.NodeDeployRequest) (models.PreflightResult, error)
+	// Prepare fills in provider specific fields of a new node record, if set
+	Prepare func(node *models.Node, req models.NodeDeployRequest)
+	// Provision creates the machine for a saved node. It runs in the background
+	// and reports progress through the node's status and deployment logs.
+	// userData and publicKey are empty for providers that aren't Bootstrapped.
+	Provision func(node models.Node, req models.NodeDeployRequest, userData, publicKey string)
+
+	Start  func(node models.Node) error
+	Stop   func(node models.Node) error
+	Reboot func(node models.Node) error
+	Delete func(node models.Node) error
+}
+
+// deployStateUpdate is what a polled machine state means for a deploying node
+type deployStateUpdate struct {
+	Status   string
+	Step     string // Empty when there is nothing to log
+	Message  string
+	Progress int
+	IP       string // Set once the machine has a public IP
+	Done     bool   // Monitoring stops
+}
+
+// applyDeployState records a deployment update on a node
+func applyDeployState(nodeID string, update deployStateUpdate) {
+	if update.IP != "" {
+		updateNodeIP(nodeID, update.IP)
+		updateNodeRPCEndpoint(nodeID, fmt.Sprintf("http://%s:8899", update.IP))
+	}
+	if update.Step != "" {
+		updateNodeWithLog(nodeID, update.Status, update.Step, update.Message, update.Progress)
+	}
+}
+
+// nodeProviders maps Node.Provider to the provider running those nodes
+var nodeProviders = map[string]nodeProvider{
+	providerLocal:     localProvider,
+	providerGCP:       gcpProvider,
+	providerBareMetal: bareMetalProvider,
+}
+
+// resolveProvider returns the Node.Provider name a deploy request asks for
+func resolveProvider(name string) (string, error) {
+	if name == "" || strings.EqualFold(name, providerAWS) {
+		return providerAWS, nil
+	}
+
+	for providerName := range nodeProviders {
+		if strings.EqualFold(name, providerName) {
+			return providerName, nil
+		}
+	}
+
+	return "", fmt.Errorf("unsupported provider %q", name)
+}
+
+// UsesEC2 reports whether a deploy request targets EC2
+func UsesEC2(req models.NodeDeployRequest) bool {
+	name, err := resolveProvider(req.Provider)
+	return err == nil && name == providerAWS
+}
+
+// rejectEC2Options rejects deploy options that only exist on EC2
+func rejectEC2Options(req models.NodeDeployRequest, providerName string) error {
+	switch {
+	case req.CustomAMI != "":
+		return fmt.Errorf("custom AMIs are only supported on AWS, not %s", providerName)
+	case req.LedgerVolume != nil || req.AccountsVolume != nil:
+		return fmt.Errorf("separate data volumes are only supported on AWS, not %s", providerName)
+	case usesInstanceStore(req):
+		return fmt.Errorf("instance storage is only supported on AWS, not %s", providerName)
+	}
+	return nil
+}
+
+// requireBootstrappedNode rejects operations that need SSH access and config
+// revisions on nodes whose provider doesn't boot from the startup script
+func requireBootstrappedNode(node models.Node, action string) error {
+	if provider, ok := nodeProviders[node.Provider]; ok && !provider.Bootstrapped {
+		return fmt.Errorf("%s nodes can't be %s", provider.DisplayName, action)
+	}
+	return nil
+}
+
+// deployProviderNode saves a node and starts provisioning it with a provider
+// from the catalog
+func deployProviderNode(userID string, provider nodeProvider, req models.NodeDeployRequest) (string, error) {
+	node, rendered, publicKey, err := saveDeployingNode(userID, provider.Name, provider.Bootstrapped, req, provider.Prepare)
+	if err != nil {
+		return "", err
+	}
+
+	go provider.Provision(node, req, rendered.UserData, publicKey)
+
+	return node.ID, nil
+}
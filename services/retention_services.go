@@ -0,0 +1,264 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/0saurabh0/NodeEase/models"
+)
+
+const (
+	// ledgerShredsPerGB is roughly how many shreds fit in a GB of blockstore.
+	// Agave's default of 200M shreds keeps the blockstore under about 500 GB.
+	ledgerShredsPerGB = 400_000
+
+	// rootReservedGB is kept free on the root volume for the OS, client
+	// binaries, logs and swap
+	rootReservedGB = 30
+
+	// incrementalSnapshotGB is the space set aside per incremental snapshot
+	incrementalSnapshotGB = 2
+
+	// defaultBigTableInstance is the instance name agave uses unless told otherwise
+	defaultBigTableInstance = "solana-ledger"
+
+	// longTermStorageSecret is the node secret holding the backend credentials
+	longTermStorageSecret = "long-term-storage-credentials"
+
+	// longTermCredentialsPath is where the node writes the backend credentials
+	longTermCredentialsPath = "/home/solana/long-term-storage-credentials.json"
+)
+
+// retentionPolicy is what a history length keeps on the node
+type retentionPolicy struct {
+	MinLedgerShreds int64
+	MaxLedgerShreds int64 // 0 fills the ledger disk
+
+	FullSnapshotIntervalSlots        int64
+	IncrementalSnapshotIntervalSlots int64
+	MaxFullSnapshots                 int
+	MaxIncrementalSnapshots          int
+
+	// WantsLongTermStorage is set when the ledger alone can't hold the whole
+	// history. Without a backend the ledger keeps as much as the disk holds.
+	WantsLongTermStorage bool
+}
+
+// retentionPolicies maps history lengths to what nodes keep
+var retentionPolicies = map[string]retentionPolicy{
+	"minimal": {
+		MinLedgerShreds:                  minLedgerShreds,
+		MaxLedgerShreds:                  minLedgerShreds,
+		FullSnapshotIntervalSlots:        100_000,
+		IncrementalSnapshotIntervalSlots: 500,
+		MaxFullSnapshots:                 1,
+		MaxIncrementalSnapshots:          2,
+	},
+	"recent": {
+		MinLedgerShreds:                  100_000_000,
+		MaxLedgerShreds:                  400_000_000,
+		FullSnapshotIntervalSlots:        50_000,
+		IncrementalSnapshotIntervalSlots: 100,
+		MaxFullSnapshots:                 2,
+		MaxIncrementalSnapshots:          4,
+	},
+	"full": {
+		MinLedgerShreds:                  200_000_000,
+		FullSnapshotIntervalSlots:        50_000,
+		IncrementalSnapshotIntervalSlots: 100,
+		MaxFullSnapshots:                 2,
+		MaxIncrementalSnapshots:          4,
+		WantsLongTermStorage:             true,
+	},
+}
+
+// clusterStorage is the estimated size of a cluster's accounts and snapshots
+type clusterStorage struct {
+	AccountsGB     int
+	FullSnapshotGB int
+}
+
+// clusterStorageEstimates are rough sizes used to check disk capacity
+var clusterStorageEstimates = map[string]clusterStorage{
+	"mainnet-beta": {AccountsGB: 250, FullSnapshotGB: 80},
+	"testnet":      {AccountsGB: 150, FullSnapshotGB: 40},
+	"devnet":       {AccountsGB: 150, FullSnapshotGB: 40},
+}
+
+// privateClusterStorage is assumed for clusters without an estimate. Private
+// clusters are usually far smaller than the public ones.
+var privateClusterStorage = clusterStorage{AccountsGB: 50, FullSnapshotGB: 10}
+
+// bigTableInstancePattern matches a BigTable instance ID
+var bigTableInstancePattern = regexp.MustCompile(`^[a-z][a-z0-9-]{4,31}[a-z0-9]$`)
+
+// retentionPlan is the retention a deploy request resolves to
+type retentionPlan struct {
+	HistoryLength string
+	Policy        retentionPolicy
+	LedgerShreds  int64
+	LedgerDiskGB  int // Space left for the blockstore after everything else on its disk
+}
+
+// resolveHistoryLength applies the default history length for a node type
+func resolveHistoryLength(rpcType, historyLength string) string {
+	if historyLength != "" {
+		return historyLength
+	}
+	if rpcType == "extended" {
+		return "recent"
+	}
+	return "minimal"
+}
+
+// ledgerDiskBudget returns the GB available to the blockstore, after the OS,
+// accounts and snapshots that share its disk
+func ledgerDiskBudget(req models.NodeDeployRequest, policy retentionPolicy) int {
+	estimates, ok := clusterStorageEstimates[clusterForNetworkType(req.NetworkType)]
+	if !ok {
+		estimates = privateClusterStorage
+	}
+
+	// Snapshots are written to the ledger directory
+	budget := -(policy.MaxFullSnapshots*estimates.FullSnapshotGB + policy.MaxIncrementalSnapshots*incrementalSnapshotGB)
+
+	// Extended nodes keep accounts inside the ledger directory
+	accountsShareDisk := req.RpcType != "base"
+
+	switch {
+	case req.LedgerVolume != nil:
+		budget += req.LedgerVolume.Size
+	case req.InstanceStoreLedger:
+		info, _ := LookupInstanceType(req.InstanceType)
+		budget += info.LocalNVMeGB
+		accountsShareDisk = true
+	default:
+		budget += req.DiskSize - rootReservedGB
+		if req.AccountsVolume == nil && !req.InstanceStoreAccounts {
+			accountsShareDisk = true
+		}
+	}
+
+	if accountsShareDisk {
+		budget -= estimates.AccountsGB
+	}
+
+	return budget
+}
+
+// planRetention works out the ledger retention for a deploy request. The plan
+// is returned with an error when the disk can't hold the requested history.
+func planRetention(req models.NodeDeployRequest) (retentionPlan, error) {
+	historyLength := resolveHistoryLength(req.RpcType, req.HistoryLength)
+	policy, ok := retentionPolicies[historyLength]
+	if !ok {
+		return retentionPlan{}, fmt.Errorf("unsupported history length %q (expected minimal, recent or full)", req.HistoryLength)
+	}
+
+	plan := retentionPlan{
+		HistoryLength: historyLength,
+		Policy:        policy,
+		LedgerDiskGB:  ledgerDiskBudget(req, policy),
+	}
+
+	// Size the ledger to the disk within the policy's bounds
+	plan.LedgerShreds = int64(plan.LedgerDiskGB) * ledgerShredsPerGB
+	if policy.MaxLedgerShreds > 0 && plan.LedgerShreds > policy.MaxLedgerShreds {
+		plan.LedgerShreds = policy.MaxLedgerShreds
+	}
+
+	if plan.LedgerShreds < policy.MinLedgerShreds {
+		neededGB := int((policy.MinLedgerShreds+ledgerShredsPerGB-1)/ledgerShredsPerGB) - plan.LedgerDiskGB
+		return plan, fmt.Errorf("%s history needs about %d GB more disk for the ledger: %d GB is left after the OS, accounts and snapshots but at least %d GB is needed",
+			historyLength, neededGB, max(plan.LedgerDiskGB, 0), plan.LedgerDiskGB+neededGB)
+	}
+
+	return plan, nil
+}
+
+// applyRetentionPlan sets the ledger and snapshot settings from a plan
+func applyRetentionPlan(settings models.ValidatorSettings, plan retentionPlan) models.ValidatorSettings {
+	settings.LimitLedger = true
+	settings.LimitLedgerShreds = plan.LedgerShreds
+	settings.FullSnapshotIntervalSlots = plan.Policy.FullSnapshotIntervalSlots
+	settings.IncrementalSnapshotIntervalSlots = plan.Policy.IncrementalSnapshotIntervalSlots
+	settings.MaxFullSnapshots = plan.Policy.MaxFullSnapshots
+	settings.MaxIncrementalSnapshots = plan.Policy.MaxIncrementalSnapshots
+	return settings
+}
+
+// applyLongTermStorage enables the long-term history backend in the settings
+func applyLongTermStorage(settings models.ValidatorSettings, spec *models.LongTermStorageSpec) models.ValidatorSettings {
+	if spec == nil {
+		return settings
+	}
+
+	settings.BigTableLedgerStorage = true
+	settings.BigTableInstanceName = spec.InstanceName
+	if settings.BigTableInstanceName == "" {
+		settings.BigTableInstanceName = defaultBigTableInstance
+	}
+	settings.BigTableTimeout = spec.Timeout
+	return settings
+}
+
+// validateLongTermStorage checks the long-term history backend of a request
+func validateLongTermStorage(spec *models.LongTermStorageSpec) error {
+	if spec.Backend != "bigtable" {
+		return fmt.Errorf("unsupported long-term storage backend %q (expected bigtable)", spec.Backend)
+	}
+	if spec.InstanceName != "" && !bigTableInstancePattern.MatchString(spec.InstanceName) {
+		return fmt.Errorf("invalid BigTable instance name %q", spec.InstanceName)
+	}
+	if spec.Timeout < 0 || spec.Timeout > 3600 {
+		return fmt.Errorf("long-term storage timeout must be at most 3600 seconds")
+	}
+
+	// Credentials are a service account key
+	var key map[string]interface{}
+	if strings.TrimSpace(spec.Credentials) == "" {
+		return fmt.Errorf("long-term storage credentials are required")
+	}
+	if err := json.Unmarshal([]byte(spec.Credentials), &key); err != nil {
+		return fmt.Errorf("long-term storage credentials must be a JSON service account key")
+	}
+
+	return nil
+}
+
+// validateRetention checks the history length, long-term storage and that the
+// disk can hold the requested retention
+func validateRetention(req models.NodeDeployRequest) error {
+	if req.LongTermStorage != nil {
+		if err := validateLongTermStorage(req.LongTermStorage); err != nil {
+			return err
+		}
+	}
+
+	_, err := planRetention(req)
+	return err
+}
+
+// RetentionWarning explains when a deploy keeps less history than requested.
+// It returns an empty string otherwise.
+func RetentionWarning(req models.NodeDeployRequest) string {
+	plan, err := planRetention(req)
+	if err != nil || !plan.Policy.WantsLongTermStorage || req.LongTermStorage != nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%s history has no long-term storage backend, so the ledger keeps only what fits on the disk (about %d GB)",
+		plan.HistoryLength, plan.LedgerDiskGB)
+}
+
+// redactDeployRequest returns a copy of the request without secrets, for storing
+func redactDeployRequest(req models.NodeDeployRequest) models.NodeDeployRequest {
+	if req.LongTermStorage != nil {
+		spec := *req.LongTermStorage
+		spec.Credentials = ""
+		req.LongTermStorage = &spec
+	}
+	return req
+}
@@ -0,0 +1,346 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/0saurabh0/NodeEase/db/repository"
+	"github.com/0saurabh0/NodeEase/models"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// bootstrapRerunTimeout bounds running the startup script again over SSH.
+	// Building Firedancer from source alone can take most of an hour.
+	bootstrapRerunTimeout = 2 * time.Hour
+
+	// bootstrapExitBusy means the startup script is still running from an
+	// earlier attempt, so the rerun didn't start
+	bootstrapExitBusy = 75
+
+	// sshReadyAttempts and sshReadyInterval bound waiting for SSH on a
+	// machine that just booted
+	sshReadyAttempts = 20
+	sshReadyInterval = 15 * time.Second
+)
+
+// RetryNodeDeployment retries a failed or stalled deploy as a new attempt and
+// returns the attempt number. The key pair, security group and machine of the
+// earlier attempt are reused: a machine that is still there runs the startup
+// script again over SSH, otherwise the remaining provisioning steps are run.
+func RetryNodeDeployment(nodeID, userID string) (int, error) {
+	// Get node details
+	node, err := repository.GetNodeByID(nodeID, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Only proceed if this is the user's node
+	if node.UserID != userID {
+		return 0, fmt.Errorf("node not found or you don't have permission")
+	}
+
+	if err := requireBootstrappedNode(node, "retried"); err != nil {
+		return 0, err
+	}
+	if !retryableDeploy(node) {
+		return 0, fmt.Errorf("only failed or stalled deployments can be retried, node is %s", node.Status)
+	}
+
+	// Render the startup script from the configuration the node runs
+	cfg, err := currentNodeConfig(node)
+	if err != nil {
+		return 0, err
+	}
+	rendered, err := RenderNodeScripts(cfg)
+	if err != nil {
+		return 0, err
+	}
+
+	// Work out what is left to do before anything changes
+	var resume func()
+	if provider, ok := nodeProviders[node.Provider]; ok {
+		resume, err = planProviderRetry(node, provider, rendered.UserData)
+	} else {
+		resume, err = planEC2Retry(node, rendered.UserData)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	attempt, err := startDeployAttempt(nodeID)
+	if err != nil {
+		return 0, err
+	}
+
+	go resume()
+
+	return attempt, nil
+}
+
+// retryableDeploy reports whether a node's deploy failed or stopped making progress
+func retryableDeploy(node models.Node) bool {
+	return node.Status == "failed" || (node.Status == "deploying" && node.StalledStep != "")
+}
+
+// startDeployAttempt moves a node on to its next deploy attempt, so logs
+// from here on are recorded under the new attempt number
+func startDeployAttempt(nodeID string) (int, error) {
+	// Serialized with VM callbacks, which may change the status meanwhile
+	unlock := lockNodeStatus(nodeID)
+	node, err := repository.GetNodeByIDInternal(nodeID)
+	if err == nil && !retryableDeploy(node) {
+		err = fmt.Errorf("node is %s and can no longer be retried", node.Status)
+	}
+	if err == nil {
+		node.DeployAttempt++
+		node.Status = "deploying"
+		node.StalledStep = ""
+		node.UpdatedAt = time.Now()
+		err = repository.SaveNode(node)
+	}
+	unlock()
+
+	if err != nil {
+		return 0, err
+	}
+
+	updateNodeWithLog(nodeID, "deploying", "retry", fmt.Sprintf("Retrying deployment, attempt %d", node.DeployAttempt), 0)
+
+	return node.DeployAttempt, nil
+}
+
+// planEC2Retry returns how an EC2 deploy is resumed. Without a live instance
+// a new one is launched with the node's key pair and security group.
+func planEC2Retry(node models.Node, userData string) (func(), error) {
+	sess, err := GetAWSSessionForRegion(node.UserID, node.Region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get AWS session: %v", err)
+	}
+	ec2Client := ec2.New(sess)
+
+	var instance *ec2.Instance
+	if node.InstanceID != "" {
+		instance, err = describeNodeInstance(ec2Client, node.InstanceID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The instance is still there, so the startup script runs again on it
+	if instance != nil {
+		state := aws.StringValue(instance.State.Name)
+		if state != "terminated" && state != "shutting-down" {
+			return func() {
+				if err := waitForRetryInstance(ec2Client, node.ID, node.InstanceID, state); err != nil {
+					updateNodeWithLog(node.ID, "failed", "error", err.Error(), 0)
+					return
+				}
+				rerunBootstrap(node.ID, userData)
+			}, nil
+		}
+	}
+
+	req, err := recordedDeployRequest(node)
+	if err != nil {
+		return nil, err
+	}
+	imageID, err := resolveSolanaAMI(sess, req.CustomAMI, req.OSRelease, req.Architecture)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve AMI: %v", err)
+	}
+	publicKey, err := nodePublicKey(node)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		if err := resetNodeMachine(node.ID); err != nil {
+			updateNodeStatus(node.ID, "failed", fmt.Sprintf("Failed to reset node: %v", err))
+			return
+		}
+		provisionEC2Node(node, ec2Client, req, imageID, userData, publicKey)
+	}, nil
+}
+
+// planProviderRetry returns how a deploy on a catalog provider is resumed. A
+// machine that was never created is provisioned again.
+func planProviderRetry(node models.Node, provider nodeProvider, userData string) (func(), error) {
+	if node.InstanceID != "" {
+		if node.IPAddress == "" {
+			return nil, fmt.Errorf("the %s machine has no IP address yet, retry once it's up", provider.DisplayName)
+		}
+		return func() {
+			rerunBootstrap(node.ID, userData)
+		}, nil
+	}
+
+	req, err := recordedDeployRequest(node)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := nodePublicKey(node)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		if err := resetNodeMachine(node.ID); err != nil {
+			updateNodeStatus(node.ID, "failed", fmt.Sprintf("Failed to reset node: %v", err))
+			return
+		}
+		provider.Provision(node, req, userData, publicKey)
+	}, nil
+}
+
+// recordedDeployRequest returns the deploy request stored with a node's first
+// config revision
+func recordedDeployRequest(node models.Node) (models.NodeDeployRequest, error) {
+	revision, err := repository.GetNodeConfigRevision(node.ID, node.UserID, 1)
+	if err != nil {
+		return models.NodeDeployRequest{}, fmt.Errorf("failed to load deploy request: %v", err)
+	}
+	if revision.DeployRequest == nil {
+		return models.NodeDeployRequest{}, fmt.Errorf("the deploy request of this node wasn't recorded, delete it and deploy again")
+	}
+	return *revision.DeployRequest, nil
+}
+
+// nodePublicKey derives the authorized_keys line of a node's SSH key
+func nodePublicKey(node models.Node) (string, error) {
+	signer, err := ssh.ParsePrivateKey([]byte(node.SshPrivateKey))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse node SSH key: %v", err)
+	}
+	return string(ssh.MarshalAuthorizedKey(signer.PublicKey())), nil
+}
+
+// resetNodeMachine forgets the machine of an earlier attempt before a new one
+// is provisioned, including its pinned SSH host key
+func resetNodeMachine(nodeID string) error {
+	node, err := repository.GetNodeByIDInternal(nodeID)
+	if err != nil {
+		return err
+	}
+
+	node.InstanceID = ""
+	node.IPAddress = ""
+	node.RpcEndpoint = ""
+	node.WsEndpoint = ""
+	node.LedgerVolumeID = ""
+	node.AccountsVolumeID = ""
+	node.UpdatedAt = time.Now()
+	if err := repository.SaveNode(node); err != nil {
+		return err
+	}
+
+	return repository.SaveNodeSSHHostKey(nodeID, "")
+}
+
+// describeNodeInstance returns a node's EC2 instance, or nil if it no longer exists
+func describeNodeInstance(ec2Client *ec2.EC2, instanceID string) (*ec2.Instance, error) {
+	result, err := ec2Client.DescribeInstances(&ec2.DescribeInstancesInput{
+		InstanceIds: []*string{aws.String(instanceID)},
+	})
+	if err != nil {
+		if isAWSErrorCode(err, "InvalidInstanceID.NotFound") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get instance status: %v", err)
+	}
+
+	if len(result.Reservations) == 0 || len(result.Reservations[0].Instances) == 0 {
+		return nil, nil
+	}
+	return result.Reservations[0].Instances[0], nil
+}
+
+// waitForRetryInstance brings the instance of a retried deploy to running,
+// starting it if it was stopped, and records its current IP address
+func waitForRetryInstance(ec2Client *ec2.EC2, nodeID, instanceID, state string) error {
+	input := &ec2.DescribeInstancesInput{InstanceIds: []*string{aws.String(instanceID)}}
+
+	if state == "stopping" {
+		if err := ec2Client.WaitUntilInstanceStopped(input); err != nil {
+			return fmt.Errorf("instance did not stop: %v", err)
+		}
+		state = "stopped"
+	}
+	if state == "stopped" {
+		updateNodeWithLog(nodeID, "deploying", "provision", "Starting the stopped EC2 instance...", 5)
+		_, err := ec2Client.StartInstances(&ec2.StartInstancesInput{
+			InstanceIds: []*string{aws.String(instanceID)},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to start instance: %v", err)
+		}
+	}
+
+	if err := ec2Client.WaitUntilInstanceRunning(input); err != nil {
+		return fmt.Errorf("instance did not reach running: %v", err)
+	}
+
+	instance, err := describeNodeInstance(ec2Client, instanceID)
+	if err != nil {
+		return err
+	}
+	if instance == nil || instance.PublicIpAddress == nil {
+		return fmt.Errorf("instance has no public IP address")
+	}
+
+	// The address changes when a stopped instance is started
+	updateNodeIP(nodeID, *instance.PublicIpAddress)
+	updateNodeRPCEndpoint(nodeID, fmt.Sprintf("http://%s:8899", *instance.PublicIpAddress))
+
+	return nil
+}
+
+// rerunBootstrap runs the startup script on a node's machine again over SSH.
+// Progress is reported by the script itself; only a run that didn't finish
+// cleanly is recorded here.
+func rerunBootstrap(nodeID, userData string) {
+	node, err := repository.GetNodeByIDInternal(nodeID)
+	if err != nil {
+		updateNodeStatus(nodeID, "failed", fmt.Sprintf("Failed to load node: %v", err))
+		return
+	}
+
+	updateNodeWithLog(nodeID, "deploying", "vm_ready", "Running the startup script again over SSH...", 15)
+
+	if err := waitForNodeSSH(node); err != nil {
+		updateNodeWithLog(nodeID, "failed", "error", err.Error(), 0)
+		return
+	}
+
+	output, exitCode, err := runNodeScript(node, userData, bootstrapRerunTimeout)
+	switch {
+	case err != nil:
+		updateNodeWithLog(nodeID, "failed", "error", fmt.Sprintf("Startup script did not complete: %v\n%s", err, tailLines(output, 20)), 0)
+	case exitCode == bootstrapExitBusy:
+		// The earlier run keeps reporting progress, or the watchdog fails it
+		updateNodeWithLog(nodeID, "deploying", "retry", "The startup script of an earlier attempt is still running", 15)
+	case exitCode != 0:
+		updateNodeWithLog(nodeID, "failed", "error", fmt.Sprintf("Startup script exited with code %d:\n%s", exitCode, tailLines(output, 20)), 0)
+	}
+}
+
+// waitForNodeSSH waits until a machine that just booted accepts SSH connections
+func waitForNodeSSH(node models.Node) error {
+	var err error
+	for i := 0; i < sshReadyAttempts; i++ {
+		var client *ssh.Client
+		if client, err = dialNode(node); err == nil {
+			client.Close()
+			return nil
+		}
+		// A changed host key won't go away by waiting
+		if strings.Contains(err.Error(), "host key") {
+			return err
+		}
+		time.Sleep(sshReadyInterval)
+	}
+	return err
+}
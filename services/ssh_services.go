@@ -0,0 +1,120 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/0saurabh0/NodeEase/db/repository"
+	"github.com/0saurabh0/NodeEase/models"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// nodeSSHUser is the default user on the Ubuntu AMIs nodes run
+	nodeSSHUser = "ubuntu"
+
+	// sshDialTimeout bounds connecting to a node
+	sshDialTimeout = 15 * time.Second
+)
+
+// nodeHostKeyCallback pins a node's SSH host key the first time NodeEase
+// connects and rejects any different key afterwards
+func nodeHostKeyCallback(nodeID string) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		presented := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+
+		known, err := repository.GetNodeSSHHostKey(nodeID)
+		if err != nil {
+			return fmt.Errorf("failed to load host key: %v", err)
+		}
+
+		if known == "" {
+			return repository.SaveNodeSSHHostKey(nodeID, presented)
+		}
+
+		if known != presented {
+			return fmt.Errorf("host key for node %s changed, refusing to connect", nodeID)
+		}
+
+		return nil
+	}
+}
+
+// dialNode opens an SSH connection to a node with its deployment key
+func dialNode(node models.Node) (*ssh.Client, error) {
+	if node.IPAddress == "" {
+		return nil, fmt.Errorf("node has no IP address")
+	}
+	if node.SshPrivateKey == "" {
+		return nil, fmt.Errorf("no SSH key available for this node")
+	}
+
+	signer, err := ssh.ParsePrivateKey([]byte(node.SshPrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse node SSH key: %v", err)
+	}
+
+	client, err := ssh.Dial("tcp", net.JoinHostPort(node.IPAddress, "22"), &ssh.ClientConfig{
+		User:            nodeSSHUser,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: nodeHostKeyCallback(node.ID),
+		Timeout:         sshDialTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to node over SSH: %v", err)
+	}
+
+	return client, nil
+}
+
+// runNodeScript runs a bash script as root on a node and returns its combined
+// output and exit code. An error is only returned when the script couldn't run
+// to completion.
+func runNodeScript(node models.Node, script string, timeout time.Duration) (string, int, error) {
+	client, err := dialNode(node)
+	if err != nil {
+		return "", 0, err
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to open SSH session: %v", err)
+	}
+	defer session.Close()
+
+	var output bytes.Buffer
+	session.Stdout = &output
+	session.Stderr = &output
+	session.Stdin = strings.NewReader(script)
+
+	// Close the connection if the script overruns its timeout
+	timer := time.AfterFunc(timeout, func() {
+		client.Close()
+	})
+	defer timer.Stop()
+
+	err = session.Run("sudo bash -s")
+	if err != nil {
+		var exitErr *ssh.ExitError
+		if errors.As(err, &exitErr) {
+			return output.String(), exitErr.ExitStatus(), nil
+		}
+		return output.String(), 0, fmt.Errorf("script did not complete: %v", err)
+	}
+
+	return output.String(), 0, nil
+}
+
+// tailLines returns the last n lines of output
+func tailLines(output string, n int) string {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
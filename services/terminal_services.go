@@ -0,0 +1,376 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/0saurabh0/NodeEase/db/repository"
+	"github.com/0saurabh0/NodeEase/models"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// maxTranscriptBytes bounds the output kept of a recorded session
+	maxTranscriptBytes = 1024 * 1024
+
+	// terminalSessionHistory is how many audit records are listed per node
+	terminalSessionHistory = 50
+
+	// Terminal sizes browsers may ask for
+	defaultTerminalCols = 80
+	defaultTerminalRows = 24
+	maxTerminalCols     = 1000
+	maxTerminalRows     = 500
+)
+
+// terminalLimit is what a role may do with browser terminals
+type terminalLimit struct {
+	Sessions   int           // Open sessions at once
+	Duration   time.Duration // Before a session is closed
+	Transcript bool          // Output is recorded in the audit record
+}
+
+// terminalRoleLimits are keyed by the role of the user opening the terminal
+var terminalRoleLimits = map[string]terminalLimit{
+	models.RoleUser:  {Sessions: 2, Duration: time.Hour, Transcript: true},
+	models.RoleAdmin: {Sessions: 10, Duration: 8 * time.Hour},
+}
+
+// Open terminal sessions per user
+var (
+	terminalSessionCounts   = map[string]int{}
+	terminalSessionCountsMu sync.Mutex
+)
+
+// acquireTerminalSession counts a new session against a user's limit
+func acquireTerminalSession(userID string, limit terminalLimit) error {
+	terminalSessionCountsMu.Lock()
+	defer terminalSessionCountsMu.Unlock()
+
+	if terminalSessionCounts[userID] >= limit.Sessions {
+		return fmt.Errorf("at most %d terminal sessions can be open at once", limit.Sessions)
+	}
+	terminalSessionCounts[userID]++
+	return nil
+}
+
+// releaseTerminalSession returns a session to a user's limit
+func releaseTerminalSession(userID string) {
+	terminalSessionCountsMu.Lock()
+	defer terminalSessionCountsMu.Unlock()
+
+	if terminalSessionCounts[userID]--; terminalSessionCounts[userID] <= 0 {
+		delete(terminalSessionCounts, userID)
+	}
+}
+
+// NodeTerminal is an interactive shell on a node, relayed to a browser
+type NodeTerminal struct {
+	client   *ssh.Client
+	session  *ssh.Session
+	stdin    io.WriteCloser
+	stdout   io.Reader
+	duration time.Duration
+
+	mu         sync.Mutex // Guards the audit record and transcript
+	audit      models.NodeTerminalSession
+	transcript bytes.Buffer
+
+	closeOnce sync.Once
+}
+
+// OpenNodeTerminal starts a shell with a PTY on a node over SSH and records
+// the start of the session. The session counts against the user's limit
+// until it's closed.
+func OpenNodeTerminal(nodeID, userID, role, remoteAddr string, req models.NodeTerminalRequest) (*NodeTerminal, error) {
+	node, err := repository.GetNodeByID(nodeID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if node.ID == "" {
+		return nil, fmt.Errorf("node not found or you don't have permission")
+	}
+	if err := requireBootstrappedNode(node, "opened in a terminal"); err != nil {
+		return nil, err
+	}
+
+	limit, ok := terminalRoleLimits[role]
+	if !ok {
+		return nil, fmt.Errorf("role %q can't open terminals", role)
+	}
+
+	cols, rows, err := terminalSize(req.Cols, req.Rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := acquireTerminalSession(userID, limit); err != nil {
+		return nil, err
+	}
+
+	terminal, err := startNodeShell(node, cols, rows)
+	if err != nil {
+		releaseTerminalSession(userID)
+		return nil, err
+	}
+	terminal.duration = limit.Duration
+
+	terminal.audit = models.NodeTerminalSession{
+		ID:          uuid.New().String(),
+		NodeID:      node.ID,
+		NodeOwnerID: node.UserID,
+		UserID:      userID,
+		Role:        role,
+		RemoteAddr:  remoteAddr,
+		StartedAt:   time.Now(),
+		Transcript:  limit.Transcript,
+	}
+	if err := repository.SaveTerminalSession(terminal.audit); err != nil {
+		terminal.client.Close()
+		releaseTerminalSession(userID)
+		return nil, fmt.Errorf("failed to record terminal session: %v", err)
+	}
+
+	return terminal, nil
+}
+
+// terminalSize checks the size a browser asked for, filling in defaults
+func terminalSize(cols, rows int) (int, int, error) {
+	if cols == 0 {
+		cols = defaultTerminalCols
+	}
+	if rows == 0 {
+		rows = defaultTerminalRows
+	}
+	if cols < 1 || cols > maxTerminalCols || rows < 1 || rows > maxTerminalRows {
+		return 0, 0, fmt.Errorf("terminal size must be at most %dx%d", maxTerminalCols, maxTerminalRows)
+	}
+	return cols, rows, nil
+}
+
+// startNodeShell opens an SSH connection to a node and starts a login shell
+// on a PTY of the given size
+func startNodeShell(node models.Node, cols, rows int) (*NodeTerminal, error) {
+	client, err := dialNode(node)
+	if err != nil {
+		return nil, err
+	}
+
+	terminal, err := startShell(client, cols, rows)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return terminal, nil
+}
+
+// startShell starts a login shell on a PTY over an SSH connection
+func startShell(client *ssh.Client, cols, rows int) (*NodeTerminal, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SSH session: %v", err)
+	}
+
+	modes := ssh.TerminalModes{
+		ssh.ECHO:          1,
+		ssh.TTY_OP_ISPEED: 14400,
+		ssh.TTY_OP_OSPEED: 14400,
+	}
+	if err := session.RequestPty("xterm-256color", rows, cols, modes); err != nil {
+		return nil, fmt.Errorf("failed to request PTY: %v", err)
+	}
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open terminal input: %v", err)
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open terminal output: %v", err)
+	}
+	// With a PTY the shell's stderr arrives on stdout, anything else is dropped
+	session.Stderr = io.Discard
+
+	if err := session.Shell(); err != nil {
+		return nil, fmt.Errorf("failed to start shell: %v", err)
+	}
+
+	return &NodeTerminal{
+		client:  client,
+		session: session,
+		stdin:   stdin,
+		stdout:  stdout,
+	}, nil
+}
+
+// Serve relays the terminal to a WebSocket until the shell exits, the browser
+// disconnects or the session reaches its role's maximum duration
+func (t *NodeTerminal) Serve(ws *websocket.Conn) {
+	timer := time.AfterFunc(t.duration, func() {
+		t.end("timeout")
+	})
+	defer timer.Stop()
+
+	go t.relayInput(ws)
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := t.stdout.Read(buf)
+		if n > 0 {
+			t.recordOutput(buf[:n])
+
+			ws.SetWriteDeadline(time.Now().Add(agentWriteTimeout))
+			if err := websocket.JSON.Send(ws, models.TerminalMessage{Type: models.TerminalOutput, Data: buf[:n]}); err != nil {
+				t.end("closed")
+				return
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	// The browser may be gone already
+	ws.SetWriteDeadline(time.Now().Add(agentWriteTimeout))
+	websocket.JSON.Send(ws, models.TerminalMessage{Type: models.TerminalExit})
+	t.end("exited")
+}
+
+// relayInput passes keystrokes and resize events from the browser to the shell
+func (t *NodeTerminal) relayInput(ws *websocket.Conn) {
+	defer t.end("closed")
+
+	for {
+		var message models.TerminalMessage
+		if err := websocket.JSON.Receive(ws, &message); err != nil {
+			return
+		}
+
+		switch message.Type {
+		case models.TerminalInput:
+			t.mu.Lock()
+			t.audit.BytesIn += int64(len(message.Data))
+			t.mu.Unlock()
+
+			if _, err := t.stdin.Write(message.Data); err != nil {
+				return
+			}
+		case models.TerminalResize:
+			cols, rows, err := terminalSize(message.Cols, message.Rows)
+			if err != nil {
+				continue
+			}
+			if err := t.session.WindowChange(rows, cols); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// recordOutput counts shell output and adds it to the transcript if one is kept
+func (t *NodeTerminal) recordOutput(output []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.audit.BytesOut += int64(len(output))
+	if !t.audit.Transcript || t.audit.TranscriptTruncated {
+		return
+	}
+
+	if room := maxTranscriptBytes - t.transcript.Len(); len(output) > room {
+		output = output[:room]
+		t.audit.TranscriptTruncated = true
+	}
+	t.transcript.Write(output)
+}
+
+// Close ends the session if it's still open
+func (t *NodeTerminal) Close() {
+	t.end("closed")
+}
+
+// end closes the session and records why. Only the first reason is kept.
+func (t *NodeTerminal) end(reason string) {
+	t.closeOnce.Do(func() {
+		t.client.Close()
+
+		t.mu.Lock()
+		audit := t.audit
+		var transcript []byte
+		if audit.Transcript {
+			transcript = append([]byte{}, t.transcript.Bytes()...)
+		}
+		t.mu.Unlock()
+
+		releaseTerminalSession(audit.UserID)
+
+		now := time.Now()
+		audit.EndedAt = &now
+		audit.EndReason = reason
+		if err := repository.FinishTerminalSession(audit, transcript); err != nil {
+			log.Printf("Failed to record end of terminal session %s: %v", audit.ID, err)
+		}
+	})
+}
+
+// terminalAuditOwner returns the owner to look up a node's terminal sessions
+// by. It's empty for a node the user owns, whose sessions are all theirs to
+// see. Audit records outlive their node, so for a deleted node it's the user,
+// matching the sessions recorded as theirs.
+func terminalAuditOwner(nodeID, userID string) (string, error) {
+	node, err := repository.GetNodeByID(nodeID, userID)
+	if err != nil {
+		return "", err
+	}
+	if node.ID != "" {
+		return "", nil
+	}
+	return userID, nil
+}
+
+// ListNodeTerminalSessions lists the recent terminal sessions on a node,
+// including one that has since been deleted
+func ListNodeTerminalSessions(nodeID, userID string) ([]models.NodeTerminalSession, error) {
+	ownerID, err := terminalAuditOwner(nodeID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := repository.GetTerminalSessions(nodeID, ownerID, terminalSessionHistory)
+	if err != nil {
+		return nil, err
+	}
+	if ownerID != "" && len(sessions) == 0 {
+		return nil, fmt.Errorf("node not found or you don't have permission")
+	}
+
+	return sessions, nil
+}
+
+// GetNodeTerminalTranscript retrieves the recorded output of a terminal
+// session, including one on a node that has since been deleted
+func GetNodeTerminalTranscript(sessionID, nodeID, userID string) ([]byte, error) {
+	ownerID, err := terminalAuditOwner(nodeID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	transcript, found, err := repository.GetTerminalTranscript(sessionID, nodeID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("terminal session not found")
+	}
+	if transcript == nil {
+		return nil, fmt.Errorf("no transcript was recorded for this session")
+	}
+
+	return transcript, nil
+}
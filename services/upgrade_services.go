@@ -0,0 +1,399 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0saurabh0/NodeEase/db/repository"
+	"github.com/0saurabh0/NodeEase/models"
+	"github.com/google/uuid"
+)
+
+const (
+	// defaultHealthTimeout is how long an upgraded node gets to catch up
+	defaultHealthTimeout = 45 * time.Minute
+
+	// restartWindowTimeout bounds the wait for a snapshot and a safe restart point
+	restartWindowTimeout = 60 * time.Minute
+
+	// upgradeOutputLines is how much script output is kept with an upgrade record
+	upgradeOutputLines = 40
+)
+
+// Upgrade script exit codes, see templates/upgrade.sh.tmpl
+const (
+	upgradeExitAborted        = 2
+	upgradeExitRolledBack     = 3
+	upgradeExitRollbackFailed = 4
+)
+
+// upgradeTemplateData is what the upgrade script template is executed with
+type upgradeTemplateData struct {
+	Client               validatorClient
+	FromVersion          string
+	ToVersion            string
+	InstallURL           string
+	ReleaseLink          string
+	BinaryPath           string
+	HealthTimeout        int
+	RestartWindowTimeout int
+}
+
+// renderUpgradeScript renders the script that moves a node between client versions
+func renderUpgradeScript(client validatorClient, fromVersion, toVersion string, healthTimeout time.Duration) (string, error) {
+	data := upgradeTemplateData{
+		Client:               client,
+		FromVersion:          fromVersion,
+		ToVersion:            toVersion,
+		ReleaseLink:          client.ReleaseLink,
+		BinaryPath:           strings.TrimPrefix(strings.TrimPrefix(client.InstallDir, client.ReleaseLink)+"/bin/"+client.BinaryName, "/"),
+		HealthTimeout:        int(healthTimeout.Seconds()),
+		RestartWindowTimeout: int(restartWindowTimeout.Seconds()),
+	}
+	if client.InstallURL != nil {
+		data.InstallURL = client.InstallURL(toVersion)
+	}
+
+	var script bytes.Buffer
+	if err := bootstrapTemplates.ExecuteTemplate(&script, "upgrade.sh.tmpl", data); err != nil {
+		return "", fmt.Errorf("failed to render upgrade script: %v", err)
+	}
+
+	return script.String(), nil
+}
+
+// healthTimeoutFromMinutes applies the default when no timeout was requested
+func healthTimeoutFromMinutes(minutes int) time.Duration {
+	if minutes <= 0 {
+		return defaultHealthTimeout
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// newNodeUpgrade validates that a node can move to a version and returns the
+// pending upgrade record for it
+func newNodeUpgrade(node models.Node, targetVersion, rolloutID string) (models.NodeUpgrade, error) {
+	if err := requireBootstrappedNode(node, "upgraded"); err != nil {
+		return models.NodeUpgrade{}, err
+	}
+	if node.Status != "running" {
+		return models.NodeUpgrade{}, fmt.Errorf("node %s is %s, only running nodes can be upgraded", node.Name, node.Status)
+	}
+
+	if _, _, err := resolveClient(node.Client, targetVersion); err != nil {
+		return models.NodeUpgrade{}, err
+	}
+
+	if node.ClientVersion == targetVersion {
+		return models.NodeUpgrade{}, fmt.Errorf("node %s already runs %s", node.Name, targetVersion)
+	}
+
+	now := time.Now()
+	return models.NodeUpgrade{
+		ID:          uuid.New().String(),
+		NodeID:      node.ID,
+		UserID:      node.UserID,
+		RolloutID:   rolloutID,
+		Client:      node.Client,
+		FromVersion: node.ClientVersion,
+		ToVersion:   targetVersion,
+		Status:      "pending",
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}, nil
+}
+
+// createNodeUpgrade stores a new upgrade record. The database refuses a second
+// active upgrade for a node.
+func createNodeUpgrade(upgrade models.NodeUpgrade, node models.Node) error {
+	created, err := repository.CreateNodeUpgrade(upgrade)
+	if err != nil {
+		return fmt.Errorf("failed to save upgrade: %v", err)
+	}
+	if !created {
+		return fmt.Errorf("node %s already has an upgrade in progress", node.Name)
+	}
+	return nil
+}
+
+// saveUpgradeStatus updates the status of an upgrade record
+func saveUpgradeStatus(upgrade *models.NodeUpgrade, status, message string) {
+	upgrade.Status = status
+	upgrade.Message = message
+	upgrade.UpdatedAt = time.Now()
+
+	if err := repository.SaveNodeUpgrade(*upgrade); err != nil {
+		log.Printf("Failed to save upgrade %s: %v", upgrade.ID, err)
+	}
+}
+
+// runNodeUpgrade carries out an upgrade on the node and records the outcome.
+// It reports whether the node ended up on the target version.
+func runNodeUpgrade(upgrade *models.NodeUpgrade, healthTimeout time.Duration) bool {
+	node, err := repository.GetNodeByIDInternal(upgrade.NodeID)
+	if err != nil || node.ID == "" {
+		saveUpgradeStatus(upgrade, "failed", "Node not found")
+		return false
+	}
+
+	client, _, err := resolveClient(upgrade.Client, upgrade.ToVersion)
+	if err != nil {
+		saveUpgradeStatus(upgrade, "failed", err.Error())
+		return false
+	}
+
+	script, err := renderUpgradeScript(client, upgrade.FromVersion, upgrade.ToVersion, healthTimeout)
+	if err != nil {
+		saveUpgradeStatus(upgrade, "failed", err.Error())
+		return false
+	}
+
+	saveUpgradeStatus(upgrade, "running", fmt.Sprintf("Upgrading %s from %s to %s", client.DisplayName, upgrade.FromVersion, upgrade.ToVersion))
+	updateNodeWithLog(node.ID, node.Status, "upgrade", upgrade.Message, 0)
+
+	// Installing, waiting for a restart window and catching up can all take a while
+	output, exitCode, err := execNodeScript(node, models.AgentCommandUpgrade, script, restartWindowTimeout+2*healthTimeout+30*time.Minute)
+	upgrade.Output = tailLines(output, upgradeOutputLines)
+	if err != nil {
+		saveUpgradeStatus(upgrade, "failed", err.Error())
+		updateNodeWithLog(node.ID, node.Status, "upgrade", "Upgrade failed: "+err.Error(), 0)
+		return false
+	}
+
+	switch exitCode {
+	case 0:
+		// Record the new version on the node
+		current, err := repository.GetNodeByIDInternal(upgrade.NodeID)
+		if err == nil {
+			current.ClientVersion = upgrade.ToVersion
+			current.UpdatedAt = time.Now()
+			err = repository.SaveNode(current)
+		}
+		if err != nil {
+			log.Printf("Failed to record client version for node %s: %v", upgrade.NodeID, err)
+		}
+		saveUpgradeStatus(upgrade, "succeeded", fmt.Sprintf("Node is healthy on %s", upgrade.ToVersion))
+		updateNodeWithLog(node.ID, node.Status, "upgrade", upgrade.Message, 100)
+		return true
+	case upgradeExitAborted:
+		saveUpgradeStatus(upgrade, "failed", "Upgrade aborted before restarting, the node still runs "+upgrade.FromVersion)
+	case upgradeExitRolledBack:
+		saveUpgradeStatus(upgrade, "rolled_back", fmt.Sprintf("Node did not recover on %s and was rolled back to %s", upgrade.ToVersion, upgrade.FromVersion))
+	case upgradeExitRollbackFailed:
+		saveUpgradeStatus(upgrade, "failed", "Node did not recover on either version")
+		updateNodeStatus(node.ID, "failed", upgrade.Message)
+		return false
+	default:
+		saveUpgradeStatus(upgrade, "failed", fmt.Sprintf("Upgrade script exited with code %d", exitCode))
+	}
+
+	updateNodeWithLog(node.ID, node.Status, "upgrade", upgrade.Message, 0)
+	return false
+}
+
+// UpgradeNode starts moving a single node to another version of its client
+func UpgradeNode(nodeID, userID string, req models.NodeUpgradeRequest) (models.NodeUpgrade, error) {
+	node, err := repository.GetNodeByID(nodeID, userID)
+	if err != nil {
+		return models.NodeUpgrade{}, err
+	}
+	if node.ID == "" {
+		return models.NodeUpgrade{}, fmt.Errorf("node not found or you don't have permission")
+	}
+
+	upgrade, err := newNodeUpgrade(node, req.TargetVersion, "")
+	if err != nil {
+		return models.NodeUpgrade{}, err
+	}
+
+	if err := createNodeUpgrade(upgrade, node); err != nil {
+		return models.NodeUpgrade{}, err
+	}
+
+	// The goroutine updates its own copy of the record
+	running := upgrade
+	go runNodeUpgrade(&running, healthTimeoutFromMinutes(req.HealthTimeoutMinutes))
+
+	return upgrade, nil
+}
+
+// GetNodeUpgrades lists the upgrade history of a node
+func GetNodeUpgrades(nodeID, userID string) ([]models.NodeUpgrade, error) {
+	return repository.GetNodeUpgrades(nodeID, userID)
+}
+
+// StartFleetUpgrade upgrades several nodes in batches. A batch only starts once
+// every node in the previous one is healthy on the new version; any failure or
+// rollback halts the rollout and the remaining nodes are skipped.
+func StartFleetUpgrade(userID string, req models.FleetUpgradeRequest) (models.UpgradeRollout, error) {
+	if req.TargetVersion == "" {
+		return models.UpgradeRollout{}, fmt.Errorf("target version is required")
+	}
+
+	client := req.Client
+	if client == "" {
+		client = DefaultClient
+	}
+	if _, _, err := resolveClient(client, req.TargetVersion); err != nil {
+		return models.UpgradeRollout{}, err
+	}
+
+	batchSize := req.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+
+	// Collect the nodes to upgrade
+	var nodes []models.Node
+	if len(req.NodeIDs) == 0 {
+		all, err := repository.GetNodesByUserID(userID)
+		if err != nil {
+			return models.UpgradeRollout{}, err
+		}
+		for _, n := range all {
+			if n.Status == "running" && n.Client == client && n.ClientVersion != req.TargetVersion &&
+				requireBootstrappedNode(n, "upgraded") == nil {
+				nodes = append(nodes, n)
+			}
+		}
+	} else {
+		seen := make(map[string]bool, len(req.NodeIDs))
+		for _, id := range req.NodeIDs {
+			// A node listed twice is upgraded once
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			n, err := repository.GetNodeByID(id, userID)
+			if err != nil {
+				return models.UpgradeRollout{}, err
+			}
+			if n.ID == "" {
+				return models.UpgradeRollout{}, fmt.Errorf("node %s not found", id)
+			}
+			if n.Client != client {
+				return models.UpgradeRollout{}, fmt.Errorf("node %s runs %s, not %s", n.Name, n.Client, client)
+			}
+			nodes = append(nodes, n)
+		}
+	}
+
+	if len(nodes) == 0 {
+		return models.UpgradeRollout{}, fmt.Errorf("no nodes to upgrade")
+	}
+
+	now := time.Now()
+	rollout := models.UpgradeRollout{
+		ID:            uuid.New().String(),
+		UserID:        userID,
+		TargetVersion: req.TargetVersion,
+		BatchSize:     batchSize,
+		Status:        "running",
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	// Validate every node before anything is started
+	upgrades := make([]models.NodeUpgrade, 0, len(nodes))
+	for _, n := range nodes {
+		upgrade, err := newNodeUpgrade(n, req.TargetVersion, rollout.ID)
+		if err != nil {
+			return models.UpgradeRollout{}, err
+		}
+		upgrades = append(upgrades, upgrade)
+	}
+
+	if err := repository.SaveUpgradeRollout(rollout); err != nil {
+		return models.UpgradeRollout{}, fmt.Errorf("failed to save rollout: %v", err)
+	}
+	for i := range upgrades {
+		if err := createNodeUpgrade(upgrades[i], nodes[i]); err != nil {
+			// Another upgrade got to a node first, release the ones already claimed
+			for j := 0; j < i; j++ {
+				saveUpgradeStatus(&upgrades[j], "skipped", "Rollout cancelled before it started")
+			}
+			rollout.Status = "halted"
+			rollout.Message = err.Error()
+			rollout.UpdatedAt = time.Now()
+			if saveErr := repository.SaveUpgradeRollout(rollout); saveErr != nil {
+				log.Printf("Failed to save rollout %s: %v", rollout.ID, saveErr)
+			}
+			return models.UpgradeRollout{}, err
+		}
+	}
+
+	go runFleetUpgrade(rollout, append([]models.NodeUpgrade(nil), upgrades...), healthTimeoutFromMinutes(req.HealthTimeoutMinutes))
+
+	rollout.Upgrades = upgrades
+	return rollout, nil
+}
+
+// runFleetUpgrade works through a rollout batch by batch
+func runFleetUpgrade(rollout models.UpgradeRollout, upgrades []models.NodeUpgrade, healthTimeout time.Duration) {
+	for start := 0; start < len(upgrades); start += rollout.BatchSize {
+		end := start + rollout.BatchSize
+		if end > len(upgrades) {
+			end = len(upgrades)
+		}
+
+		// Upgrade the batch in parallel
+		var wg sync.WaitGroup
+		results := make([]bool, end-start)
+		for i := start; i < end; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i-start] = runNodeUpgrade(&upgrades[i], healthTimeout)
+			}(i)
+		}
+		wg.Wait()
+
+		for _, ok := range results {
+			if ok {
+				continue
+			}
+
+			// Halt the rollout and skip the nodes that haven't been touched
+			for i := end; i < len(upgrades); i++ {
+				saveUpgradeStatus(&upgrades[i], "skipped", "Rollout halted after a failed upgrade")
+			}
+			rollout.Status = "halted"
+			rollout.Message = fmt.Sprintf("Halted after batch %d, an upgrade failed or was rolled back", start/rollout.BatchSize+1)
+			rollout.UpdatedAt = time.Now()
+			if err := repository.SaveUpgradeRollout(rollout); err != nil {
+				log.Printf("Failed to save rollout %s: %v", rollout.ID, err)
+			}
+			return
+		}
+	}
+
+	rollout.Status = "succeeded"
+	rollout.Message = fmt.Sprintf("%d nodes upgraded to %s", len(upgrades), rollout.TargetVersion)
+	rollout.UpdatedAt = time.Now()
+	if err := repository.SaveUpgradeRollout(rollout); err != nil {
+		log.Printf("Failed to save rollout %s: %v", rollout.ID, err)
+	}
+}
+
+// GetUpgradeRollout returns a rollout with the state of each node upgrade
+func GetUpgradeRollout(rolloutID, userID string) (models.UpgradeRollout, error) {
+	rollout, err := repository.GetUpgradeRollout(rolloutID, userID)
+	if err != nil {
+		return models.UpgradeRollout{}, err
+	}
+	if rollout.ID == "" {
+		return models.UpgradeRollout{}, fmt.Errorf("rollout not found")
+	}
+
+	rollout.Upgrades, err = repository.GetRolloutUpgrades(rolloutID)
+	if err != nil {
+		return models.UpgradeRollout{}, err
+	}
+
+	return rollout, nil
+}
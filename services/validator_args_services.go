@@ -0,0 +1,165 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/0saurabh0/NodeEase/models"
+)
+
+// Value types extra validator arguments can take
+const (
+	argBool     = "bool"     // No value
+	argInt      = "int"      // Integer within Min and Max
+	argPath     = "path"     // Absolute file path on the node
+	argURL      = "url"      // http(s) or grpc URL
+	argHostPort = "hostport" // host:port
+)
+
+// argSchema describes an extra validator argument users may pass
+type argSchema struct {
+	Type        string
+	Min         int64
+	Max         int64
+	Repeatable  bool
+	Description string
+}
+
+// agaveExtraArgs are the agave-validator flags users can add on top of the
+// flags NodeEase manages
+var agaveExtraArgs = map[string]argSchema{
+	"--rpc-threads":                          {Type: argInt, Min: 1, Max: 1024, Description: "Number of threads serving RPC requests"},
+	"--rpc-max-multiple-accounts":            {Type: argInt, Min: 1, Max: 10000, Description: "Maximum accounts accepted by getMultipleAccounts"},
+	"--rpc-max-request-body-size":            {Type: argInt, Min: 1024, Max: 1 << 30, Description: "Maximum RPC request body size in bytes"},
+	"--rpc-send-retry-ms":                    {Type: argInt, Min: 1, Max: 60000, Description: "Retry interval for sendTransaction in milliseconds"},
+	"--rpc-send-batch-ms":                    {Type: argInt, Min: 1, Max: 60000, Description: "Batch interval for sendTransaction in milliseconds"},
+	"--rpc-send-batch-size":                  {Type: argInt, Min: 1, Max: 10000, Description: "Transactions per sendTransaction batch"},
+	"--rpc-pubsub-enable-block-subscription": {Type: argBool, Description: "Enable blockSubscribe over websockets"},
+	"--rpc-pubsub-enable-vote-subscription":  {Type: argBool, Description: "Enable voteSubscribe over websockets"},
+	"--accounts-db-cache-limit-mb":           {Type: argInt, Min: 1, Max: 1 << 20, Description: "Accounts cache size limit in MB"},
+	"--accounts-index-memory-limit-mb":       {Type: argInt, Min: 1, Max: 1 << 20, Description: "Accounts index memory limit in MB"},
+	"--health-check-slot-distance":           {Type: argInt, Min: 1, Max: 10000, Description: "Slots behind before the node reports unhealthy"},
+	"--minimal-snapshot-download-speed":      {Type: argInt, Min: 1, Max: 1 << 40, Description: "Minimum snapshot download speed in bytes per second"},
+	"--log-messages-bytes-limit":             {Type: argInt, Min: 1, Max: 1 << 30, Description: "Maximum bytes of transaction log messages stored"},
+	"--geyser-plugin-config":                 {Type: argPath, Repeatable: true, Description: "Path to a Geyser plugin config file"},
+}
+
+// jitoExtraArgs are the Jito-Solana options for block engine and relayer connections
+var jitoExtraArgs = map[string]argSchema{
+	"--block-engine-url":       {Type: argURL, Description: "Block engine to connect to"},
+	"--relayer-url":            {Type: argURL, Description: "Relayer to connect to"},
+	"--shred-receiver-address": {Type: argHostPort, Description: "Address shreds are forwarded to"},
+}
+
+// mergeArgs returns a new schema containing both sets of arguments
+func mergeArgs(base, extra map[string]argSchema) map[string]argSchema {
+	merged := make(map[string]argSchema, len(base)+len(extra))
+	for name, schema := range base {
+		merged[name] = schema
+	}
+	for name, schema := range extra {
+		merged[name] = schema
+	}
+	return merged
+}
+
+var (
+	// absolutePathPattern matches absolute paths made of safe characters
+	absolutePathPattern = regexp.MustCompile(`^/[A-Za-z0-9._/-]+$`)
+
+	// hostPortPattern matches host:port and bare names
+	hostPortPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+(:[0-9]{1,5})?$`)
+)
+
+// validateArgValue checks a single argument value against its type
+func validateArgValue(name string, schema argSchema, value string) error {
+	switch schema.Type {
+	case argInt:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s expects an integer, got %q", name, value)
+		}
+		if n < schema.Min || n > schema.Max {
+			return fmt.Errorf("%s must be between %d and %d", name, schema.Min, schema.Max)
+		}
+	case argPath:
+		if !absolutePathPattern.MatchString(value) || strings.Contains(value, "..") {
+			return fmt.Errorf("%s expects an absolute path, got %q", name, value)
+		}
+	case argURL:
+		u, err := url.Parse(value)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "grpc") {
+			return fmt.Errorf("%s expects an http(s) or grpc URL, got %q", name, value)
+		}
+	case argHostPort:
+		if !hostPortPattern.MatchString(value) {
+			return fmt.Errorf("%s expects a host or host:port, got %q", name, value)
+		}
+	}
+	return nil
+}
+
+// validateExtraArgs checks extra validator arguments against the client's
+// schema and rejects anything NodeEase manages itself
+func validateExtraArgs(client validatorClient, args []models.ValidatorFlag) error {
+	seen := map[string]bool{}
+	for _, arg := range args {
+		if _, managed := client.SupportedFlags[arg.Name]; managed {
+			return fmt.Errorf("%s is managed by NodeEase and can't be set as an extra argument", arg.Name)
+		}
+
+		schema, ok := client.ExtraArgs[arg.Name]
+		if !ok {
+			return fmt.Errorf("%s does not accept %s as an extra argument", client.DisplayName, arg.Name)
+		}
+
+		if seen[arg.Name] && !schema.Repeatable {
+			return fmt.Errorf("%s can only be given once", arg.Name)
+		}
+		seen[arg.Name] = true
+
+		if schema.Type == argBool {
+			if len(arg.Values) != 0 {
+				return fmt.Errorf("%s doesn't take a value", arg.Name)
+			}
+			continue
+		}
+
+		if len(arg.Values) != 1 {
+			return fmt.Errorf("%s takes exactly one value", arg.Name)
+		}
+		if err := validateArgValue(arg.Name, schema, arg.Values[0]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// extraArgInfo lists a client's extra arguments for the catalog API
+func extraArgInfo(client validatorClient) []models.ValidatorArgInfo {
+	args := make([]models.ValidatorArgInfo, 0, len(client.ExtraArgs))
+	for name, schema := range client.ExtraArgs {
+		info := models.ValidatorArgInfo{
+			Name:        name,
+			Type:        schema.Type,
+			Repeatable:  schema.Repeatable,
+			Description: schema.Description,
+		}
+		if schema.Type == argInt {
+			info.Min = schema.Min
+			info.Max = schema.Max
+		}
+		args = append(args, info)
+	}
+
+	sort.Slice(args, func(i, j int) bool {
+		return args[i].Name < args[j].Name
+	})
+
+	return args
+}
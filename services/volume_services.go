@@ -0,0 +1,166 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/0saurabh0/NodeEase/db/repository"
+	"github.com/0saurabh0/NodeEase/models"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+const (
+	// ledgerDeviceName is the block device name requested for the ledger volume
+	ledgerDeviceName = "/dev/sdf"
+
+	// accountsDeviceName is the block device name requested for the accounts volume
+	accountsDeviceName = "/dev/sdg"
+)
+
+// volumeLimits holds the EBS limits for a volume type
+type volumeLimits struct {
+	MinSize, MaxSize             int
+	MinIOPS, MaxIOPS             int
+	MaxIOPSPerGB                 int
+	MinThroughput, MaxThroughput int
+	MaxThroughputPerIOPS         float64 // MiB/s allowed per provisioned IOPS
+}
+
+// supportedVolumeTypes lists the EBS volume types allowed for data volumes
+var supportedVolumeTypes = map[string]volumeLimits{
+	"gp3": {MinSize: 1, MaxSize: 16384, MinIOPS: 3000, MaxIOPS: 16000, MaxIOPSPerGB: 500, MinThroughput: 125, MaxThroughput: 1000, MaxThroughputPerIOPS: 0.25},
+	"io2": {MinSize: 4, MaxSize: 65536, MinIOPS: 100, MaxIOPS: 256000, MaxIOPSPerGB: 1000},
+}
+
+// validateVolumeSpec checks a data volume against the limits of its type
+func validateVolumeSpec(name string, spec *models.VolumeSpec) error {
+	if spec == nil {
+		return nil
+	}
+
+	limits, ok := supportedVolumeTypes[spec.Type]
+	if !ok {
+		return fmt.Errorf("%s volume: unsupported type %q (use gp3 or io2)", name, spec.Type)
+	}
+
+	if spec.Size < limits.MinSize || spec.Size > limits.MaxSize {
+		return fmt.Errorf("%s volume: %s size must be between %d and %d GB", name, spec.Type, limits.MinSize, limits.MaxSize)
+	}
+
+	// io2 requires provisioned IOPS, gp3 defaults to its baseline
+	if spec.Type == "io2" && spec.IOPS == 0 {
+		return fmt.Errorf("%s volume: io2 volumes require iops", name)
+	}
+	if spec.IOPS != 0 {
+		if spec.IOPS < limits.MinIOPS || spec.IOPS > limits.MaxIOPS {
+			return fmt.Errorf("%s volume: %s iops must be between %d and %d", name, spec.Type, limits.MinIOPS, limits.MaxIOPS)
+		}
+		if spec.IOPS > spec.Size*limits.MaxIOPSPerGB {
+			return fmt.Errorf("%s volume: %s allows at most %d iops per GB", name, spec.Type, limits.MaxIOPSPerGB)
+		}
+	}
+
+	if spec.Throughput != 0 {
+		if limits.MaxThroughput == 0 {
+			return fmt.Errorf("%s volume: throughput can only be set on gp3 volumes", name)
+		}
+		if spec.Throughput < limits.MinThroughput || spec.Throughput > limits.MaxThroughput {
+			return fmt.Errorf("%s volume: gp3 throughput must be between %d and %d MiB/s", name, limits.MinThroughput, limits.MaxThroughput)
+		}
+
+		// Throughput is capped by the provisioned IOPS, the baseline if none are set
+		iops := spec.IOPS
+		if iops == 0 {
+			iops = limits.MinIOPS
+		}
+		if maxThroughput := int(float64(iops) * limits.MaxThroughputPerIOPS); spec.Throughput > maxThroughput {
+			return fmt.Errorf("%s volume: gp3 throughput of %d MiB/s needs at least %d iops, %d allows at most %d MiB/s",
+				name, spec.Throughput, int(float64(spec.Throughput)/limits.MaxThroughputPerIOPS), iops, maxThroughput)
+		}
+	}
+
+	return nil
+}
+
+// validateDataVolumes checks the optional ledger and accounts volumes on a request
+func validateDataVolumes(req models.NodeDeployRequest) error {
+	if err := validateVolumeSpec("ledger", req.LedgerVolume); err != nil {
+		return err
+	}
+	return validateVolumeSpec("accounts", req.AccountsVolume)
+}
+
+// dataVolumeMapping builds the block device mapping for a data volume
+func dataVolumeMapping(deviceName string, spec *models.VolumeSpec) *ec2.BlockDeviceMapping {
+	ebs := &ec2.EbsBlockDevice{
+		DeleteOnTermination: aws.Bool(true),
+		VolumeSize:          aws.Int64(int64(spec.Size)),
+		VolumeType:          aws.String(spec.Type),
+	}
+	if spec.IOPS != 0 {
+		ebs.Iops = aws.Int64(int64(spec.IOPS))
+	}
+	if spec.Throughput != 0 {
+		ebs.Throughput = aws.Int64(int64(spec.Throughput))
+	}
+
+	return &ec2.BlockDeviceMapping{
+		DeviceName: aws.String(deviceName),
+		Ebs:        ebs,
+	}
+}
+
+// buildBlockDeviceMappings returns the root volume plus any requested data volumes
+func buildBlockDeviceMappings(req models.NodeDeployRequest) []*ec2.BlockDeviceMapping {
+	mappings := []*ec2.BlockDeviceMapping{
+		{
+			DeviceName: aws.String("/dev/sda1"),
+			Ebs: &ec2.EbsBlockDevice{
+				DeleteOnTermination: aws.Bool(true),
+				VolumeSize:          aws.Int64(int64(req.DiskSize)),
+				VolumeType:          aws.String("gp3"),
+			},
+		},
+	}
+
+	if req.LedgerVolume != nil {
+		mappings = append(mappings, dataVolumeMapping(ledgerDeviceName, req.LedgerVolume))
+	}
+	if req.AccountsVolume != nil {
+		mappings = append(mappings, dataVolumeMapping(accountsDeviceName, req.AccountsVolume))
+	}
+
+	return mappings
+}
+
+// updateNodeVolumes records the EBS volume IDs attached to the node's data devices
+func updateNodeVolumes(nodeID string, instance *ec2.Instance) error {
+	var ledgerVolumeID, accountsVolumeID string
+	for _, mapping := range instance.BlockDeviceMappings {
+		if mapping.Ebs == nil {
+			continue
+		}
+		switch aws.StringValue(mapping.DeviceName) {
+		case ledgerDeviceName:
+			ledgerVolumeID = aws.StringValue(mapping.Ebs.VolumeId)
+		case accountsDeviceName:
+			accountsVolumeID = aws.StringValue(mapping.Ebs.VolumeId)
+		}
+	}
+
+	if ledgerVolumeID == "" && accountsVolumeID == "" {
+		return nil
+	}
+
+	node, err := repository.GetNodeByIDInternal(nodeID)
+	if err != nil {
+		return err
+	}
+
+	node.LedgerVolumeID = ledgerVolumeID
+	node.AccountsVolumeID = accountsVolumeID
+	node.UpdatedAt = time.Now()
+
+	return repository.SaveNode(node)
+}
@@ -9,28 +9,42 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sync"
 )
 
-var encryptionKey []byte
-
-func init() {
-	key := os.Getenv("ENCRYPTION_KEY")
-	if key == "" {
-		panic("ENCRYPTION_KEY environment variable is not set")
-	}
-
-	// Decode the base64 key
-	decodedKey, err := base64.StdEncoding.DecodeString(key)
-	if err != nil {
-		panic(fmt.Sprintf("Invalid ENCRYPTION_KEY format: %v", err))
-	}
-
-	// Check if the key is the correct length for AES-256
-	if len(decodedKey) != 32 {
-		panic("ENCRYPTION_KEY must be a base64-encoded 32-byte value")
-	}
+var (
+	encryptionKey     []byte
+	encryptionKeyErr  error
+	encryptionKeyOnce sync.Once
+)
 
-	encryptionKey = decodedKey
+// LoadEncryptionKey reads ENCRYPTION_KEY once. It's called at startup, after
+// the .env file is loaded, and before the key is first used.
+func LoadEncryptionKey() error {
+	encryptionKeyOnce.Do(func() {
+		key := os.Getenv("ENCRYPTION_KEY")
+		if key == "" {
+			encryptionKeyErr = errors.New("ENCRYPTION_KEY environment variable is not set")
+			return
+		}
+
+		// Decode the base64 key
+		decodedKey, err := base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			encryptionKeyErr = fmt.Errorf("invalid ENCRYPTION_KEY format: %v", err)
+			return
+		}
+
+		// Check if the key is the correct length for AES-256
+		if len(decodedKey) != 32 {
+			encryptionKeyErr = errors.New("ENCRYPTION_KEY must be a base64-encoded 32-byte value")
+			return
+		}
+
+		encryptionKey = decodedKey
+	})
+
+	return encryptionKeyErr
 }
 
 // Encrypt encrypts a string using AES-GCM
@@ -39,6 +53,10 @@ func Encrypt(plaintext string) (string, error) {
 		return "", errors.New("plaintext cannot be empty")
 	}
 
+	if err := LoadEncryptionKey(); err != nil {
+		return "", err
+	}
+
 	block, err := aes.NewCipher(encryptionKey)
 	if err != nil {
 		return "", fmt.Errorf("failed to create cipher: %v", err)
@@ -64,6 +82,10 @@ func Decrypt(encrypted string) (string, error) {
 		return "", errors.New("encrypted text cannot be empty")
 	}
 
+	if err := LoadEncryptionKey(); err != nil {
+		return "", err
+	}
+
 	ciphertext, err := base64.StdEncoding.DecodeString(encrypted)
 	if err != nil {
 		return "", fmt.Errorf("failed to decode base64: %v", err)